@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
@@ -11,16 +12,29 @@ import (
 
 func main() {
 	log.Println("Starting...")
-	bot, apicnt, duration := initialize.App()
+	bot, scheduler, outbox := initialize.App()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	polling := make(chan struct{})
+	sending := make(chan struct{})
 	go bot.Bot.Start()
-	go initialize.StartPolling(apicnt, duration)
+	go func() {
+		initialize.StartPolling(ctx, scheduler)
+		close(polling)
+	}()
+	go func() {
+		initialize.StartSending(ctx, outbox)
+		close(sending)
+	}()
 
 	log.Println("Started")
 
 	<-quit
 	log.Println("Quit")
+	cancel()
+	<-polling
+	<-sending
 }
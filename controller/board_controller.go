@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+)
+
+// BoardCatalog provides lists of boards of imageboards
+type BoardCatalog interface {
+	GetBoards(source string) ([]logic.Board, error) // Returns boards of source sorted by name
+}
+
+// ErrUnknownSource is returned by BoardCatalog when imageboard is not configured
+var ErrUnknownSource = errors.New("unknown source")
+
+// BoardError describes board which is missing in catalog
+type BoardError struct {
+	Source        string   // Imageboard name, empty for logic.DefaultSource
+	Board         string   // Requested board
+	Suggestions   []string // Known boards with similar names
+	UnknownSource bool     // Imageboard itself is not configured
+}
+
+func (e *BoardError) Error() string {
+	if e.UnknownSource {
+		return "unknown imageboard " + e.Source
+	}
+
+	msg := "unknown board " + formatBoard(e.Source, e.Board)
+	if len(e.Suggestions) == 0 {
+		return msg
+	}
+
+	boards := make([]string, len(e.Suggestions))
+	for i, board := range e.Suggestions {
+		boards[i] = formatBoard(e.Source, board)
+	}
+	return fmt.Sprintf("%s, did you mean %s?", msg, strings.Join(boards, ", "))
+}
+
+// Formats board as "/board" or "/source/board"
+func formatBoard(source, board string) string {
+	if source == "" || source == logic.DefaultSource {
+		return "/" + board
+	}
+	return fmt.Sprintf("/%s/%s", source, board)
+}
+
+// Max amount of suggested boards
+const maxSuggestions = 3
+
+// Max edit distance of suggested board
+const maxSuggestionDistance = 2
+
+// BoardController is an implementation of controller.Board
+type BoardController struct {
+	catalog BoardCatalog
+}
+
+// NewBoardController constructor of BoardController struct
+// Boards are not validated if catalog is nil
+func NewBoardController(catalog BoardCatalog) *BoardController {
+	return &BoardController{catalog: catalog}
+}
+
+// GetBoards returns boards of source
+func (bcon *BoardController) GetBoards(source string) ([]logic.Board, error) {
+	if bcon.catalog == nil {
+		return nil, fmt.Errorf("boards of %s are unknown", source)
+	}
+	if source == "" {
+		source = logic.DefaultSource
+	}
+
+	return bcon.catalog.GetBoards(source)
+}
+
+// CheckBoard returns BoardError if board or its imageboard is missing in catalog
+// Board is accepted if list of boards can not be loaded
+func (bcon *BoardController) CheckBoard(source, board string) error {
+	if bcon.catalog == nil {
+		return nil
+	}
+
+	boards, err := bcon.GetBoards(source)
+	if errors.Is(err, ErrUnknownSource) {
+		return &BoardError{Source: source, Board: board, UnknownSource: true}
+	}
+	if err != nil {
+		log.Println("BoardController.CheckBoard-GetBoards", err)
+		return nil
+	}
+
+	for _, known := range boards {
+		if known.Name == board {
+			return nil
+		}
+	}
+
+	return &BoardError{
+		Source:      source,
+		Board:       board,
+		Suggestions: suggestBoards(boards, board),
+	}
+}
+
+// Returns names of boards closest to board
+func suggestBoards(boards []logic.Board, board string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	candidates := make([]candidate, 0)
+	for _, known := range boards {
+		distance := editDistance(known.Name, board)
+		if distance <= maxSuggestionDistance && distance < len([]rune(board)) {
+			candidates = append(candidates, candidate{known.Name, distance})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	suggestions := make([]string, 0, maxSuggestions)
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].name)
+	}
+	return suggestions
+}
+
+// Returns Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minOf(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// Returns the smallest of values
+func minOf(values ...int) int {
+	result := values[0]
+	for _, v := range values[1:] {
+		if v < result {
+			result = v
+		}
+	}
+	return result
+}
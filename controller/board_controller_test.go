@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/stretchr/testify/assert"
+)
+
+// Catalog with fixed boards, list of source without boards can not be loaded
+type staticCatalog map[string][]logic.Board
+
+func (c staticCatalog) GetBoards(source string) ([]logic.Board, error) {
+	boards, ok := c[source]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownSource, source)
+	}
+	if boards == nil {
+		return nil, errors.New("catalog is not available")
+	}
+	return boards, nil
+}
+
+func TestBoardController_CheckBoard(t *testing.T) {
+	assert := assert.New(t)
+
+	bcon := NewBoardController(staticCatalog{
+		logic.DefaultSource: {
+			{Source: logic.DefaultSource, Name: "b", Title: "Бред"},
+			{Source: logic.DefaultSource, Name: "w", Title: "Обои и высокое разрешение"},
+			{Source: logic.DefaultSource, Name: "wm", Title: "Military"},
+			{Source: logic.DefaultSource, Name: "wp", Title: "Обои"},
+		},
+		"4chan": nil,
+	})
+
+	tests := []struct {
+		name    string
+		source  string
+		board   string
+		wantErr error
+	}{
+		{
+			name:  "Known board",
+			board: "wp",
+		},
+		{
+			name:    "Typo",
+			board:   "wq",
+			wantErr: &BoardError{Board: "wq", Suggestions: []string{"w", "wm", "wp"}},
+		},
+		{
+			name:    "No similar boards",
+			source:  logic.DefaultSource,
+			board:   "zzz",
+			wantErr: &BoardError{Source: logic.DefaultSource, Board: "zzz", Suggestions: []string{}},
+		},
+		{
+			name:   "Catalog is not available",
+			source: "4chan",
+			board:  "zzz",
+		},
+		{
+			name:    "Unknown source",
+			source:  "foo",
+			board:   "b",
+			wantErr: &BoardError{Source: "foo", Board: "b", UnknownSource: true},
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(tt.wantErr, bcon.CheckBoard(tt.source, tt.board), tt.name)
+	}
+
+	assert.Nil(NewBoardController(nil).CheckBoard("", "zzz"))
+}
+
+func TestBoardError_Error(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("unknown board /wq, did you mean /w, /wp?",
+		(&BoardError{Board: "wq", Suggestions: []string{"w", "wp"}}).Error())
+	assert.Equal("unknown board /4chan/zzz",
+		(&BoardError{Source: "4chan", Board: "zzz"}).Error())
+	assert.Equal("unknown imageboard foo",
+		(&BoardError{Source: "foo", Board: "b", UnknownSource: true}).Error())
+}
+
+func Test_parseRequest_UnknownBoard(t *testing.T) {
+	assert := assert.New(t)
+
+	bcon := NewBoardController(staticCatalog{
+		logic.DefaultSource: {{Source: logic.DefaultSource, Name: "wp"}},
+	})
+
+	_, err := parseRequest("wq .img \"C\"", bcon, nil)
+	assert.Equal(&BoardError{Board: "wq", Suggestions: []string{"wp"}}, err)
+
+	_, err = parseRequestAlias("wq .img \"C\" Default", bcon, nil)
+	assert.Equal(&BoardError{Board: "wq", Suggestions: []string{"wp"}}, err)
+
+	pub, err := parseRequest("wp .img \"C\"", bcon, nil)
+	assert.Nil(err)
+	assert.Equal("wp", pub.Board)
+}
@@ -0,0 +1,18 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/aoyako/telegram_2ch_res_bot/media"
+)
+
+// Config struct for controllers
+type Config struct {
+	LedgerRetention time.Duration   // How long delivered files are remembered, 0 to remember forever
+	Catalog         BoardCatalog    // Known boards, nil to accept any board
+	Media           *media.Registry // Known file type groups, nil to accept any type
+
+	OutboxAttempts   int           // Attempts of queued delivery, 0 means no limit
+	OutboxMinBackoff time.Duration // Delay after the first failed delivery attempt
+	OutboxMaxBackoff time.Duration // Max delay between delivery attempts
+}
@@ -27,8 +27,40 @@ type Subscription interface {
 
 // Info interface definces methods for Info Controller
 type Info interface {
-	GetLastTimestamp() uint64    // Returns time of the latest post
-	SetLastTimestamp(tsp uint64) // Sets time of the latest post
+	GetBoardTimestamp(source, board string) (uint64, error)                 // Returns time of the latest post on board
+	SetBoardTimestamp(source, board string, tsp uint64) error               // Sets time of the latest post on board
+	GetThreadCursors(source, board string) (map[uint64]logic.Cursor, error) // Returns cursors of board's threads by thread number
+	SetThreadCursor(source, board string, threadID, tsp, num uint64) error  // Sets time and number of the latest post in thread
+	RemoveStaleThreads(source, board string, alive []uint64) error          // Forgets threads which are not in alive
+}
+
+// Ledger interface defines methods for Ledger Controller
+type Ledger interface {
+	FilterUnsent(key string, users []*logic.User) ([]*logic.User, error) // Returns users which did not receive file, without duplicates
+	MarkSent(key string, users []*logic.User) error                      // Records delivery of file to users
+	PruneSent() error                                                    // Forgets deliveries older than retention window
+}
+
+// Outbox interface defines methods for Outbox Controller
+type Outbox interface {
+	Enqueue(chatIDs []int64, url, caption, key string) error                  // Queues file for sending to chats
+	EnqueueAlbum(chatIDs []int64, files []logic.File, caption string) error   // Queues files for sending to chats together
+	GetDueDeliveries(limit int) ([]logic.Delivery, error)                     // Returns deliveries which should be sent now
+	CompleteDelivery(delivery *logic.Delivery) error                          // Removes sent delivery from queue
+	FailDelivery(delivery *logic.Delivery, cause error, permanent bool) error // Schedules retry or moves delivery to dead letters
+}
+
+// Upload interface defines methods for Upload Controller
+type Upload interface {
+	GetFileID(key string) (string, error) // Returns telegram file id of file, empty if it was not uploaded
+	SaveFileID(key, fileID string) error  // Records file id of uploaded file
+	ForgetFileID(key string) error        // Forgets file id which telegram does not accept
+}
+
+// Board interface defines methods for Board Controller
+type Board interface {
+	GetBoards(source string) ([]logic.Board, error) // Returns boards of imageboard
+	CheckBoard(source, board string) error          // Returns BoardError if board does not exist
 }
 
 // Controller struct is used to access database
@@ -36,13 +68,24 @@ type Controller struct {
 	User
 	Subscription
 	Info
+	Ledger
+	Board
+	Outbox
+	Upload
 }
 
 // NewController constructor of Controller
-func NewController(stg *storage.Storage) *Controller {
+func NewController(stg *storage.Storage, cfg *Config) *Controller {
+	board := NewBoardController(cfg.Catalog)
+	subscription := NewSubscriptionController(stg, board)
+	subscription.Media = cfg.Media
 	return &Controller{
 		User:         NewUserController(stg),
-		Subscription: NewSubscriptionController(stg),
+		Subscription: subscription,
 		Info:         NewInfoController(stg),
+		Ledger:       NewLedgerController(stg, cfg.LedgerRetention),
+		Board:        board,
+		Outbox:       NewOutboxController(stg, cfg),
+		Upload:       NewUploadController(stg),
 	}
 }
@@ -1,8 +1,10 @@
 package controller
 
 import (
+	"log"
 	"sync"
 
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
 	"github.com/aoyako/telegram_2ch_res_bot/storage"
 )
 
@@ -17,17 +19,66 @@ func NewInfoController(stg *storage.Storage) *InfoController {
 	return &InfoController{stg: stg}
 }
 
-// GetLastTimestamp returns time of the latest post
-func (icon *InfoController) GetLastTimestamp() uint64 {
-	return icon.stg.GetLastTimestamp()
+// GetBoardTimestamp returns time of the latest post on board
+func (icon *InfoController) GetBoardTimestamp(source, board string) (uint64, error) {
+	cursor, err := icon.stg.GetBoardCursor(source, board)
+	if err != nil {
+		log.Println("InfoController.GetBoardTimestamp-GetBoardCursor", err)
+		return 0, err
+	}
+
+	return cursor.LastPost, nil
 }
 
-// SetLastTimestamp sets time of the latest post
-func (icon *InfoController) SetLastTimestamp(tsp uint64) {
+// SetBoardTimestamp sets time of the latest post on board
+// Cursor never moves backwards
+func (icon *InfoController) SetBoardTimestamp(source, board string, tsp uint64) error {
 	icon.m.Lock()
-	last := icon.GetLastTimestamp()
-	if last < tsp {
-		icon.stg.SetLastTimestamp(tsp)
+	defer icon.m.Unlock()
+
+	last, err := icon.GetBoardTimestamp(source, board)
+	if err != nil {
+		return err
+	}
+	if last >= tsp {
+		return nil
+	}
+
+	return icon.stg.SaveCursor(&logic.Cursor{
+		Source:   source,
+		Board:    board,
+		LastPost: tsp,
+	})
+}
+
+// GetThreadCursors returns cursors of board's threads by thread number
+func (icon *InfoController) GetThreadCursors(source, board string) (map[uint64]logic.Cursor, error) {
+	cursors, err := icon.stg.GetThreadCursors(source, board)
+	if err != nil {
+		log.Println("InfoController.GetThreadCursors-GetThreadCursors", err)
+		return nil, err
 	}
-	icon.m.Unlock()
+
+	result := make(map[uint64]logic.Cursor, len(cursors))
+	for _, cursor := range cursors {
+		result[cursor.Thread] = cursor
+	}
+
+	return result, nil
+}
+
+// SetThreadCursor sets time and number of the latest post in thread
+func (icon *InfoController) SetThreadCursor(source, board string, threadID, tsp, num uint64) error {
+	return icon.stg.SaveCursor(&logic.Cursor{
+		Source:   source,
+		Board:    board,
+		Thread:   threadID,
+		LastPost: tsp,
+		LastNum:  num,
+	})
+}
+
+// RemoveStaleThreads forgets board's threads which are not in alive
+func (icon *InfoController) RemoveStaleThreads(source, board string, alive []uint64) error {
+	return icon.stg.RemoveThreadCursors(source, board, alive)
 }
@@ -1,26 +1,38 @@
 package controller
 
 import (
+	"errors"
 	"testing"
 
 	mock_storage "github.com/aoyako/telegram_2ch_res_bot/controller/mock"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
 	"github.com/aoyako/telegram_2ch_res_bot/storage"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestInfoController_GetLastTimestamp(t *testing.T) {
+func TestInfoController_GetBoardTimestamp(t *testing.T) {
 	assert := assert.New(t)
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	tests := []struct {
-		name string
-		want uint64
+		name  string
+		board string
+		want  uint64
+		err   error
 	}{
 		{
 			"Get timestamp",
+			"a",
 			123,
+			nil,
+		},
+		{
+			"Storage error",
+			"a",
+			0,
+			errors.New("storage error"),
 		},
 	}
 
@@ -28,8 +40,8 @@ func TestInfoController_GetLastTimestamp(t *testing.T) {
 		m := mock_storage.NewMockStorage(ctrl)
 		m.MockInfo.
 			EXPECT().
-			GetLastTimestamp().
-			Return(tt.want)
+			GetBoardCursor(gomock.Eq("2ch"), gomock.Eq(tt.board)).
+			Return(&logic.Cursor{Board: tt.board, LastPost: tt.want}, tt.err)
 
 		icon := NewInfoController(&storage.Storage{
 			Info:         m.MockInfo,
@@ -37,30 +49,34 @@ func TestInfoController_GetLastTimestamp(t *testing.T) {
 			Subscription: m.MockSubscription,
 		})
 
-		tsmp := icon.GetLastTimestamp()
+		tsmp, err := icon.GetBoardTimestamp("2ch", tt.board)
 
+		assert.Equal(tt.err, err)
 		assert.Equal(tt.want, tsmp)
 	}
 }
 
-func TestInfoController_SetLastTimestamp(t *testing.T) {
+func TestInfoController_SetBoardTimestamp(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	tests := []struct {
 		name         string
+		board        string
 		before       uint64
 		want         uint64
 		shouldChange bool
 	}{
 		{
 			name:         "Set timestamp",
+			board:        "a",
 			before:       123,
 			want:         124,
 			shouldChange: true,
 		},
 		{
 			name:         "Do not set timestamp",
+			board:        "a",
 			before:       123,
 			want:         122,
 			shouldChange: false,
@@ -77,15 +93,62 @@ func TestInfoController_SetLastTimestamp(t *testing.T) {
 
 		m.MockInfo.
 			EXPECT().
-			GetLastTimestamp().
-			Return(tt.before)
+			GetBoardCursor(gomock.Eq("2ch"), gomock.Eq(tt.board)).
+			Return(&logic.Cursor{Board: tt.board, LastPost: tt.before}, nil)
 
 		if tt.shouldChange {
 			m.MockInfo.
 				EXPECT().
-				SetLastTimestamp(gomock.Eq(tt.want))
+				SaveCursor(gomock.Eq(&logic.Cursor{Source: "2ch", Board: tt.board, LastPost: tt.want})).
+				Return(nil)
 		}
 
-		icon.SetLastTimestamp(tt.want)
+		err := icon.SetBoardTimestamp("2ch", tt.board, tt.want)
+		assert.Nil(t, err)
+	}
+}
+
+func TestInfoController_GetThreadCursors(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name    string
+		board   string
+		cursors []logic.Cursor
+		want    map[uint64]logic.Cursor
+	}{
+		{
+			name:  "Get thread cursors",
+			board: "a",
+			cursors: []logic.Cursor{
+				{Board: "a", Thread: 1, LastPost: 10},
+				{Board: "a", Thread: 2, LastPost: 20, LastNum: 200},
+			},
+			want: map[uint64]logic.Cursor{
+				1: {Board: "a", Thread: 1, LastPost: 10},
+				2: {Board: "a", Thread: 2, LastPost: 20, LastNum: 200},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		m := mock_storage.NewMockStorage(ctrl)
+		icon := NewInfoController(&storage.Storage{
+			Info:         m.MockInfo,
+			User:         m.MockUser,
+			Subscription: m.MockSubscription,
+		})
+
+		m.MockInfo.
+			EXPECT().
+			GetThreadCursors(gomock.Eq("2ch"), gomock.Eq(tt.board)).
+			Return(tt.cursors, nil)
+
+		result, err := icon.GetThreadCursors("2ch", tt.board)
+
+		assert.Nil(err)
+		assert.Equal(tt.want, result)
 	}
 }
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"log"
+	"time"
+
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/storage"
+)
+
+// LedgerController is an implementation of controller.Ledger
+type LedgerController struct {
+	stg       *storage.Storage
+	Retention time.Duration // How long delivered files are remembered, 0 to remember forever
+	Clock     func() uint64 // Returns current unix time
+}
+
+// NewLedgerController constructor of LedgerController struct
+func NewLedgerController(stg *storage.Storage, retention time.Duration) *LedgerController {
+	return &LedgerController{
+		stg:       stg,
+		Retention: retention,
+		Clock: func() uint64 {
+			return uint64(time.Now().Unix())
+		},
+	}
+}
+
+// Returns the earliest remembered delivery time
+func (lcon *LedgerController) since() uint64 {
+	retention := uint64(lcon.Retention / time.Second)
+	now := lcon.Clock()
+	if retention == 0 || retention > now {
+		return 0
+	}
+	return now - retention
+}
+
+// FilterUnsent returns users which did not receive file with key, without duplicates
+func (lcon *LedgerController) FilterUnsent(key string, users []*logic.User) ([]*logic.User, error) {
+	unique := make([]*logic.User, 0, len(users))
+	chatIDs := make([]int64, 0, len(users))
+	seen := make(map[int64]bool)
+	for _, user := range users {
+		if !seen[user.ChatID] {
+			seen[user.ChatID] = true
+			unique = append(unique, user)
+			chatIDs = append(chatIDs, user.ChatID)
+		}
+	}
+
+	sent, err := lcon.stg.GetSentChats(key, chatIDs, lcon.since())
+	if err != nil {
+		log.Println("LedgerController.FilterUnsent-GetSentChats", err)
+		return unique, err
+	}
+
+	sentChats := make(map[int64]bool)
+	for _, chatID := range sent {
+		sentChats[chatID] = true
+	}
+
+	result := make([]*logic.User, 0, len(unique))
+	for _, user := range unique {
+		if !sentChats[user.ChatID] {
+			result = append(result, user)
+		}
+	}
+
+	return result, nil
+}
+
+// MarkSent records delivery of file with key to users
+func (lcon *LedgerController) MarkSent(key string, users []*logic.User) error {
+	chatIDs := make([]int64, len(users))
+	for i, user := range users {
+		chatIDs[i] = user.ChatID
+	}
+
+	return lcon.stg.SaveSent(key, chatIDs, lcon.Clock())
+}
+
+// PruneSent forgets deliveries older than retention window
+func (lcon *LedgerController) PruneSent() error {
+	since := lcon.since()
+	if since == 0 {
+		return nil
+	}
+
+	return lcon.stg.RemoveSentBefore(since)
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	mock_storage "github.com/aoyako/telegram_2ch_res_bot/controller/mock"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLedgerController_FilterUnsent(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	users := []*logic.User{
+		{ID: 1, ChatID: 10},
+		{ID: 2, ChatID: 20},
+		{ID: 1, ChatID: 10},
+		{ID: 3, ChatID: 30},
+	}
+
+	tests := []struct {
+		name      string
+		retention time.Duration
+		since     uint64
+		sent      []int64
+		err       error
+		want      []*logic.User
+	}{
+		{
+			name:      "Skip sent and duplicates",
+			retention: time.Hour,
+			since:     5000 - 3600,
+			sent:      []int64{20},
+			want:      []*logic.User{users[0], users[3]},
+		},
+		{
+			name:  "Remember forever",
+			since: 0,
+			sent:  []int64{},
+			want:  []*logic.User{users[0], users[1], users[3]},
+		},
+		{
+			name:      "Storage error",
+			retention: time.Hour,
+			since:     5000 - 3600,
+			err:       errors.New("storage error"),
+			want:      []*logic.User{users[0], users[1], users[3]},
+		},
+	}
+
+	for _, tt := range tests {
+		m := mock_storage.NewMockStorage(ctrl)
+		m.MockLedger.
+			EXPECT().
+			GetSentChats(gomock.Eq("md5:abc"), gomock.Eq([]int64{10, 20, 30}), gomock.Eq(tt.since)).
+			Return(tt.sent, tt.err)
+
+		lcon := NewLedgerController(&storage.Storage{
+			Ledger: m.MockLedger,
+		}, tt.retention)
+		lcon.Clock = func() uint64 { return 5000 }
+
+		result, err := lcon.FilterUnsent("md5:abc", users)
+
+		assert.Equal(tt.err, err, tt.name)
+		assert.Equal(tt.want, result, tt.name)
+	}
+}
+
+func TestLedgerController_MarkSent(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_storage.NewMockStorage(ctrl)
+	m.MockLedger.
+		EXPECT().
+		SaveSent(gomock.Eq("url:/a.png"), gomock.Eq([]int64{10, 20}), gomock.Eq(uint64(5000))).
+		Return(nil)
+
+	lcon := NewLedgerController(&storage.Storage{
+		Ledger: m.MockLedger,
+	}, time.Hour)
+	lcon.Clock = func() uint64 { return 5000 }
+
+	err := lcon.MarkSent("url:/a.png", []*logic.User{{ChatID: 10}, {ChatID: 20}})
+	assert.Nil(err)
+}
+
+func TestLedgerController_PruneSent(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_storage.NewMockStorage(ctrl)
+	m.MockLedger.
+		EXPECT().
+		RemoveSentBefore(gomock.Eq(uint64(5000 - 3600))).
+		Return(nil)
+
+	lcon := NewLedgerController(&storage.Storage{
+		Ledger: m.MockLedger,
+	}, time.Hour)
+	lcon.Clock = func() uint64 { return 5000 }
+
+	assert.Nil(lcon.PruneSent())
+
+	lcon.Retention = 0
+	assert.Nil(lcon.PruneSent())
+}
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/aoyako/telegram_2ch_res_bot/storage (interfaces: User,Subscription,Info)
+// Source: github.com/aoyako/telegram_2ch_res_bot/storage (interfaces: User,Subscription,Info,Ledger,Outbox,Upload)
 
 // Package mock_storage is a generated GoMock package.
 package mock_storage
@@ -321,28 +321,287 @@ func (m *MockInfo) EXPECT() *MockInfoMockRecorder {
 	return m.recorder
 }
 
-// GetLastTimestamp mocks base method
-func (m *MockInfo) GetLastTimestamp() uint64 {
+// GetBoardCursor mocks base method
+func (m *MockInfo) GetBoardCursor(arg0, arg1 string) (*logic.Cursor, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetLastTimestamp")
-	ret0, _ := ret[0].(uint64)
+	ret := m.ctrl.Call(m, "GetBoardCursor", arg0, arg1)
+	ret0, _ := ret[0].(*logic.Cursor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBoardCursor indicates an expected call of GetBoardCursor
+func (mr *MockInfoMockRecorder) GetBoardCursor(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBoardCursor", reflect.TypeOf((*MockInfo)(nil).GetBoardCursor), arg0, arg1)
+}
+
+// GetThreadCursors mocks base method
+func (m *MockInfo) GetThreadCursors(arg0, arg1 string) ([]logic.Cursor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetThreadCursors", arg0, arg1)
+	ret0, _ := ret[0].([]logic.Cursor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetThreadCursors indicates an expected call of GetThreadCursors
+func (mr *MockInfoMockRecorder) GetThreadCursors(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetThreadCursors", reflect.TypeOf((*MockInfo)(nil).GetThreadCursors), arg0, arg1)
+}
+
+// RemoveThreadCursors mocks base method
+func (m *MockInfo) RemoveThreadCursors(arg0, arg1 string, arg2 []uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveThreadCursors", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveThreadCursors indicates an expected call of RemoveThreadCursors
+func (mr *MockInfoMockRecorder) RemoveThreadCursors(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveThreadCursors", reflect.TypeOf((*MockInfo)(nil).RemoveThreadCursors), arg0, arg1, arg2)
+}
+
+// SaveCursor mocks base method
+func (m *MockInfo) SaveCursor(arg0 *logic.Cursor) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveCursor", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveCursor indicates an expected call of SaveCursor
+func (mr *MockInfoMockRecorder) SaveCursor(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveCursor", reflect.TypeOf((*MockInfo)(nil).SaveCursor), arg0)
+}
+
+// MockLedger is a mock of Ledger interface
+type MockLedger struct {
+	ctrl     *gomock.Controller
+	recorder *MockLedgerMockRecorder
+}
+
+// MockLedgerMockRecorder is the mock recorder for MockLedger
+type MockLedgerMockRecorder struct {
+	mock *MockLedger
+}
+
+// NewMockLedger creates a new mock instance
+func NewMockLedger(ctrl *gomock.Controller) *MockLedger {
+	mock := &MockLedger{ctrl: ctrl}
+	mock.recorder = &MockLedgerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockLedger) EXPECT() *MockLedgerMockRecorder {
+	return m.recorder
+}
+
+// GetSentChats mocks base method
+func (m *MockLedger) GetSentChats(arg0 string, arg1 []int64, arg2 uint64) ([]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSentChats", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSentChats indicates an expected call of GetSentChats
+func (mr *MockLedgerMockRecorder) GetSentChats(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSentChats", reflect.TypeOf((*MockLedger)(nil).GetSentChats), arg0, arg1, arg2)
+}
+
+// RemoveSentBefore mocks base method
+func (m *MockLedger) RemoveSentBefore(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveSentBefore", arg0)
+	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// GetLastTimestamp indicates an expected call of GetLastTimestamp
-func (mr *MockInfoMockRecorder) GetLastTimestamp() *gomock.Call {
+// RemoveSentBefore indicates an expected call of RemoveSentBefore
+func (mr *MockLedgerMockRecorder) RemoveSentBefore(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastTimestamp", reflect.TypeOf((*MockInfo)(nil).GetLastTimestamp))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveSentBefore", reflect.TypeOf((*MockLedger)(nil).RemoveSentBefore), arg0)
 }
 
-// SetLastTimestamp mocks base method
-func (m *MockInfo) SetLastTimestamp(arg0 uint64) {
+// SaveSent mocks base method
+func (m *MockLedger) SaveSent(arg0 string, arg1 []int64, arg2 uint64) error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "SetLastTimestamp", arg0)
+	ret := m.ctrl.Call(m, "SaveSent", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveSent indicates an expected call of SaveSent
+func (mr *MockLedgerMockRecorder) SaveSent(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveSent", reflect.TypeOf((*MockLedger)(nil).SaveSent), arg0, arg1, arg2)
+}
+
+// MockOutbox is a mock of Outbox interface
+type MockOutbox struct {
+	ctrl     *gomock.Controller
+	recorder *MockOutboxMockRecorder
+}
+
+// MockOutboxMockRecorder is the mock recorder for MockOutbox
+type MockOutboxMockRecorder struct {
+	mock *MockOutbox
+}
+
+// NewMockOutbox creates a new mock instance
+func NewMockOutbox(ctrl *gomock.Controller) *MockOutbox {
+	mock := &MockOutbox{ctrl: ctrl}
+	mock.recorder = &MockOutboxMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockOutbox) EXPECT() *MockOutboxMockRecorder {
+	return m.recorder
+}
+
+// AddDeliveries mocks base method
+func (m *MockOutbox) AddDeliveries(arg0 []logic.Delivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDeliveries", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDeliveries indicates an expected call of AddDeliveries
+func (mr *MockOutboxMockRecorder) AddDeliveries(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDeliveries", reflect.TypeOf((*MockOutbox)(nil).AddDeliveries), arg0)
+}
+
+// GetDueDeliveries mocks base method
+func (m *MockOutbox) GetDueDeliveries(arg0 uint64, arg1 int) ([]logic.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDueDeliveries", arg0, arg1)
+	ret0, _ := ret[0].([]logic.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDueDeliveries indicates an expected call of GetDueDeliveries
+func (mr *MockOutboxMockRecorder) GetDueDeliveries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDueDeliveries", reflect.TypeOf((*MockOutbox)(nil).GetDueDeliveries), arg0, arg1)
+}
+
+// GetQueuedChats mocks base method
+func (m *MockOutbox) GetQueuedChats(arg0 string, arg1 []int64) ([]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQueuedChats", arg0, arg1)
+	ret0, _ := ret[0].([]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQueuedChats indicates an expected call of GetQueuedChats
+func (mr *MockOutboxMockRecorder) GetQueuedChats(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQueuedChats", reflect.TypeOf((*MockOutbox)(nil).GetQueuedChats), arg0, arg1)
+}
+
+// RemoveDelivery mocks base method
+func (m *MockOutbox) RemoveDelivery(arg0 *logic.Delivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveDelivery", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveDelivery indicates an expected call of RemoveDelivery
+func (mr *MockOutboxMockRecorder) RemoveDelivery(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveDelivery", reflect.TypeOf((*MockOutbox)(nil).RemoveDelivery), arg0)
+}
+
+// SaveDelivery mocks base method
+func (m *MockOutbox) SaveDelivery(arg0 *logic.Delivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveDelivery", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveDelivery indicates an expected call of SaveDelivery
+func (mr *MockOutboxMockRecorder) SaveDelivery(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDelivery", reflect.TypeOf((*MockOutbox)(nil).SaveDelivery), arg0)
+}
+
+// MockUpload is a mock of Upload interface
+type MockUpload struct {
+	ctrl     *gomock.Controller
+	recorder *MockUploadMockRecorder
+}
+
+// MockUploadMockRecorder is the mock recorder for MockUpload
+type MockUploadMockRecorder struct {
+	mock *MockUpload
+}
+
+// NewMockUpload creates a new mock instance
+func NewMockUpload(ctrl *gomock.Controller) *MockUpload {
+	mock := &MockUpload{ctrl: ctrl}
+	mock.recorder = &MockUploadMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockUpload) EXPECT() *MockUploadMockRecorder {
+	return m.recorder
+}
+
+// GetFileID mocks base method
+func (m *MockUpload) GetFileID(arg0 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFileID", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFileID indicates an expected call of GetFileID
+func (mr *MockUploadMockRecorder) GetFileID(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFileID", reflect.TypeOf((*MockUpload)(nil).GetFileID), arg0)
+}
+
+// RemoveFileID mocks base method
+func (m *MockUpload) RemoveFileID(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveFileID", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveFileID indicates an expected call of RemoveFileID
+func (mr *MockUploadMockRecorder) RemoveFileID(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveFileID", reflect.TypeOf((*MockUpload)(nil).RemoveFileID), arg0)
+}
+
+// SaveFileID mocks base method
+func (m *MockUpload) SaveFileID(arg0, arg1 string, arg2 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveFileID", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// SetLastTimestamp indicates an expected call of SetLastTimestamp
-func (mr *MockInfoMockRecorder) SetLastTimestamp(arg0 interface{}) *gomock.Call {
+// SaveFileID indicates an expected call of SaveFileID
+func (mr *MockUploadMockRecorder) SaveFileID(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLastTimestamp", reflect.TypeOf((*MockInfo)(nil).SetLastTimestamp), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveFileID", reflect.TypeOf((*MockUpload)(nil).SaveFileID), arg0, arg1, arg2)
 }
@@ -9,6 +9,9 @@ type MockStorage struct {
 	*MockUser
 	*MockSubscription
 	*MockInfo
+	*MockLedger
+	*MockOutbox
+	*MockUpload
 }
 
 // NewMockStorage constructor for mock storage
@@ -17,5 +20,8 @@ func NewMockStorage(c *gomock.Controller) *MockStorage {
 		NewMockUser(c),
 		NewMockSubscription(c),
 		NewMockInfo(c),
+		NewMockLedger(c),
+		NewMockOutbox(c),
+		NewMockUpload(c),
 	}
 }
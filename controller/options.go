@@ -0,0 +1,305 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+)
+
+// OptionError describes invalid publication option
+type OptionError struct {
+	Option string // Option name
+	Msg    string // Error description
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("option %s: %s", e.Option, e.Msg)
+}
+
+// Option is a word like name=value
+var optionFormat = regexp.MustCompile(`^([a-z_]+)=(\S*)$`)
+
+// Parses options at the beginning of s and applies them to publication
+// Returns text after options
+func parseOptions(pub *logic.Publication, s string) (string, error) {
+	for {
+		s = strings.TrimLeft(s, " ")
+		word := s
+		if i := strings.IndexByte(s, ' '); i >= 0 {
+			word = s[:i]
+		}
+
+		option := optionFormat.FindStringSubmatch(word)
+		if option == nil {
+			return s, nil
+		}
+
+		err := setOption(pub, option[1], option[2])
+		if err != nil {
+			return "", err
+		}
+		s = s[len(word):]
+	}
+}
+
+// Sets publication option name to value
+func setOption(pub *logic.Publication, name, value string) error {
+	switch name {
+	case "mode":
+		switch value {
+		case "thread":
+			pub.PostMode = false
+		case "post":
+			pub.PostMode = true
+		default:
+			return &OptionError{Option: name, Msg: "must be thread or post"}
+		}
+	case "min_posts", "min_views":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return &OptionError{Option: name, Msg: "must be a non-negative integer"}
+		}
+		if name == "min_posts" {
+			pub.MinPosts = n
+		} else {
+			pub.MinViews = n
+		}
+	case "min_score":
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(score) {
+			return &OptionError{Option: name, Msg: "must be a number"}
+		}
+		pub.MinScore = score
+	case "max_age", "max_inactive":
+		seconds, err := parseDuration(value)
+		if errors.Is(err, errDurationRange) {
+			return &OptionError{Option: name, Msg: "must be at most " + formatDuration(maxDuration)}
+		}
+		if err != nil {
+			return &OptionError{Option: name, Msg: "must be a duration like 90m, 12h or 7d"}
+		}
+		if name == "max_age" {
+			pub.MaxAge = seconds
+		} else {
+			pub.MaxInactive = seconds
+		}
+	case "min_res", "max_res":
+		width, height, err := parseResolution(value)
+		if err != nil {
+			return &OptionError{Option: name, Msg: "must be a resolution like 1920x1080"}
+		}
+		if name == "min_res" {
+			pub.Media.MinWidth, pub.Media.MinHeight = width, height
+		} else {
+			pub.Media.MaxWidth, pub.Media.MaxHeight = width, height
+		}
+	case "min_size", "max_size":
+		size, err := parseSize(value)
+		if err != nil {
+			return &OptionError{Option: name, Msg: "must be a size like 500k or 20m"}
+		}
+		if name == "min_size" {
+			pub.Media.MinSize = size
+		} else {
+			pub.Media.MaxSize = size
+		}
+	case "min_duration", "max_duration":
+		seconds, err := parseDuration(value)
+		if errors.Is(err, errDurationRange) {
+			return &OptionError{Option: name, Msg: "must be at most " + formatDuration(maxDuration)}
+		}
+		if err != nil {
+			return &OptionError{Option: name, Msg: "must be a duration like 30s or 5m"}
+		}
+		if name == "min_duration" {
+			pub.Media.MinDuration = seconds
+		} else {
+			pub.Media.MaxDuration = seconds
+		}
+	case "min_aspect", "max_aspect":
+		ratio, err := parseRatio(value)
+		if err != nil {
+			return &OptionError{Option: name, Msg: "must be a ratio like 16:9 or 1.5"}
+		}
+		if name == "min_aspect" {
+			pub.Media.MinAspect = ratio
+		} else {
+			pub.Media.MaxAspect = ratio
+		}
+	default:
+		return &OptionError{Option: name, Msg: "unknown option"}
+	}
+
+	return nil
+}
+
+// Parses resolution like 1920x1080
+func parseResolution(s string) (int, int, error) {
+	parts := strings.SplitN(s, "x", 2)
+	if len(parts) != 2 {
+		return 0, 0, errors.New("bad resolution")
+	}
+
+	width, err := strconv.ParseUint(parts[0], 10, 31)
+	if err != nil {
+		return 0, 0, err
+	}
+	height, err := strconv.ParseUint(parts[1], 10, 31)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(width), int(height), nil
+}
+
+// Parses size like 500k or 20m into kilobytes, size without unit is in kilobytes
+func parseSize(s string) (int, error) {
+	unit := uint64(1)
+	switch {
+	case strings.HasSuffix(s, "k"):
+		s = strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		s = strings.TrimSuffix(s, "m")
+		unit = 1024
+	}
+
+	n, err := strconv.ParseUint(s, 10, 31)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n * unit), nil
+}
+
+// Parses ratio like 16:9 or 1.5
+func parseRatio(s string) (float64, error) {
+	parts := strings.SplitN(s, ":", 2)
+	ratio, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(parts) == 2 {
+		divisor, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return 0, err
+		}
+		ratio /= divisor
+	}
+
+	if math.IsNaN(ratio) || math.IsInf(ratio, 0) || ratio < 0 {
+		return 0, errors.New("bad ratio")
+	}
+
+	return ratio, nil
+}
+
+// Formats size in kilobytes with the largest exact unit
+func formatSize(size int) string {
+	if size%1024 == 0 {
+		return strconv.Itoa(size/1024) + "m"
+	}
+	return strconv.Itoa(size) + "k"
+}
+
+// Units of durations in options, in seconds
+var durationUnits = map[byte]uint64{
+	's': 1,
+	'm': 60,
+	'h': 60 * 60,
+	'd': 24 * 60 * 60,
+}
+
+// Max duration in options, about 100 years, so time arithmetic does not overflow
+const maxDuration = 36500 * 24 * 60 * 60
+
+// Returned by parseDuration if duration exceeds maxDuration
+var errDurationRange = errors.New("duration out of range")
+
+// Parses duration like 12h into seconds
+func parseDuration(s string) (uint64, error) {
+	if len(s) < 2 {
+		return 0, errors.New("bad duration")
+	}
+
+	unit, ok := durationUnits[s[len(s)-1]]
+	if !ok {
+		return 0, errors.New("bad duration")
+	}
+
+	n, err := strconv.ParseUint(s[:len(s)-1], 10, 64)
+	if errors.Is(err, strconv.ErrRange) || (err == nil && n > maxDuration/unit) {
+		return 0, errDurationRange
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return n * unit, nil
+}
+
+// Formats seconds as duration with the largest exact unit
+func formatDuration(seconds uint64) string {
+	for _, unit := range []byte{'d', 'h', 'm'} {
+		if seconds%durationUnits[unit] == 0 {
+			return strconv.FormatUint(seconds/durationUnits[unit], 10) + string(unit)
+		}
+	}
+	return strconv.FormatUint(seconds, 10) + "s"
+}
+
+// FormatOptions returns options of publication which differ from defaults
+func FormatOptions(pub *logic.Publication) string {
+	options := make([]string, 0)
+	if pub.PostMode {
+		options = append(options, "mode=post")
+	}
+	if pub.MinPosts != 0 {
+		options = append(options, fmt.Sprintf("min_posts=%d", pub.MinPosts))
+	}
+	if pub.MinViews != 0 {
+		options = append(options, fmt.Sprintf("min_views=%d", pub.MinViews))
+	}
+	if pub.MinScore != 0 {
+		options = append(options, "min_score="+strconv.FormatFloat(pub.MinScore, 'f', -1, 64))
+	}
+	if pub.MaxAge != 0 {
+		options = append(options, "max_age="+formatDuration(pub.MaxAge))
+	}
+	if pub.MaxInactive != 0 {
+		options = append(options, "max_inactive="+formatDuration(pub.MaxInactive))
+	}
+
+	media := &pub.Media
+	if media.MinWidth != 0 || media.MinHeight != 0 {
+		options = append(options, fmt.Sprintf("min_res=%dx%d", media.MinWidth, media.MinHeight))
+	}
+	if media.MaxWidth != 0 || media.MaxHeight != 0 {
+		options = append(options, fmt.Sprintf("max_res=%dx%d", media.MaxWidth, media.MaxHeight))
+	}
+	if media.MinSize != 0 {
+		options = append(options, "min_size="+formatSize(media.MinSize))
+	}
+	if media.MaxSize != 0 {
+		options = append(options, "max_size="+formatSize(media.MaxSize))
+	}
+	if media.MinDuration != 0 {
+		options = append(options, "min_duration="+formatDuration(media.MinDuration))
+	}
+	if media.MaxDuration != 0 {
+		options = append(options, "max_duration="+formatDuration(media.MaxDuration))
+	}
+	if media.MinAspect != 0 {
+		options = append(options, "min_aspect="+strconv.FormatFloat(media.MinAspect, 'g', 4, 64))
+	}
+	if media.MaxAspect != 0 {
+		options = append(options, "max_aspect="+strconv.FormatFloat(media.MaxAspect, 'g', 4, 64))
+	}
+
+	return strings.Join(options, " ")
+}
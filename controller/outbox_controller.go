@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/storage"
+)
+
+// OutboxController is an implementation of controller.Outbox
+type OutboxController struct {
+	stg         *storage.Storage
+	MaxAttempts int           // Attempts after which delivery is moved to dead letters, 0 means no limit
+	MinBackoff  time.Duration // Delay after the first failed attempt
+	MaxBackoff  time.Duration // Max delay between attempts
+	Clock       func() uint64 // Returns current unix time
+	m           sync.Mutex
+}
+
+// NewOutboxController constructor of OutboxController struct
+func NewOutboxController(stg *storage.Storage, cfg *Config) *OutboxController {
+	return &OutboxController{
+		stg:         stg,
+		MaxAttempts: cfg.OutboxAttempts,
+		MinBackoff:  cfg.OutboxMinBackoff,
+		MaxBackoff:  cfg.OutboxMaxBackoff,
+		Clock: func() uint64 {
+			return uint64(time.Now().Unix())
+		},
+	}
+}
+
+// Enqueue queues file for sending to chats
+// Key identifies file, so it is not uploaded again and not queued twice for the same chat
+func (ocon *OutboxController) Enqueue(chatIDs []int64, url, caption, key string) error {
+	ocon.m.Lock()
+	defer ocon.m.Unlock()
+
+	now := ocon.Clock()
+	queued := ocon.queuedChats(key, chatIDs)
+	deliveries := make([]logic.Delivery, 0, len(chatIDs))
+	for _, chatID := range chatIDs {
+		if queued[chatID] {
+			continue
+		}
+		deliveries = append(deliveries, logic.Delivery{
+			ChatID:        chatID,
+			URL:           url,
+			Key:           key,
+			Caption:       caption,
+			Status:        logic.DeliveryPending,
+			NextAttemptAt: now,
+			QueuedAt:      now,
+		})
+	}
+
+	return ocon.stg.AddDeliveries(deliveries)
+}
+
+// EnqueueAlbum queues files for sending to chats as album
+// Album is identified by time it was queued and url of its first file
+// Files already waiting to be sent to chat are left out of its album
+func (ocon *OutboxController) EnqueueAlbum(chatIDs []int64, files []logic.File, caption string) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	ocon.m.Lock()
+	defer ocon.m.Unlock()
+
+	queued := make(map[string]map[int64]bool)
+	for _, file := range files {
+		if _, ok := queued[file.Key]; !ok {
+			queued[file.Key] = ocon.queuedChats(file.Key, chatIDs)
+		}
+	}
+
+	now := ocon.Clock()
+	album := fmt.Sprintf("%d:%s", now, files[0].URL)
+	deliveries := make([]logic.Delivery, 0, len(chatIDs)*len(files))
+	for _, chatID := range chatIDs {
+		for _, file := range files {
+			if queued[file.Key][chatID] {
+				continue
+			}
+			if file.Key != "" {
+				queued[file.Key][chatID] = true
+			}
+			deliveries = append(deliveries, logic.Delivery{
+				ChatID:        chatID,
+				URL:           file.URL,
+				Key:           file.Key,
+				Caption:       caption,
+				Album:         album,
+				Status:        logic.DeliveryPending,
+				NextAttemptAt: now,
+				QueuedAt:      now,
+			})
+		}
+	}
+
+	return ocon.stg.AddDeliveries(deliveries)
+}
+
+// Returns chats which file with key is waiting to be sent to
+// File without key is never considered queued, file is queued again if queue is unavailable
+func (ocon *OutboxController) queuedChats(key string, chatIDs []int64) map[int64]bool {
+	queued := make(map[int64]bool)
+	if key == "" {
+		return queued
+	}
+
+	chats, err := ocon.stg.GetQueuedChats(key, chatIDs)
+	if err != nil {
+		log.Println("OutboxController.queuedChats-GetQueuedChats", err)
+	}
+	for _, chatID := range chats {
+		queued[chatID] = true
+	}
+	return queued
+}
+
+// GetDueDeliveries returns up to limit deliveries which should be sent now
+func (ocon *OutboxController) GetDueDeliveries(limit int) ([]logic.Delivery, error) {
+	return ocon.stg.GetDueDeliveries(ocon.Clock(), limit)
+}
+
+// CompleteDelivery records file as sent to chat and removes delivery from queue
+// Delivery is removed even if ledger is unavailable, so file is not sent twice
+func (ocon *OutboxController) CompleteDelivery(delivery *logic.Delivery) error {
+	if delivery.Key != "" {
+		err := ocon.stg.SaveSent(delivery.Key, []int64{delivery.ChatID}, ocon.Clock())
+		if err != nil {
+			log.Println("OutboxController.CompleteDelivery-SaveSent", err)
+		}
+	}
+
+	return ocon.stg.RemoveDelivery(delivery)
+}
+
+// FailDelivery schedules the next attempt of delivery with exponential backoff
+// Delivery is moved to dead letters if error is permanent or attempts are exhausted
+func (ocon *OutboxController) FailDelivery(delivery *logic.Delivery, cause error, permanent bool) error {
+	delivery.Attempts++
+	delivery.LastError = cause.Error()
+
+	if permanent || (ocon.MaxAttempts > 0 && delivery.Attempts >= ocon.MaxAttempts) {
+		log.Printf("Delivery of %s to %d failed after %d attempts: %s",
+			delivery.URL, delivery.ChatID, delivery.Attempts, delivery.LastError)
+		delivery.Status = logic.DeliveryDead
+	} else {
+		delivery.NextAttemptAt = ocon.Clock() + uint64(ocon.backoff(delivery.Attempts)/time.Second)
+	}
+
+	return ocon.stg.SaveDelivery(delivery)
+}
+
+// Returns delay before attempt following failed one
+func (ocon *OutboxController) backoff(attempts int) time.Duration {
+	delay := ocon.MinBackoff
+	for i := 1; i < attempts && (ocon.MaxBackoff <= 0 || delay < ocon.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if ocon.MaxBackoff > 0 && delay > ocon.MaxBackoff {
+		delay = ocon.MaxBackoff
+	}
+	return delay
+}
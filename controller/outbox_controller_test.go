@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	mock_storage "github.com/aoyako/telegram_2ch_res_bot/controller/mock"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxController_Enqueue(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_storage.NewMockStorage(ctrl)
+	m.MockOutbox.
+		EXPECT().
+		GetQueuedChats("md5:abc", []int64{10, 20, 30}).
+		Return([]int64{30}, nil)
+	m.MockOutbox.
+		EXPECT().
+		AddDeliveries(gomock.Eq([]logic.Delivery{
+			{ChatID: 10, URL: "/a.png", Key: "md5:abc", Caption: "1", Status: logic.DeliveryPending, NextAttemptAt: 5000, QueuedAt: 5000},
+			{ChatID: 20, URL: "/a.png", Key: "md5:abc", Caption: "1", Status: logic.DeliveryPending, NextAttemptAt: 5000, QueuedAt: 5000},
+		})).
+		Return(nil)
+
+	ocon := NewOutboxController(&storage.Storage{
+		Outbox: m.MockOutbox,
+	}, &Config{})
+	ocon.Clock = func() uint64 { return 5000 }
+
+	// File which is already waiting to be sent to chat is not queued again
+	assert.Nil(ocon.Enqueue([]int64{10, 20, 30}, "/a.png", "1", "md5:abc"))
+}
+
+func TestOutboxController_EnqueueAlbum(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_storage.NewMockStorage(ctrl)
+	m.MockOutbox.
+		EXPECT().
+		GetQueuedChats("md5:a", []int64{10, 20}).
+		Return([]int64{}, nil)
+	m.MockOutbox.
+		EXPECT().
+		GetQueuedChats("md5:b", []int64{10, 20}).
+		Return([]int64{20}, nil)
+	m.MockOutbox.
+		EXPECT().
+		AddDeliveries(gomock.Eq([]logic.Delivery{
+			{ChatID: 10, URL: "/a.png", Key: "md5:a", Caption: "1", Album: "5000:/a.png", Status: logic.DeliveryPending, NextAttemptAt: 5000, QueuedAt: 5000},
+			{ChatID: 10, URL: "/b.png", Key: "md5:b", Caption: "1", Album: "5000:/a.png", Status: logic.DeliveryPending, NextAttemptAt: 5000, QueuedAt: 5000},
+			{ChatID: 20, URL: "/a.png", Key: "md5:a", Caption: "1", Album: "5000:/a.png", Status: logic.DeliveryPending, NextAttemptAt: 5000, QueuedAt: 5000},
+		})).
+		Return(nil)
+
+	ocon := NewOutboxController(&storage.Storage{
+		Outbox: m.MockOutbox,
+	}, &Config{})
+	ocon.Clock = func() uint64 { return 5000 }
+
+	// Repost of the first file in the same album is queued once
+	files := []logic.File{{URL: "/a.png", Key: "md5:a"}, {URL: "/b.png", Key: "md5:b"}, {URL: "/c.png", Key: "md5:a"}}
+	assert.Nil(ocon.EnqueueAlbum([]int64{10, 20}, files, "1"))
+}
+
+func TestOutboxController_CompleteDelivery(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_storage.NewMockStorage(ctrl)
+	delivery := &logic.Delivery{ID: 1, ChatID: 10, URL: "/a.png", Key: "md5:abc"}
+	gomock.InOrder(
+		m.MockLedger.EXPECT().SaveSent("md5:abc", []int64{10}, uint64(5000)).Return(errors.New("error")),
+		m.MockOutbox.EXPECT().RemoveDelivery(delivery).Return(nil),
+	)
+
+	ocon := NewOutboxController(&storage.Storage{
+		Ledger: m.MockLedger,
+		Outbox: m.MockOutbox,
+	}, &Config{})
+	ocon.Clock = func() uint64 { return 5000 }
+
+	// Delivery is removed even if ledger fails
+	assert.Nil(ocon.CompleteDelivery(delivery))
+}
+
+func TestOutboxController_FailDelivery(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name      string
+		attempts  int
+		permanent bool
+		want      logic.Delivery
+	}{
+		{
+			name:     "First retry",
+			attempts: 0,
+			want:     logic.Delivery{ID: 1, Status: logic.DeliveryPending, Attempts: 1, NextAttemptAt: 5010, LastError: "error"},
+		},
+		{
+			name:     "Backoff is doubled",
+			attempts: 2,
+			want:     logic.Delivery{ID: 1, Status: logic.DeliveryPending, Attempts: 3, NextAttemptAt: 5040, LastError: "error"},
+		},
+		{
+			name:     "Backoff is limited",
+			attempts: 3,
+			want:     logic.Delivery{ID: 1, Status: logic.DeliveryPending, Attempts: 4, NextAttemptAt: 5060, LastError: "error"},
+		},
+		{
+			name:     "Attempts are exhausted",
+			attempts: 4,
+			want:     logic.Delivery{ID: 1, Status: logic.DeliveryDead, Attempts: 5, LastError: "error"},
+		},
+		{
+			name:      "Permanent error",
+			attempts:  0,
+			permanent: true,
+			want:      logic.Delivery{ID: 1, Status: logic.DeliveryDead, Attempts: 1, LastError: "error"},
+		},
+	}
+
+	for _, tt := range tests {
+		m := mock_storage.NewMockStorage(ctrl)
+		m.MockOutbox.
+			EXPECT().
+			SaveDelivery(gomock.Eq(&tt.want)).
+			Return(nil)
+
+		ocon := NewOutboxController(&storage.Storage{
+			Outbox: m.MockOutbox,
+		}, &Config{
+			OutboxAttempts:   5,
+			OutboxMinBackoff: 10 * time.Second,
+			OutboxMaxBackoff: time.Minute,
+		})
+		ocon.Clock = func() uint64 { return 5000 }
+
+		delivery := &logic.Delivery{ID: 1, Status: logic.DeliveryPending, Attempts: tt.attempts}
+		assert.Nil(ocon.FailDelivery(delivery, errors.New("error"), tt.permanent), tt.name)
+		assert.Equal(tt.want, *delivery, tt.name)
+	}
+}
@@ -8,18 +8,23 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/aoyako/telegram_2ch_res_bot/filter"
 	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/media"
 	"github.com/aoyako/telegram_2ch_res_bot/storage"
 )
 
 // SubscriptionController is an implementation of controller.Subscription
 type SubscriptionController struct {
-	stg *storage.Storage
+	stg    *storage.Storage
+	boards Board
+	Media  *media.Registry // Known file type groups, types of new publications are not checked if nil
 }
 
 // NewSubscriptionController constructor of SubscriptionController struct
-func NewSubscriptionController(stg *storage.Storage) *SubscriptionController {
-	return &SubscriptionController{stg: stg}
+// Boards of new publications are not checked if boards is nil
+func NewSubscriptionController(stg *storage.Storage, boards Board) *SubscriptionController {
+	return &SubscriptionController{stg: stg, boards: boards}
 }
 
 // AddNew creates a subscription to user with publication
@@ -30,7 +35,7 @@ func (scon *SubscriptionController) AddNew(chatID int64, request string) error {
 		return err
 	}
 
-	publication, err := parseRequest(request)
+	publication, err := parseRequest(request, scon.boards, scon.Media)
 	if err != nil {
 		log.Println("SubscriptionController.AddNew-parseRequest", err)
 		return err
@@ -52,7 +57,7 @@ func (scon *SubscriptionController) Create(chatID int64, request string) error {
 	if !scon.stg.IsChatAdmin(chatID) {
 		return errors.New("access denied")
 	}
-	publication, err := parseRequestAlias(request)
+	publication, err := parseRequestAlias(request, scon.boards, scon.Media)
 	if err != nil {
 		log.Println("SubscriptionController.Create-parseRequestAlias", err)
 		return err
@@ -206,8 +211,9 @@ func (scon *SubscriptionController) GetAllDefaultSubs() []logic.Publication {
 }
 
 // Parses request string
-// Request string format: "board_name {.img | .webm | .gif} "keyword1"[|,&]..."
-func parseRequest(req string) (*logic.Publication, error) {
+// Request string format: "[source/]board_name {.img | .webm | .gif} [option=value]... "keyword1"[|,&]..."
+// Board is checked with boards if it is not nil
+func parseRequest(req string, boards Board, types *media.Registry) (*logic.Publication, error) {
 	separator := regexp.MustCompile(` `)
 	args := separator.Split(req, 3)
 	if len(args) != 3 {
@@ -215,59 +221,132 @@ func parseRequest(req string) (*logic.Publication, error) {
 		return nil, errors.New("bad request")
 	}
 
-	tags := args[2]
-	res, err := regexp.MatchString(`^(!?".+"[|&])*!?"[^&|]+"$`, tags)
-
+	res, err := regexp.MatchString(`^(\.[A-Za-z0-9]+)+$`, args[1])
 	if err != nil || !res {
 		log.Println("parseRequest - error", args)
 		return nil, errors.New("bad request")
 	}
+	if err := checkTypes(args[1], types); err != nil {
+		log.Println("parseRequest - error", args, err)
+		return nil, err
+	}
 
-	types := args[1]
-	res, err = regexp.MatchString(`^(\.[A-Za-z0-9]+)+$`, types)
-	if err != nil || !res {
-		log.Println("parseRequest - error", args)
-		return nil, errors.New("bad request")
+	source, board := parseBoard(args[0])
+	if boards != nil {
+		if err := boards.CheckBoard(source, board); err != nil {
+			log.Println("parseRequest - error", args, err)
+			return nil, err
+		}
+	}
+
+	publication := &logic.Publication{
+		Source: source,
+		Board:  board,
+		Type:   args[1],
+	}
+
+	tags, err := parseOptions(publication, args[2])
+	if err != nil {
+		log.Println("parseRequest - error", args, err)
+		return nil, err
 	}
 
-	return &logic.Publication{
-		Board: args[0],
-		Tags:  args[2],
-		Type:  args[1],
-	}, nil
+	tags = strings.TrimSpace(tags)
+	_, err = filter.Parse(tags)
+	if err != nil {
+		log.Println("parseRequest - error", args, err)
+		return nil, err
+	}
+	publication.Tags = tags
+
+	return publication, nil
 }
 
 // Parses request string with alias
-func parseRequestAlias(req string) (*logic.Publication, error) {
+// Alias is the text after tags, tags are used as alias if it is missing
+// Board is checked with boards if it is not nil
+func parseRequestAlias(req string, boards Board, types *media.Registry) (*logic.Publication, error) {
 	separator := regexp.MustCompile(` `)
 	args := separator.Split(req, 3)
-
-	parser := regexp.MustCompile(`" `)
-	words := parser.Split(args[len(args)-1], -1)
-
 	if len(args) != 3 {
 		log.Println("parseRequestAlias - error", args)
 		return nil, errors.New("bad request")
 	}
 
-	tags := strings.TrimSuffix(args[2], " "+words[len(words)-1])
-	res, err := regexp.MatchString(`^(!?".+"[|&])*!?"[^&|]+"$`, tags)
+	res, err := regexp.MatchString(`^(\.[A-Za-z0-9]+)+$`, args[1])
 	if err != nil || !res {
 		log.Println("parseRequestAlias - error", args)
 		return nil, errors.New("bad request")
 	}
+	if err := checkTypes(args[1], types); err != nil {
+		log.Println("parseRequestAlias - error", args, err)
+		return nil, err
+	}
 
-	types := args[1]
-	res, err = regexp.MatchString(`^(\.[A-Za-z0-9]+)+$`, types)
-	if err != nil || !res {
-		log.Println("parseRequestAlias - error", args)
-		return nil, errors.New("bad request")
+	source, board := parseBoard(args[0])
+	if boards != nil {
+		if err := boards.CheckBoard(source, board); err != nil {
+			log.Println("parseRequestAlias - error", args, err)
+			return nil, err
+		}
+	}
+
+	publication := &logic.Publication{
+		Source: source,
+		Board:  board,
+		Type:   args[1],
+	}
+
+	text, err := parseOptions(publication, args[2])
+	if err != nil {
+		log.Println("parseRequestAlias - error", args, err)
+		return nil, err
+	}
+
+	_, alias, err := filter.ParsePrefix(text)
+	if err != nil {
+		log.Println("parseRequestAlias - error", args, err)
+		return nil, err
+	}
+
+	// Tags are checked by the same parser which matches them later
+	tags := strings.TrimSpace(strings.TrimSuffix(text, alias))
+	_, err = filter.Parse(tags)
+	if err != nil {
+		log.Println("parseRequestAlias - error", args, err)
+		return nil, err
+	}
+	if alias == "" {
+		alias = tags
+	}
+	publication.Tags = tags
+	publication.Alias = alias
+
+	return publication, nil
+}
+
+// Returns OptionError if type group, e.g. "img" of ".img.webm", is not known by registry
+// Any groups are accepted if registry is nil
+func checkTypes(types string, registry *media.Registry) error {
+	if registry == nil {
+		return nil
+	}
+
+	for _, group := range strings.Split(strings.TrimPrefix(types, "."), ".") {
+		if !registry.HasGroup(group) {
+			return &OptionError{Option: "type", Msg: fmt.Sprintf("unknown file type %s", group)}
+		}
+	}
+	return nil
+}
+
+// Parses board string formatted as "[source/]board"
+// Source is empty if not specified
+func parseBoard(s string) (string, string) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) == 1 {
+		return "", parts[0]
 	}
 
-	return &logic.Publication{
-		Board: args[0],
-		Tags:  tags,
-		Type:  types,
-		Alias: words[len(words)-1],
-	}, nil
+	return parts[0], parts[1]
 }
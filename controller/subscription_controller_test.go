@@ -5,7 +5,9 @@ import (
 	"testing"
 
 	mock_storage "github.com/aoyako/telegram_2ch_res_bot/controller/mock"
+	"github.com/aoyako/telegram_2ch_res_bot/filter"
 	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/media"
 	"github.com/aoyako/telegram_2ch_res_bot/storage"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -73,7 +75,7 @@ func TestSubscriptionController_AddNew(t *testing.T) {
 			Info:         m.MockInfo,
 			User:         m.MockUser,
 			Subscription: m.MockSubscription,
-		})
+		}, nil)
 
 		m.MockUser.
 			EXPECT().
@@ -149,7 +151,7 @@ func TestSubscriptionController_Create(t *testing.T) {
 			Info:         m.MockInfo,
 			User:         m.MockUser,
 			Subscription: m.MockSubscription,
-		})
+		}, nil)
 
 		m.MockUser.
 			EXPECT().
@@ -242,7 +244,7 @@ func TestSubscriptionController_Subscribe(t *testing.T) {
 			Info:         m.MockInfo,
 			User:         m.MockUser,
 			Subscription: m.MockSubscription,
-		})
+		}, nil)
 
 		m.MockUser.
 			EXPECT().
@@ -347,7 +349,7 @@ func TestSubscriptionController_Remove(t *testing.T) {
 			Info:         m.MockInfo,
 			User:         m.MockUser,
 			Subscription: m.MockSubscription,
-		})
+		}, nil)
 
 		m.MockUser.
 			EXPECT().
@@ -473,7 +475,7 @@ func TestSubscriptionController_RemoveDefault(t *testing.T) {
 			Info:         m.MockInfo,
 			User:         m.MockUser,
 			Subscription: m.MockSubscription,
-		})
+		}, nil)
 
 		m.MockUser.
 			EXPECT().
@@ -559,7 +561,7 @@ func TestSubscriptionController_GetSubsByChatID(t *testing.T) {
 			Info:         m.MockInfo,
 			User:         m.MockUser,
 			Subscription: m.MockSubscription,
-		})
+		}, nil)
 
 		user := &logic.User{ID: 1}
 
@@ -608,7 +610,7 @@ func TestSubscriptionController_GetAllSubs(t *testing.T) {
 			Info:         m.MockInfo,
 			User:         m.MockUser,
 			Subscription: m.MockSubscription,
-		})
+		}, nil)
 
 		m.MockSubscription.
 			EXPECT().
@@ -651,7 +653,7 @@ func TestSubscriptionController_GetAllDefaultSubs(t *testing.T) {
 			Info:         m.MockInfo,
 			User:         m.MockUser,
 			Subscription: m.MockSubscription,
-		})
+		}, nil)
 
 		m.MockSubscription.
 			EXPECT().
@@ -702,6 +704,115 @@ func Test_parseRequest(t *testing.T) {
 				Tags:  "\"C\"|\"De\"&\"F\"|!\"G\"",
 			},
 		},
+		{
+			name:      "Normal with source",
+			request:   "4chan/a .b \"C\"",
+			wantError: nil,
+			wantPublication: &logic.Publication{
+				Source: "4chan",
+				Board:  "a",
+				Type:   ".b",
+				Tags:   "\"C\"",
+			},
+		},
+		{
+			name:      "Grouped tags",
+			request:   "a .b (\"C\" | \"D\") & !\"E \\\" F\"",
+			wantError: nil,
+			wantPublication: &logic.Publication{
+				Board: "a",
+				Type:  ".b",
+				Tags:  "(\"C\" | \"D\") & !\"E \\\" F\"",
+			},
+		},
+		{
+			name:      "Post mode",
+			request:   "a .b mode=post  mode=thread mode=post \"C\"",
+			wantError: nil,
+			wantPublication: &logic.Publication{
+				Board:    "a",
+				Type:     ".b",
+				Tags:     "\"C\"",
+				PostMode: true,
+			},
+		},
+		{
+			name:      "Thresholds",
+			request:   "a .b min_posts=10 min_views=500 min_score=2.5 max_age=7d max_inactive=90m \"C\"",
+			wantError: nil,
+			wantPublication: &logic.Publication{
+				Board:       "a",
+				Type:        ".b",
+				Tags:        "\"C\"",
+				MinPosts:    10,
+				MinViews:    500,
+				MinScore:    2.5,
+				MaxAge:      7 * 24 * 60 * 60,
+				MaxInactive: 90 * 60,
+			},
+		},
+		{
+			name:      "Media limits",
+			request:   "a .b min_res=1920x1080 max_res=3840x2160 min_size=100 max_size=20m min_duration=10s max_duration=5m min_aspect=16:9 max_aspect=2.5 \"C\"",
+			wantError: nil,
+			wantPublication: &logic.Publication{
+				Board: "a",
+				Type:  ".b",
+				Tags:  "\"C\"",
+				Media: logic.MediaLimits{
+					MinWidth:    1920,
+					MinHeight:   1080,
+					MaxWidth:    3840,
+					MaxHeight:   2160,
+					MinSize:     100,
+					MaxSize:     20 * 1024,
+					MinDuration: 10,
+					MaxDuration: 5 * 60,
+					MinAspect:   16.0 / 9.0,
+					MaxAspect:   2.5,
+				},
+			},
+		},
+		{
+			name:      "Bad resolution",
+			request:   "a .b min_res=1920 \"C\"",
+			wantError: &OptionError{Option: "min_res", Msg: "must be a resolution like 1920x1080"},
+		},
+		{
+			name:      "Bad threshold",
+			request:   "a .b min_posts=-1 \"C\"",
+			wantError: &OptionError{Option: "min_posts", Msg: "must be a non-negative integer"},
+		},
+		{
+			name:      "Bad duration",
+			request:   "a .b max_age=1w \"C\"",
+			wantError: &OptionError{Option: "max_age", Msg: "must be a duration like 90m, 12h or 7d"},
+		},
+		{
+			name:      "Duration out of range",
+			request:   "a .b max_age=18446744073709551615d \"C\"",
+			wantError: &OptionError{Option: "max_age", Msg: "must be at most 36500d"},
+		},
+		{
+			name:      "Duration overflow",
+			request:   "a .b max_duration=99999999999999999999s \"C\"",
+			wantError: &OptionError{Option: "max_duration", Msg: "must be at most 36500d"},
+		},
+		{
+			name:      "Unknown option",
+			request:   "a .b size=10 \"C\"",
+			wantError: &OptionError{Option: "size", Msg: "unknown option"},
+		},
+		{
+			name:      "Bad mode",
+			request:   "a .b mode=all \"C\"",
+			wantError: &OptionError{Option: "mode", Msg: "must be thread or post"},
+		},
+		{
+			name:      "Unclosed parenthesis",
+			request:   "a .b (\"C\"|\"D\"",
+			wantError: &filter.SyntaxError{Pos: 1, Msg: "unclosed parenthesis"},
+		},
 		{
 			name:      "No tags",
 			request:   "a .b",
@@ -720,7 +831,7 @@ func Test_parseRequest(t *testing.T) {
 		{
 			name:      "Empty tags",
 			request:   "a .b \"\"",
-			wantError: errors.New("bad request"),
+			wantError: &filter.SyntaxError{Pos: 1, Msg: "empty keyword"},
 		},
 		{
 			name:      "Wrong type",
@@ -730,12 +841,27 @@ func Test_parseRequest(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		res, err := parseRequest(tt.request)
+		res, err := parseRequest(tt.request, nil, nil)
 		assert.Equal(tt.wantPublication, res)
 		assert.Equal(tt.wantError, err)
 	}
 }
 
+func Test_parseRequestTypes(t *testing.T) {
+	assert := assert.New(t)
+	registry := media.Default()
+
+	pub, err := parseRequest("a .img.webm \"C\"", nil, registry)
+	assert.Nil(err)
+	assert.Equal(".img.webm", pub.Type)
+
+	_, err = parseRequest("a .imgg \"C\"", nil, registry)
+	assert.Equal(&OptionError{Option: "type", Msg: "unknown file type imgg"}, err)
+
+	_, err = parseRequestAlias("a .img.zip \"C\" Default", nil, registry)
+	assert.Equal(&OptionError{Option: "type", Msg: "unknown file type zip"}, err)
+}
+
 func Test_parseRequestAlias(t *testing.T) {
 	assert := assert.New(t)
 
@@ -778,10 +904,45 @@ func Test_parseRequestAlias(t *testing.T) {
 				Alias: "\"C\"|\"De\"&\"F\"|!\"G\"",
 			},
 		},
+		{
+			name:      "Normal with source",
+			request:   "4chan/a .b \"C\" Default",
+			wantError: nil,
+			wantPublication: &logic.Publication{
+				Source: "4chan",
+				Board:  "a",
+				Type:   ".b",
+				Tags:   "\"C\"",
+				Alias:  "Default",
+			},
+		},
+		{
+			name:      "Grouped tags",
+			request:   "a .b (\"C\"|\"D\")&!\"E\" Default name",
+			wantError: nil,
+			wantPublication: &logic.Publication{
+				Board: "a",
+				Type:  ".b",
+				Tags:  "(\"C\"|\"D\")&!\"E\"",
+				Alias: "Default name",
+			},
+		},
+		{
+			name:      "Post mode",
+			request:   "a .b mode=post \"C\" Default",
+			wantError: nil,
+			wantPublication: &logic.Publication{
+				Board:    "a",
+				Type:     ".b",
+				Tags:     "\"C\"",
+				Alias:    "Default",
+				PostMode: true,
+			},
+		},
 		{
 			name:      "No tags",
 			request:   "a .b Default",
-			wantError: errors.New("bad request"),
+			wantError: &filter.SyntaxError{Pos: 1, Msg: "unexpected 'D'"},
 		},
 		{
 			name:      "No formats",
@@ -796,7 +957,7 @@ func Test_parseRequestAlias(t *testing.T) {
 		{
 			name:      "Empty tags",
 			request:   "a .b \"\" Default",
-			wantError: errors.New("bad request"),
+			wantError: &filter.SyntaxError{Pos: 1, Msg: "empty keyword"},
 		},
 		{
 			name:      "Wrong type",
@@ -806,7 +967,7 @@ func Test_parseRequestAlias(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		res, err := parseRequestAlias(tt.request)
+		res, err := parseRequestAlias(tt.request, nil, nil)
 		assert.Equal(tt.wantPublication, res)
 		assert.Equal(tt.wantError, err)
 	}
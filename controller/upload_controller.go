@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/aoyako/telegram_2ch_res_bot/storage"
+)
+
+// UploadController is an implementation of controller.Upload
+type UploadController struct {
+	stg   *storage.Storage
+	Clock func() uint64 // Returns current unix time
+}
+
+// NewUploadController constructor of UploadController struct
+func NewUploadController(stg *storage.Storage) *UploadController {
+	return &UploadController{
+		stg: stg,
+		Clock: func() uint64 {
+			return uint64(time.Now().Unix())
+		},
+	}
+}
+
+// GetFileID returns telegram file id of file with key, empty string if file was not uploaded
+func (ucon *UploadController) GetFileID(key string) (string, error) {
+	return ucon.stg.GetFileID(key)
+}
+
+// SaveFileID records file id of file with key
+func (ucon *UploadController) SaveFileID(key, fileID string) error {
+	return ucon.stg.SaveFileID(key, fileID, ucon.Clock())
+}
+
+// ForgetFileID forgets file id of file with key, file will be uploaded again
+func (ucon *UploadController) ForgetFileID(key string) error {
+	return ucon.stg.RemoveFileID(key)
+}
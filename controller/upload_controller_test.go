@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"testing"
+
+	mock_storage "github.com/aoyako/telegram_2ch_res_bot/controller/mock"
+	"github.com/aoyako/telegram_2ch_res_bot/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadController(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_storage.NewMockStorage(ctrl)
+	m.MockUpload.
+		EXPECT().
+		GetFileID(gomock.Eq("md5:abc")).
+		Return("AgAD", nil)
+	m.MockUpload.
+		EXPECT().
+		SaveFileID(gomock.Eq("md5:abc"), gomock.Eq("AgAD"), gomock.Eq(uint64(5000))).
+		Return(nil)
+	m.MockUpload.
+		EXPECT().
+		RemoveFileID(gomock.Eq("md5:abc")).
+		Return(nil)
+
+	ucon := NewUploadController(&storage.Storage{
+		Upload: m.MockUpload,
+	})
+	ucon.Clock = func() uint64 { return 5000 }
+
+	fileID, err := ucon.GetFileID("md5:abc")
+	assert.Nil(err)
+	assert.Equal("AgAD", fileID)
+	assert.Nil(ucon.SaveFileID("md5:abc", "AgAD"))
+	assert.Nil(ucon.ForgetFileID("md5:abc"))
+}
@@ -1,13 +1,16 @@
 package dvach
 
 import (
+	"context"
+
 	"github.com/aoyako/telegram_2ch_res_bot/controller"
 	"github.com/aoyako/telegram_2ch_res_bot/telegram"
 )
 
 // APIWorker for working with external api
 type APIWorker interface {
-	InitiateSending()
+	InitiateSending(ctx context.Context)
+	PollBoards(ctx context.Context, due func(board string) bool) (map[string]Activity, error)
 }
 
 // APIController for accessing external api
@@ -35,6 +38,7 @@ type ListResponse struct {
 
 // Post contains post data
 type Post struct {
+	Num       uint64 `json:"num"` // Post number, grows within board
 	Comment   string `json:"comment"`
 	Date      string `json:"date"`
 	Timestamp uint64 `json:"timestamp"`
@@ -43,9 +47,15 @@ type Post struct {
 
 // File constains file data
 type File struct {
-	Name string `json:"name"`
-	Path string `json:"path"`
-	Size int    `json:"size"`
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Size      int    `json:"size"` // Size in kilobytes
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Duration  uint64 `json:"duration_secs"` // Video duration in seconds
+	MD5       string `json:"md5"`           // Hex encoded md5 of file
+	Thumbnail string `json:"thumbnail"`     // Path of preview
+	Type      int    `json:"type"`          // 2ch file type code
 }
 
 // ThreadPost stores info about thread's posts
@@ -53,14 +63,25 @@ type ThreadPost struct {
 	Posts []Post `json:"posts"`
 }
 
+// PostsResponse contains posts of thread after given number
+type PostsResponse struct {
+	Posts []Post `json:"posts"`
+}
+
+// Board describes board in list of boards
+type Board struct {
+	ID   string `json:"id"`   // Board name used in requests
+	Name string `json:"name"` // Human readable board title
+}
+
 // ThreadData contains every thread data
 type ThreadData struct {
 	ThreadPosts []ThreadPost `json:"threads"`
 }
 
 // NewAPIController constructor of APIController
-func NewAPIController(cnt *controller.Controller, snd telegram.Sender, req Requester) *APIController {
+func NewAPIController(cnt *controller.Controller, snd telegram.Sender, sources map[string]Source) *APIController {
 	return &APIController{
-		APIWorker: NewAPIWorkerDvach(cnt, snd, req),
+		APIWorker: NewAPIWorkerDvach(cnt, snd, sources),
 	}
 }
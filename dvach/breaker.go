@@ -0,0 +1,128 @@
+package dvach
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// CircuitError is returned when requests to host are stopped after repeated failures
+type CircuitError struct {
+	Host string
+}
+
+func (e *CircuitError) Error() string {
+	return fmt.Sprintf("requests to %s are paused after repeated failures", e.Host)
+}
+
+// Breaker stops requests to host after repeated failures
+// When cooldown passes, single request is allowed to probe whether host recovered
+type Breaker struct {
+	Threshold int               // Consecutive failures after which host is paused, 0 disables breaker
+	Cooldown  time.Duration     // Pause before probe request
+	Notify    func(text string) // Receives pause and resume of hosts, called on fetch path so it should not block
+	Now       func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*circuit
+}
+
+// State of single host
+type circuit struct {
+	failures int
+	open     bool      // Requests are paused
+	openedAt time.Time // Time of the latest failure which paused requests
+	probing  bool      // Probe request is running
+}
+
+// NewBreaker constructor for Breaker
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		Now:       time.Now,
+		hosts:     make(map[string]*circuit),
+	}
+}
+
+// Allow returns error if requests to host are paused
+func (b *Breaker) Allow(host string) error {
+	if b.Threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(host)
+	if !c.open {
+		return nil
+	}
+	if c.probing || b.Now().Sub(c.openedAt) < b.Cooldown {
+		return &CircuitError{Host: host}
+	}
+
+	c.probing = true
+	return nil
+}
+
+// Report records result of request to host
+func (b *Breaker) Report(host string, failed bool) {
+	if b.Threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	c := b.circuit(host)
+	wasOpen := c.open
+	c.probing = false
+	if failed {
+		c.failures++
+		if c.failures >= b.Threshold {
+			c.open = true
+			c.openedAt = b.Now()
+		}
+	} else {
+		c.failures = 0
+		c.open = false
+	}
+	isOpen := c.open
+	b.mu.Unlock()
+
+	switch {
+	case !wasOpen && isOpen:
+		b.notify(fmt.Sprintf("Requests to %s are paused for %s after %d failures", host, b.Cooldown, b.Threshold))
+	case wasOpen && !isOpen:
+		b.notify(fmt.Sprintf("Requests to %s are resumed", host))
+	}
+}
+
+// Abort records that request allowed for host was not performed
+func (b *Breaker) Abort(host string) {
+	if b.Threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.circuit(host).probing = false
+}
+
+// Returns state of host, creates it if missing
+func (b *Breaker) circuit(host string) *circuit {
+	c, ok := b.hosts[host]
+	if !ok {
+		c = &circuit{}
+		b.hosts[host] = c
+	}
+	return c
+}
+
+// Logs text and sends it to Notify
+func (b *Breaker) notify(text string) {
+	log.Println(text)
+	if b.Notify != nil {
+		b.Notify(text)
+	}
+}
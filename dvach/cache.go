@@ -0,0 +1,140 @@
+package dvach
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNotModified is returned with cached data when server responds that document did not change
+var ErrNotModified = errors.New("not modified")
+
+// CacheEntry stores validators and body of response
+type CacheEntry struct {
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+	Body         []byte `json:"body"`
+}
+
+// CacheStats counts conditional requests
+type CacheStats struct {
+	Hits   uint64 // Responses served from cache after 304
+	Misses uint64 // Responses downloaded in full
+}
+
+// ResponseCache keeps responses of the latest requests by url
+// The least recently used entries are dropped when size is exceeded
+type ResponseCache struct {
+	Size int    // Max amount of entries, 0 means no limit
+	Dir  string // Directory entries are also saved to, empty if cache is in memory only
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Urls, the most recently used first
+	stats   CacheStats
+}
+
+// Element of ResponseCache.order
+type cacheItem struct {
+	url   string
+	entry *CacheEntry
+}
+
+// NewResponseCache constructor for ResponseCache
+func NewResponseCache(size int, dir string) *ResponseCache {
+	return &ResponseCache{
+		Size:    size,
+		Dir:     dir,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Get returns entry of url, nil if it is not cached
+func (c *ResponseCache) Get(url string) *CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[url]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*cacheItem).entry
+	}
+
+	if c.Dir == "" {
+		return nil
+	}
+	var entry CacheEntry
+	if err := readFixture(c.path(url), &entry); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading cached response of %s: %s", url, err.Error())
+		}
+		return nil
+	}
+	c.add(url, &entry)
+	return &entry
+}
+
+// Put saves entry of url
+func (c *ResponseCache) Put(url string, entry *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[url]; ok {
+		elem.Value.(*cacheItem).entry = entry
+		c.order.MoveToFront(elem)
+	} else {
+		c.add(url, entry)
+	}
+
+	if c.Dir == "" {
+		return
+	}
+	if err := writeFixture(c.path(url), entry); err != nil {
+		log.Printf("Error saving cached response of %s: %s", url, err.Error())
+	}
+}
+
+// Adds entry to memory, drops the least recently used one if cache is full
+func (c *ResponseCache) add(url string, entry *CacheEntry) {
+	c.entries[url] = c.order.PushFront(&cacheItem{url: url, entry: entry})
+	for c.Size > 0 && c.order.Len() > c.Size {
+		last := c.order.Back()
+		c.order.Remove(last)
+		dropped := last.Value.(*cacheItem).url
+		delete(c.entries, dropped)
+		if c.Dir != "" {
+			if err := os.Remove(c.path(dropped)); err != nil && !os.IsNotExist(err) {
+				log.Printf("Error removing cached response of %s: %s", dropped, err.Error())
+			}
+		}
+	}
+}
+
+// Returns file of url entry
+func (c *ResponseCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Stats returns amount of hits and misses
+func (c *ResponseCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Records request result
+func (c *ResponseCache) count(hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hit {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+}
@@ -0,0 +1,123 @@
+package dvach_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aoyako/telegram_2ch_res_bot/controller"
+	"github.com/aoyako/telegram_2ch_res_bot/dvach"
+	mock_telegram "github.com/aoyako/telegram_2ch_res_bot/dvach/mock/sender"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	mock_controller "github.com/aoyako/telegram_2ch_res_bot/telegram/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetcher_Cache(t *testing.T) {
+	assert := assert.New(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"board":"a","threads":[{"num":1}]}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	fetcher := dvach.NewFetcher(server.Client(), &dvach.RetryPolicy{Attempts: 1})
+	fetcher.Cache = dvach.NewResponseCache(10, dir)
+	want := dvach.ListResponse{Board: "a", Threads: []dvach.Thread{{ID: 1}}}
+
+	var list dvach.ListResponse
+	assert.Nil(fetcher.GetJSON(context.Background(), server.URL+"/a", &list))
+	assert.Equal(want, list)
+
+	list = dvach.ListResponse{}
+	assert.Equal(dvach.ErrNotModified, fetcher.GetJSON(context.Background(), server.URL+"/a", &list))
+	assert.Equal(want, list)
+	assert.Equal(dvach.CacheStats{Hits: 1, Misses: 1}, fetcher.Cache.Stats())
+
+	// Cache is restored from disk
+	fetcher.Cache = dvach.NewResponseCache(10, dir)
+	list = dvach.ListResponse{}
+	assert.Equal(dvach.ErrNotModified, fetcher.GetJSON(context.Background(), server.URL+"/a", &list))
+	assert.Equal(want, list)
+	assert.Equal(3, calls)
+}
+
+func TestResponseCache_Size(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := dvach.NewResponseCache(2, "")
+	cache.Put("a", &dvach.CacheEntry{ETag: "a"})
+	cache.Put("b", &dvach.CacheEntry{ETag: "b"})
+	assert.NotNil(cache.Get("a"))
+	cache.Put("c", &dvach.CacheEntry{ETag: "c"})
+
+	assert.NotNil(cache.Get("a"))
+	assert.Nil(cache.Get("b"))
+	assert.NotNil(cache.Get("c"))
+}
+
+// Source which board and threads do not change after the first request
+type unchangedSource struct {
+	lists int
+	posts int
+}
+
+func (s *unchangedSource) ListThreads(ctx context.Context, board string) ([]dvach.Thread, error) {
+	s.lists++
+	threads := []dvach.Thread{{ID: 1, Comment: "thread"}}
+	if s.lists > 1 {
+		return threads, dvach.ErrNotModified
+	}
+	return threads, nil
+}
+
+func (s *unchangedSource) GetPosts(ctx context.Context, board string, threadID, after uint64) ([]dvach.Post, error) {
+	s.posts++
+	return []dvach.Post{{Num: 1, Timestamp: 100}}, nil
+}
+
+func (s *unchangedSource) GetMediaURL(file dvach.File) string {
+	return file.Path
+}
+
+func TestAPIWorkerDvach_NotModified(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	src := &unchangedSource{}
+	tm := mock_telegram.NewMockSender(ctrl)
+	cm := mock_controller.NewMockController(ctrl)
+	awdv := dvach.NewAPIWorkerDvach(&controller.Controller{
+		User:         cm.MockUser,
+		Subscription: cm.MockSubscription,
+		Info:         cm.MockInfo,
+		Ledger:       cm.MockLedger,
+	}, tm, map[string]dvach.Source{logic.DefaultSource: src})
+
+	publications := []logic.Publication{{ID: 1, Board: "a", Type: ".img", Tags: `"thread"`}}
+	cm.MockSubscription.EXPECT().GetAllSubs().Return(publications).Times(2)
+	cm.MockUser.EXPECT().GetUsersByPublication(gomock.Any()).Return([]logic.User{{ID: 1, ChatID: 1}}, nil)
+	cm.MockInfo.EXPECT().GetBoardTimestamp(logic.DefaultSource, "a").Return(uint64(0), nil).Times(2)
+	cm.MockInfo.EXPECT().GetThreadCursors(logic.DefaultSource, "a").Return(map[uint64]logic.Cursor{}, nil).Times(2)
+	cm.MockInfo.EXPECT().SetThreadCursor(logic.DefaultSource, "a", uint64(1), uint64(100), uint64(1)).Return(nil)
+	cm.MockInfo.EXPECT().RemoveStaleThreads(logic.DefaultSource, "a", []uint64{1}).Return(nil)
+	cm.MockInfo.EXPECT().SetBoardTimestamp(logic.DefaultSource, "a", uint64(100)).Return(nil)
+	cm.MockLedger.EXPECT().PruneSent().Return(nil).Times(2)
+
+	awdv.InitiateSending(context.Background())
+	awdv.InitiateSending(context.Background())
+
+	assert.Equal(2, src.lists)
+	assert.Equal(1, src.posts)
+}
@@ -0,0 +1,106 @@
+package dvach
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aoyako/telegram_2ch_res_bot/controller"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+)
+
+// Catalog keeps lists of boards of sources
+// List is requested again when it is older than TTL
+type Catalog struct {
+	Sources map[string]Source
+	TTL     time.Duration // How long list of boards is kept, 0 means forever
+	Timeout time.Duration // Timeout of list request, 0 means no timeout
+	Now     func() time.Time
+
+	mu     sync.Mutex
+	boards map[string]catalogEntry
+}
+
+// Cached list of boards of source
+type catalogEntry struct {
+	boards  []logic.Board
+	fetched time.Time
+}
+
+// NewCatalog constructor for Catalog
+func NewCatalog(sources map[string]Source, ttl time.Duration) *Catalog {
+	return &Catalog{
+		Sources: sources,
+		TTL:     ttl,
+		Now:     time.Now,
+		boards:  make(map[string]catalogEntry),
+	}
+}
+
+// GetBoards returns boards of source sorted by name
+// Outdated list is returned if it can not be refreshed
+func (c *Catalog) GetBoards(source string) ([]logic.Board, error) {
+	c.mu.Lock()
+	entry, ok := c.boards[source]
+	c.mu.Unlock()
+
+	if ok && (c.TTL == 0 || c.Now().Sub(entry.fetched) < c.TTL) {
+		return entry.boards, nil
+	}
+
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	boards, err := c.Refresh(ctx, source)
+	if err != nil && ok {
+		log.Printf("Error refreshing boards of %s: %s", source, err.Error())
+		return entry.boards, nil
+	}
+
+	return boards, err
+}
+
+// Refresh requests list of boards of source
+func (c *Catalog) Refresh(ctx context.Context, source string) ([]logic.Board, error) {
+	src, ok := c.Sources[source]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", controller.ErrUnknownSource, source)
+	}
+	lister, ok := src.(BoardLister)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	list, err := lister.ListBoards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("empty list of boards of %s", source)
+	}
+
+	boards := make([]logic.Board, len(list))
+	for i, board := range list {
+		boards[i] = logic.Board{
+			Source: source,
+			Name:   board.ID,
+			Title:  board.Name,
+		}
+	}
+	sort.Slice(boards, func(i, j int) bool {
+		return boards[i].Name < boards[j].Name
+	})
+
+	c.mu.Lock()
+	c.boards[source] = catalogEntry{boards: boards, fetched: c.Now()}
+	c.mu.Unlock()
+
+	return boards, nil
+}
@@ -0,0 +1,76 @@
+package dvach_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aoyako/telegram_2ch_res_bot/controller"
+	"github.com/aoyako/telegram_2ch_res_bot/dvach"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalog(t *testing.T) {
+	assert := assert.New(t)
+
+	calls, available := 0, true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if !available {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		switch r.URL.Path {
+		case "/boards":
+			_, _ = w.Write([]byte(`[{"id":"wp","name":"Обои"},{"id":"b","name":"Бред"}]`))
+		case "/boards.json":
+			_, _ = w.Write([]byte(`{"boards":[{"board":"wg","title":"Wallpapers/General"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	fetcher := dvach.NewFetcher(server.Client(), &dvach.RetryPolicy{Attempts: 1})
+	now := time.Unix(1000, 0)
+	catalog := dvach.NewCatalog(map[string]dvach.Source{
+		logic.DefaultSource: dvach.NewDvachSource(dvach.NewRequester(&dvach.RequestURL{BoardsURL: server.URL + "/boards"}, fetcher)),
+		"4chan":             dvach.NewFourchanSource(&dvach.FourchanURL{BoardsURL: server.URL + "/boards.json"}, fetcher),
+		"other":             dvach.NewFourchanSource(&dvach.FourchanURL{}, fetcher),
+	}, time.Hour)
+	catalog.Now = func() time.Time { return now }
+
+	want := []logic.Board{
+		{Source: logic.DefaultSource, Name: "b", Title: "Бред"},
+		{Source: logic.DefaultSource, Name: "wp", Title: "Обои"},
+	}
+	boards, err := catalog.GetBoards(logic.DefaultSource)
+	assert.Nil(err)
+	assert.Equal(want, boards)
+
+	boards, err = catalog.GetBoards("4chan")
+	assert.Nil(err)
+	assert.Equal([]logic.Board{{Source: "4chan", Name: "wg", Title: "Wallpapers/General"}}, boards)
+
+	_, err = catalog.GetBoards("other")
+	assert.Equal(dvach.ErrNotSupported, err)
+	_, err = catalog.GetBoards("unknown")
+	assert.True(errors.Is(err, controller.ErrUnknownSource))
+
+	// List is cached until it is outdated
+	boards, err = catalog.GetBoards(logic.DefaultSource)
+	assert.Nil(err)
+	assert.Equal(want, boards)
+	assert.Equal(2, calls)
+
+	// Outdated list is used if it can not be refreshed
+	now = now.Add(time.Hour)
+	available = false
+	boards, err = catalog.GetBoards(logic.DefaultSource)
+	assert.Nil(err)
+	assert.Equal(want, boards)
+	assert.Equal(3, calls)
+}
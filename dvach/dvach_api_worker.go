@@ -1,78 +1,254 @@
 package dvach
 
 import (
+	"context"
+	"fmt"
 	"log"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aoyako/telegram_2ch_res_bot/controller"
+	"github.com/aoyako/telegram_2ch_res_bot/filter"
 	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/media"
 	"github.com/aoyako/telegram_2ch_res_bot/telegram"
 )
 
 // APIWorkerDvach represents struct to work with external api
 type APIWorkerDvach struct {
-	cnt       *controller.Controller
-	Sender    telegram.Sender
-	Requester Requester
+	cnt     *controller.Controller
+	Sender  telegram.Sender
+	Sources map[string]Source // Imageboards by name
+	Clock   func() uint64     // Returns current unix time
+	Media   *media.Registry   // Known file types
+	Pool    *Pool             // Limits threads processed at the same time
+	Retries int               // Polls which try to deliver file again before it is skipped, 0 means no limit
+
+	m        sync.Mutex
+	settled  map[boardKey]bool  // Boards which were fully processed during the latest poll
+	lasthits map[boardKey]int64 // The latest bump of board's threads seen during the previous poll
+	retries  map[delivery]int   // Failed polls of deliveries which are sent again
+	skipped  map[delivery]bool  // Deliveries which are given up, they are not sent again while thread cursor is held
 }
 
-// SourceType specify user's file extensions choice
-type SourceType struct {
-	Image bool
-	Gif   bool
-	Webm  bool
-}
+// Polls which try to deliver file again if it is not configured
+const defaultRetries = 5
+
+// SourceType specify user's file types choice as set of media group names
+type SourceType map[string]bool
 
 // UserRequest stores information about user and it's requested file types
 // in thread
 type UserRequest struct {
-	User    *logic.User
-	Request SourceType
+	User        *logic.User
+	Request     SourceType
+	Filter      filter.Expr        // Checked against every post, nil if whole thread is requested
+	Limits      *logic.MediaLimits // Limits of sent files
+	Publication *logic.Publication // Publication which made request
+}
+
+// Recipient of file and publications which requested it
+type fileReceiver struct {
+	user         *logic.User
+	publications []*logic.Publication
 }
 
 // NewAPIWorkerDvach constructor for APIWorkerDvach
-func NewAPIWorkerDvach(cnt *controller.Controller, snd telegram.Sender, req Requester) *APIWorkerDvach {
+func NewAPIWorkerDvach(cnt *controller.Controller, snd telegram.Sender, sources map[string]Source) *APIWorkerDvach {
 	return &APIWorkerDvach{
-		cnt:       cnt,
-		Sender:    snd,
-		Requester: req,
+		cnt:     cnt,
+		Sender:  snd,
+		Sources: sources,
+		Clock: func() uint64 {
+			return uint64(time.Now().Unix())
+		},
+		Media:    media.Default(),
+		Pool:     NewPool(0, 0),
+		Retries:  defaultRetries,
+		settled:  make(map[boardKey]bool),
+		lasthits: make(map[boardKey]int64),
+		retries:  make(map[delivery]int),
+		skipped:  make(map[delivery]bool),
+	}
+}
+
+// Identifies board of imageboard
+type boardKey struct {
+	source string
+	board  string
+}
+
+// Identifies delivery of file to chat in thread
+type delivery struct {
+	board  boardKey
+	thread string
+	key    string
+	chatID int64
+}
+
+// Result of processing a single thread
+type threadResult struct {
+	threadID  uint64
+	timestamp uint64 // Time of the latest processed post
+	posts     int    // Amount of new posts
+	ok        bool   // Thread was fetched and processed
+}
+
+// Result of processing a single board
+type boardResult struct {
+	key      boardKey
+	ok       bool      // Board was fully processed and its cursor was saved
+	activity *Activity // Nil if threads were not received or board is polled for the first time
+}
+
+// Activity describes changes of board found during polling
+type Activity struct {
+	Threads int // Threads bumped since previous poll
+	Posts   int // New posts of fetched threads
+}
+
+// Counts threads bumped since previous poll of board and remembers the latest bump
+// Returns nil during the first poll, when previous bumps are unknown
+func (dw *APIWorkerDvach) boardActivity(key boardKey, threads []Thread) *Activity {
+	dw.m.Lock()
+	defer dw.m.Unlock()
+
+	lasthit, ok := dw.lasthits[key]
+	latest := lasthit
+	activity := &Activity{}
+	for i := range threads {
+		if threads[i].Lasthit > lasthit {
+			activity.Threads++
+		}
+		if threads[i].Lasthit > latest {
+			latest = threads[i].Lasthit
+		}
 	}
+
+	dw.lasthits[key] = latest
+	if !ok {
+		return nil
+	}
+	return activity
+}
+
+// String returns board as in publications, e.g. "b" or "4chan/g"
+func (k boardKey) String() string {
+	if k.source == logic.DefaultSource {
+		return k.board
+	}
+	return k.source + "/" + k.board
 }
 
 // InitiateSending loads data from server and sending it to users
-func (dw *APIWorkerDvach) InitiateSending() {
+func (dw *APIWorkerDvach) InitiateSending(ctx context.Context) {
+	_, err := dw.PollBoards(ctx, nil)
+	if err != nil {
+		log.Printf("Error sending: %s", err.Error())
+	}
+}
+
+// PollBoards loads data of boards for which due returns true and sends it to users
+// Every board is polled if due is nil
+// Returns activity of boards which threads were received
+func (dw *APIWorkerDvach) PollBoards(ctx context.Context, due func(board string) bool) (map[string]Activity, error) {
 	log.Println("started sending")
+	boardSubs := make(map[boardKey][]logic.Publication)
 
-	boardSubs := make(map[string][]logic.Publication)
 	subs := dw.cnt.GetAllSubs()
 
-	// nolint:gosimple
+	polled := make(map[boardKey]bool)
 	for i := range subs {
-		boardSubs[subs[i].Board] = append(boardSubs[subs[i].Board], subs[i])
+		key := boardKey{source: subs[i].SourceName(), board: subs[i].Board}
+		poll, ok := polled[key]
+		if !ok {
+			poll = due == nil || due(key.String())
+			polled[key] = poll
+		}
+		if poll {
+			boardSubs[key] = append(boardSubs[key], subs[i])
+		}
 	}
 
-	boardWaiter := make(chan uint64, len(boardSubs))
-
-	lastTimestamp := dw.cnt.GetLastTimestamp()
+	boardWaiter := make(chan boardResult, len(boardSubs))
 	for key := range boardSubs {
-		go dw.processBoard(boardSubs[key], key, lastTimestamp, boardWaiter)
+		go dw.processBoard(ctx, boardSubs[key], key, boardWaiter)
 	}
 
-	var lastReceivedTimestamp uint64
+	failed := make([]string, 0)
+	activity := make(map[string]Activity)
 	for i := 0; i < len(boardSubs); i++ {
-		tmp := <-boardWaiter
-		if tmp > lastReceivedTimestamp {
-			lastReceivedTimestamp = tmp
+		res := <-boardWaiter
+		if res.activity != nil {
+			activity[res.key.String()] = *res.activity
 		}
+		if !res.ok {
+			failed = append(failed, res.key.String())
+		}
+	}
+
+	err := dw.cnt.PruneSent()
+	if err != nil {
+		log.Printf("Error pruning sent files: %s", err.Error())
 	}
-	dw.cnt.SetLastTimestamp(lastReceivedTimestamp)
+
+	if len(failed) != 0 {
+		sort.Strings(failed)
+		return activity, &PollError{Boards: failed}
+	}
+	return activity, nil
 }
 
 // Process request from board
-func (dw *APIWorkerDvach) processBoard(subs []logic.Publication, board string, lastTimestamp uint64, waiter chan uint64) {
-	list := dw.Requester.GetAllThreads(board)
+// Board cursor is advanced only if every matched thread was processed
+func (dw *APIWorkerDvach) processBoard(ctx context.Context, subs []logic.Publication, key boardKey, waiter chan boardResult) {
+	res := boardResult{key: key}
+	defer func() {
+		dw.m.Lock()
+		dw.settled[key] = res.ok
+		dw.m.Unlock()
+		waiter <- res
+	}()
+
+	source, board := key.source, key.board
+	src, ok := dw.Sources[source]
+	if !ok {
+		log.Printf("Unknown source %s of board %s", source, board)
+		return
+	}
+
+	boardTimestamp, err := dw.cnt.GetBoardTimestamp(source, board)
+	if err != nil {
+		log.Printf("Error getting cursor of board %s: %s", board, err.Error())
+		return
+	}
+
+	threadCursors, err := dw.cnt.GetThreadCursors(source, board)
+	if err != nil {
+		log.Printf("Error getting thread cursors of board %s: %s", board, err.Error())
+		return
+	}
+
+	threads, err := src.ListThreads(ctx, board)
+	if err != nil && err != ErrNotModified {
+		log.Printf("Error getting threads of board %s: %s", board, err.Error())
+		return
+	}
+	if err == ErrNotModified {
+		// Threads did not change since previous poll
+		res.activity = &Activity{}
+	} else {
+		res.activity = dw.boardActivity(key, threads)
+	}
+
+	// Unchanged board has no new posts if it was fully processed last time
+	if err == ErrNotModified && dw.isSettled(key) {
+		res.ok = true
+		return
+	}
 
 	users := make([][]logic.User, len(subs))
 	for subID := range subs {
@@ -82,163 +258,563 @@ func (dw *APIWorkerDvach) processBoard(subs []logic.Publication, board string, l
 
 	usedThreads := make(map[int]([]UserRequest))
 
-	subValidator := make([]func(string) bool, len(subs))
+	subFilter := make([]filter.Expr, len(subs))
 	subTypes := make([]SourceType, len(subs))
 	for i, sub := range subs {
-		subValidator[i] = ParseKeywords(sub.Tags)
+		subFilter[i] = ParseFilter(sub.Tags, sub.LegacyTags)
 		subTypes[i] = ParseTypes(sub.Type)
 	}
 
-	for threadID, thread := range list.Threads {
+	now := dw.Clock()
+	for threadID := range threads {
+		doc := threadDocument(&threads[threadID])
 		for subID := range subs {
-			if subValidator[subID](thread.Comment) {
-				for userID := range users[subID] {
-					usedThreads[threadID] = append(usedThreads[threadID], UserRequest{
-						User:    &users[subID][userID],
-						Request: subTypes[subID],
-					})
-				}
+			if subFilter[subID] == nil || !CheckThresholds(&subs[subID], &threads[threadID], now) {
+				continue
+			}
+
+			// In post mode every thread is fetched, posts are checked in processThread
+			var postFilter filter.Expr
+			if subs[subID].PostMode {
+				postFilter = subFilter[subID]
+			} else if !subFilter[subID].Match(doc) {
+				continue
+			}
+
+			for userID := range users[subID] {
+				usedThreads[threadID] = append(usedThreads[threadID], UserRequest{
+					User:        &users[subID][userID],
+					Request:     subTypes[subID],
+					Filter:      postFilter,
+					Limits:      &subs[subID].Media,
+					Publication: &subs[subID],
+				})
 			}
 		}
 	}
 
-	threadWaiter := make(chan uint64, len(usedThreads))
+	threadWaiter := make(chan threadResult, len(usedThreads))
 	for threadID, subsList := range usedThreads {
-		URLThreadID := list.Threads[threadID].ID
-		dw.processThread(board, strconv.FormatUint(URLThreadID, 10), subsList, lastTimestamp, threadWaiter)
+		thread, subsList := &threads[threadID], subsList
+		cursor, ok := threadCursors[thread.ID]
+		if !ok {
+			cursor = logic.Cursor{LastPost: boardTimestamp}
+		}
+		dw.Pool.run(ctx, key, func() {
+			dw.processThread(ctx, src, key, thread, subsList, cursor, threadWaiter)
+		}, func() {
+			threadWaiter <- threadResult{threadID: thread.ID}
+		})
 	}
 
-	var lastReceivedTimestamp uint64
+	completed := true
+	lastReceivedTimestamp := boardTimestamp
 	for i := 0; i < len(usedThreads); i++ {
-		tmp := <-threadWaiter
-		if tmp > lastReceivedTimestamp {
-			lastReceivedTimestamp = tmp
+		thread := <-threadWaiter
+		if res.activity != nil {
+			res.activity.Posts += thread.posts
 		}
+		if !thread.ok {
+			completed = false
+			continue
+		}
+		if thread.timestamp > lastReceivedTimestamp {
+			lastReceivedTimestamp = thread.timestamp
+		}
+	}
+
+	if !completed {
+		log.Printf("Board %s was not fully processed, cursor is kept", board)
+		return
+	}
+
+	alive := make([]uint64, len(threads))
+	for i := range threads {
+		alive[i] = threads[i].ID
+	}
+	err = dw.cnt.RemoveStaleThreads(source, board, alive)
+	if err != nil {
+		log.Printf("Error removing stale threads of board %s: %s", board, err.Error())
+	}
+
+	err = dw.cnt.SetBoardTimestamp(source, board, lastReceivedTimestamp)
+	if err != nil {
+		log.Printf("Error saving cursor of board %s: %s", board, err.Error())
+		return
 	}
 
-	waiter <- lastReceivedTimestamp
+	res.ok = true
+}
+
+// Returns true if board was fully processed during the latest poll
+func (dw *APIWorkerDvach) isSettled(key boardKey) bool {
+	dw.m.Lock()
+	defer dw.m.Unlock()
+	return dw.settled[key]
 }
 
 // Process requests from thread
-func (dw *APIWorkerDvach) processThread(board, URLThreadID string, subsList []UserRequest, lastTimestamp uint64, waiter chan uint64) {
-	threadData := dw.Requester.GetThread(board, URLThreadID)
-	currentTimestamp := lastTimestamp
+// Posts older than cursor are skipped, cursor number allows source to load only new posts
+func (dw *APIWorkerDvach) processThread(ctx context.Context, src Source, key boardKey, thread *Thread,
+	subsList []UserRequest, cursor logic.Cursor, waiter chan threadResult) {
+	board := key.board
+	threadID := thread.ID
+	URLThreadID := strconv.FormatUint(threadID, 10)
+	posts, err := src.GetPosts(ctx, board, threadID, cursor.LastNum)
+	if err != nil && err != ErrNotModified {
+		log.Printf("Error getting thread %s/%s: %s", board, URLThreadID, err.Error())
+		waiter <- threadResult{threadID: threadID}
+		return
+	}
 
-	if len(threadData.ThreadPosts) == 0 {
-		waiter <- 0
+	// Thread with saved cursor was processed up to the latest response if board was fully processed
+	if err == ErrNotModified && cursor.Thread != 0 && dw.isSettled(key) {
+		waiter <- threadResult{threadID: threadID, timestamp: cursor.LastPost, ok: true}
 		return
 	}
 
-	for _, post := range threadData.ThreadPosts[0].Posts {
+	lastTimestamp := cursor.LastPost
+	currentTimestamp := lastTimestamp
+	currentNum := cursor.LastNum
+	newPosts := 0
+	// Cursor is not moved past the first post which files should be sent again
+	retry := false
+	for _, post := range posts {
 		if post.Timestamp > lastTimestamp {
-			files := post.Files
-			for _, file := range files {
-				fileReceivers := make([]*logic.User, 0)
-				for subID := range subsList {
-					if CheckFileExtension(file.Name, subsList[subID].Request) {
-						fileReceivers = append(fileReceivers, subsList[subID].User)
-					}
+			newPosts++
+			postReceivers := make([]UserRequest, 0, len(subsList))
+			doc := postDocument(thread, &post)
+			for subID := range subsList {
+				if subsList[subID].Filter == nil || subsList[subID].Filter.Match(doc) {
+					postReceivers = append(postReceivers, subsList[subID])
 				}
-				dw.Sender.Send(fileReceivers, dw.Requester.GetResourceURL(file.Path), URLThreadID)
 			}
 
-			if post.Timestamp > currentTimestamp {
-				currentTimestamp = post.Timestamp
+			if dw.sendPost(ctx, post.Files, src, key, URLThreadID, postReceivers) {
+				retry = true
 			}
 		}
+
+		if retry {
+			continue
+		}
+		if post.Num > currentNum {
+			currentNum = post.Num
+		}
+		if post.Timestamp > currentTimestamp {
+			currentTimestamp = post.Timestamp
+		}
 	}
 
-	waiter <- currentTimestamp
-}
+	// New thread gets cursor, so failed post is not skipped when board cursor moves
+	if currentTimestamp > lastTimestamp || currentNum > cursor.LastNum || (retry && cursor.Thread == 0) {
+		err = dw.cnt.SetThreadCursor(key.source, board, threadID, currentTimestamp, currentNum)
+		if err != nil {
+			log.Printf("Error saving cursor of thread %s/%s: %s", board, URLThreadID, err.Error())
+			waiter <- threadResult{threadID: threadID, posts: newPosts}
+			return
+		}
+	}
 
-// CheckFileExtension returns true if filename is user's selected type
-func CheckFileExtension(filename string, req SourceType) bool {
-	var result bool
-	if req.Image {
-		result = result || strings.HasSuffix(filename, ".png") || strings.HasSuffix(filename, ".jpg") ||
-			strings.HasSuffix(filename, ".jpeg")
+	if retry {
+		log.Printf("Files of thread %s/%s were not delivered, they will be sent again", board, URLThreadID)
+		waiter <- threadResult{threadID: threadID, posts: newPosts}
+		return
 	}
+	dw.forgetThread(key, URLThreadID)
 
-	if req.Gif {
-		result = result || strings.HasSuffix(filename, ".gif")
+	waiter <- threadResult{
+		threadID:  threadID,
+		timestamp: currentTimestamp,
+		posts:     newPosts,
+		ok:        true,
 	}
+}
+
+// Returns recipients of file, user requesting file by several publications is listed once
+func (dw *APIWorkerDvach) mergeReceivers(file *File, requests []UserRequest) []fileReceiver {
+	receivers := make([]fileReceiver, 0)
+	index := make(map[int64]int)
+	for _, req := range requests {
+		if !CheckFileExtension(file.Name, req.Request, dw.Media) || !CheckFileLimits(file, req.Limits) {
+			continue
+		}
 
-	if req.Webm {
-		result = result || strings.HasSuffix(filename, ".webm")
+		i, ok := index[req.User.ChatID]
+		if !ok {
+			i = len(receivers)
+			index[req.User.ChatID] = i
+			receivers = append(receivers, fileReceiver{user: req.User})
+		}
+		if req.Publication != nil && !containsPublication(receivers[i].publications, req.Publication) {
+			receivers[i].publications = append(receivers[i].publications, req.Publication)
+		}
 	}
 
-	return result
+	return receivers
 }
 
-// ParseKeywords retruns function to validate keywords
-func ParseKeywords(s string) func(string) bool {
-	d := regexp.MustCompile("\"\\|")
-	c := regexp.MustCompile("\"&")
-	disjunction := d.Split(s, -1)
-	conjunction := make([][]string, len(disjunction))
-	negation := make([][]bool, len(disjunction))
-
-	for key := range disjunction {
-		conjunction[key] = c.Split(disjunction[key], -1)
-		for ckey := range conjunction[key] {
-			if strings.HasPrefix(conjunction[key][ckey], "!") {
-				negation[key] = append(negation[key], true)
-				conjunction[key][ckey] = strings.TrimPrefix(conjunction[key][ckey], "!\"")
-			} else {
-				negation[key] = append(negation[key], false)
-				conjunction[key][ckey] = strings.TrimPrefix(conjunction[key][ckey], "\"")
+// Returns true if pubs contain publication with the same id
+func containsPublication(pubs []*logic.Publication, pub *logic.Publication) bool {
+	for _, p := range pubs {
+		if p.ID == pub.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// Sends files of post to receivers which did not receive them yet
+// Files going to the same receivers with the same caption are sent as album
+// Receivers matched by the same publications get one message with common caption
+// If ledger is unavailable files are sent to everyone
+// Only delivered files are recorded in ledger, receivers which can never get file are skipped while post is sent again
+// Returns true if some files were not delivered to some receivers and should be sent again
+func (dw *APIWorkerDvach) sendPost(ctx context.Context, files []File, src Source, board boardKey, threadID string,
+	requests []UserRequest) bool {
+	type postFile struct {
+		file   logic.File
+		unsent []*logic.User
+	}
+	// Files of receiver with the same caption
+	type parcel struct {
+		chatID  int64
+		caption string
+	}
+
+	postFiles := make([]postFile, 0, len(files))
+	parcels := make([]parcel, 0)
+	parcelUsers := make(map[parcel]*logic.User)
+	parcelFiles := make(map[parcel][]int)
+	for i := range files {
+		file := &files[i]
+		receivers := dw.mergeReceivers(file, requests)
+		if len(receivers) == 0 {
+			continue
+		}
+
+		users := make([]*logic.User, len(receivers))
+		for j := range receivers {
+			users[j] = receivers[j].user
+		}
+
+		url := src.GetMediaURL(*file)
+		key := FileKey(file, url)
+		unsent, err := dw.cnt.FilterUnsent(key, users)
+		if err != nil {
+			log.Printf("Error checking deliveries of %s: %s", url, err.Error())
+		}
+		unsent = dw.filterSkipped(board, threadID, key, unsent)
+		if len(unsent) == 0 {
+			continue
+		}
+
+		allowed := make(map[int64]bool)
+		for _, user := range unsent {
+			allowed[user.ChatID] = true
+		}
+
+		index := len(postFiles)
+		postFiles = append(postFiles, postFile{file: logic.File{URL: url, Key: key}, unsent: unsent})
+		for _, receiver := range receivers {
+			if !allowed[receiver.user.ChatID] {
+				continue
 			}
+			allowed[receiver.user.ChatID] = false
+
+			p := parcel{receiver.user.ChatID, FileCaption(threadID, receiver.publications)}
+			if _, ok := parcelFiles[p]; !ok {
+				parcels = append(parcels, p)
+				parcelUsers[p] = receiver.user
+			}
+			parcelFiles[p] = append(parcelFiles[p], index)
 		}
 	}
 
-	conjunction[len(conjunction)-1][len(conjunction[len(conjunction)-1])-1] =
-		strings.TrimSuffix(conjunction[len(conjunction)-1][len(conjunction[len(conjunction)-1])-1], "\"")
+	// Receivers of the same files with the same caption get one album
+	type album struct {
+		caption string
+		files   []int
+		users   []*logic.User
+	}
+	albums := make([]*album, 0)
+	albumIndex := make(map[string]*album)
+	for _, p := range parcels {
+		id := p.caption + "\x00" + fmt.Sprint(parcelFiles[p])
+		a, ok := albumIndex[id]
+		if !ok {
+			a = &album{caption: p.caption, files: parcelFiles[p]}
+			albumIndex[id] = a
+			albums = append(albums, a)
+		}
+		a.users = append(a.users, parcelUsers[p])
+	}
 
-	return func(input string) bool {
-		for dis := range conjunction {
-			success := true
-			for con := range conjunction[dis] {
-				if !(strings.Contains(strings.ToLower(input),
-					strings.ToLower(conjunction[dis][con])) != negation[dis][con]) {
-					success = false
-					break
-				}
+	// Queued files are recorded in ledger by outbox once they are sent
+	results := make([]map[int64]telegram.Result, len(postFiles))
+	for _, a := range albums {
+		var albumResults [][]telegram.Result
+		if len(a.files) == 1 {
+			file := postFiles[a.files[0]].file
+			albumResults = [][]telegram.Result{dw.Sender.Send(ctx, a.users, file.URL, a.caption, file.Key)}
+		} else {
+			albumFiles := make([]logic.File, len(a.files))
+			for j, index := range a.files {
+				albumFiles[j] = postFiles[index].file
+			}
+			albumResults = dw.Sender.SendAlbum(ctx, a.users, albumFiles, a.caption)
+		}
+
+		for j := 0; j < len(albumResults) && j < len(a.files); j++ {
+			if results[a.files[j]] == nil {
+				results[a.files[j]] = make(map[int64]telegram.Result)
 			}
-			if success {
-				return true
+			for _, result := range albumResults[j] {
+				results[a.files[j]][result.ChatID] = result
 			}
 		}
+	}
+
+	retry := false
+	for i, postFile := range postFiles {
+		delivered := make([]*logic.User, 0, len(postFile.unsent))
+		for _, user := range postFile.unsent {
+			result, ok := results[i][user.ChatID]
+			if !ok || result.Queued {
+				continue
+			}
+
+			d := delivery{board: board, thread: threadID, key: postFile.file.Key, chatID: user.ChatID}
+			if result.Err == nil {
+				dw.retryDelivery(d, false)
+				delivered = append(delivered, user)
+				continue
+			}
+			if result.Retry() && dw.retryDelivery(d, true) {
+				retry = true
+				continue
+			}
+			dw.skipDelivery(d)
+		}
+		if len(delivered) == 0 {
+			continue
+		}
+
+		err := dw.cnt.MarkSent(postFile.file.Key, delivered)
+		if err != nil {
+			log.Printf("Error saving deliveries of %s: %s", postFile.file.URL, err.Error())
+		}
+	}
+	return retry
+}
+
+// Counts failed polls of delivery, returns true if file should be sent again
+// Counter is reset when file is delivered
+func (dw *APIWorkerDvach) retryDelivery(d delivery, failed bool) bool {
+	dw.m.Lock()
+	defer dw.m.Unlock()
+
+	if !failed {
+		delete(dw.retries, d)
+		return false
+	}
+
+	dw.retries[d]++
+	if dw.Retries > 0 && dw.retries[d] > dw.Retries {
+		log.Printf("File %s was not delivered to %d after %d attempts, it is skipped", d.key, d.chatID, dw.retries[d])
+		delete(dw.retries, d)
 		return false
 	}
+	return true
 }
 
-// ParseTypes returns types from s as [.img.gif.webm]
-func ParseTypes(s string) SourceType {
-	var result SourceType
+// Remembers delivery which is not sent again while post is retried
+func (dw *APIWorkerDvach) skipDelivery(d delivery) {
+	dw.m.Lock()
+	defer dw.m.Unlock()
+	delete(dw.retries, d)
+	dw.skipped[d] = true
+}
+
+// Returns users which file of thread is not skipped for
+func (dw *APIWorkerDvach) filterSkipped(board boardKey, threadID, key string, users []*logic.User) []*logic.User {
+	dw.m.Lock()
+	defer dw.m.Unlock()
 
-	re := regexp.MustCompile(`\.`)
-	res := re.Split(s, -1)
-	if _, ok := find(res, "img"); ok {
-		result.Image = true
+	filtered := make([]*logic.User, 0, len(users))
+	for _, user := range users {
+		if !dw.skipped[delivery{board: board, thread: threadID, key: key, chatID: user.ChatID}] {
+			filtered = append(filtered, user)
+		}
 	}
+	return filtered
+}
+
+// Forgets failed deliveries of thread once its cursor moves past all posts
+func (dw *APIWorkerDvach) forgetThread(board boardKey, threadID string) {
+	dw.m.Lock()
+	defer dw.m.Unlock()
 
-	if _, ok := find(res, "gif"); ok {
-		result.Gif = true
+	for d := range dw.skipped {
+		if d.board == board && d.thread == threadID {
+			delete(dw.skipped, d)
+		}
+	}
+	for d := range dw.retries {
+		if d.board == board && d.thread == threadID {
+			delete(dw.retries, d)
+		}
 	}
+}
 
-	if _, ok := find(res, "webm"); ok {
-		result.Webm = true
+// FileCaption returns caption of file with thread number and publications which requested it
+func FileCaption(threadID string, pubs []*logic.Publication) string {
+	if len(pubs) == 0 {
+		return threadID
 	}
 
-	return result
+	labels := make([]string, len(pubs))
+	for i, pub := range pubs {
+		labels[i] = pub.Alias
+		if labels[i] == "" {
+			labels[i] = pub.Tags
+		}
+	}
+
+	return threadID + "\n" + strings.Join(labels, ", ")
+}
+
+// FileKey returns identity of file used to find repeated deliveries
+// Reposts of the same file share md5, url is used if md5 is unknown
+func FileKey(file *File, url string) string {
+	if file.MD5 != "" {
+		return "md5:" + strings.ToLower(file.MD5)
+	}
+	return "url:" + url
+}
+
+// CheckThresholds returns true if thread is popular and fresh enough for publication
+func CheckThresholds(pub *logic.Publication, thread *Thread, now uint64) bool {
+	if thread.PostCount < pub.MinPosts || thread.Views < pub.MinViews {
+		return false
+	}
+
+	if pub.MinScore != 0 && thread.Score < pub.MinScore {
+		return false
+	}
+
+	// Age is compared by subtraction, so large limits do not overflow
+	if pub.MaxAge != 0 && thread.Timestamp != 0 && thread.Timestamp < now && now-thread.Timestamp > pub.MaxAge {
+		return false
+	}
+
+	if pub.MaxInactive != 0 && thread.Lasthit > 0 && uint64(thread.Lasthit) < now && now-uint64(thread.Lasthit) > pub.MaxInactive {
+		return false
+	}
+
+	return true
 }
 
-// Returns if "val" is in sice and it's position
-func find(slice []string, val string) (int, bool) {
-	for i, item := range slice {
-		if item == val {
-			return i, true
+// CheckFileLimits returns true if file metadata satisfies limits
+// Values which are not reported by imageboard are not checked
+func CheckFileLimits(file *File, limits *logic.MediaLimits) bool {
+	if limits == nil {
+		return true
+	}
+
+	if file.Width != 0 && file.Height != 0 {
+		if file.Width < limits.MinWidth || file.Height < limits.MinHeight {
+			return false
+		}
+		if (limits.MaxWidth != 0 && file.Width > limits.MaxWidth) ||
+			(limits.MaxHeight != 0 && file.Height > limits.MaxHeight) {
+			return false
+		}
+
+		aspect := float64(file.Width) / float64(file.Height)
+		if aspect < limits.MinAspect || (limits.MaxAspect != 0 && aspect > limits.MaxAspect) {
+			return false
 		}
 	}
-	return -1, false
+
+	if file.Size != 0 {
+		if file.Size < limits.MinSize || (limits.MaxSize != 0 && file.Size > limits.MaxSize) {
+			return false
+		}
+	}
+
+	if file.Duration != 0 {
+		if file.Duration < limits.MinDuration || (limits.MaxDuration != 0 && file.Duration > limits.MaxDuration) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckFileExtension returns true if filename is user's selected type
+func CheckFileExtension(filename string, req SourceType, reg *media.Registry) bool {
+	format, ok := reg.Lookup(filename)
+	return ok && req[format.Group]
+}
+
+// ParseFilter returns expression of tags, legacy tags saved before expression grammar are parsed as before
+// Returns nil if tags are malformed
+func ParseFilter(s string, legacy bool) filter.Expr {
+	if legacy {
+		return filter.ParseLegacy(s)
+	}
+
+	expr, err := filter.Parse(s)
+	if err != nil {
+		log.Printf("Error parsing tags %s: %s", s, err.Error())
+		return nil
+	}
+
+	return expr
+}
+
+// ParseKeywords retruns function to validate keywords
+// Malformed expression matches nothing
+func ParseKeywords(s string) func(string) bool {
+	expr := ParseFilter(s, false)
+	return func(input string) bool {
+		if expr == nil {
+			return false
+		}
+		return expr.Match(&filter.Document{Text: input, Op: input, Post: input})
+	}
+}
+
+// Returns document of thread checked by publication tags
+// Thread is represented by its first post
+func threadDocument(thread *Thread) *filter.Document {
+	return &filter.Document{
+		Text:    thread.Comment,
+		Subject: thread.Subject,
+		Op:      thread.Comment,
+		Post:    thread.Comment,
+	}
+}
+
+// Returns document of post checked by tags of publication in post mode
+func postDocument(thread *Thread, post *Post) *filter.Document {
+	return &filter.Document{
+		Text:    post.Comment,
+		Subject: thread.Subject,
+		Op:      thread.Comment,
+		Post:    post.Comment,
+	}
+}
+
+// ParseTypes returns types from s as [.group1.group2...], e.g. .img.gif.webm
+func ParseTypes(s string) SourceType {
+	result := make(SourceType)
+	for _, name := range strings.Split(s, ".") {
+		if name != "" {
+			result[name] = true
+		}
+	}
+
+	return result
 }
@@ -1,17 +1,25 @@
 package dvach_test
 
 import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
 	"testing"
 
 	"github.com/aoyako/telegram_2ch_res_bot/dvach"
 	mock_dvach "github.com/aoyako/telegram_2ch_res_bot/dvach/mock/requester"
+	"github.com/aoyako/telegram_2ch_res_bot/filter"
+	"github.com/aoyako/telegram_2ch_res_bot/media"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/aoyako/telegram_2ch_res_bot/controller"
 	mock_telegram "github.com/aoyako/telegram_2ch_res_bot/dvach/mock/sender"
 	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/telegram"
 	mock_controller "github.com/aoyako/telegram_2ch_res_bot/telegram/mock"
 	"github.com/golang/mock/gomock"
+	telebot "gopkg.in/tucnak/telebot.v2"
 )
 
 func TestAPIWorkerDvach_InitiateSending(t *testing.T) {
@@ -108,7 +116,13 @@ func TestAPIWorkerDvach_InitiateSending(t *testing.T) {
 				User:         cm.MockUser,
 				Subscription: cm.MockSubscription,
 				Info:         cm.MockInfo,
-			}, tm, sm)
+				Ledger:       cm.MockLedger,
+			}, tm, map[string]dvach.Source{logic.DefaultSource: dvach.NewDvachSource(sm)})
+
+			cm.MockLedger.
+				EXPECT().
+				PruneSent().
+				Return(nil)
 
 			cm.MockSubscription.
 				EXPECT().
@@ -125,21 +139,46 @@ func TestAPIWorkerDvach_InitiateSending(t *testing.T) {
 			for i := range tt.args.boards {
 				sm.
 					EXPECT().
-					GetAllThreads(gomock.Eq(tt.args.boards[i])).
-					Return(tt.args.expectAllThreads[i])
+					GetAllThreads(gomock.Any(), gomock.Eq(tt.args.boards[i])).
+					Return(tt.args.expectAllThreads[i], nil)
+
+				cm.MockInfo.
+					EXPECT().
+					GetBoardTimestamp(gomock.Eq(logic.DefaultSource), gomock.Eq(tt.args.boards[i])).
+					Return(uint64(0), nil)
+
+				cm.MockInfo.
+					EXPECT().
+					GetThreadCursors(gomock.Eq(logic.DefaultSource), gomock.Eq(tt.args.boards[i])).
+					Return(map[uint64]logic.Cursor{}, nil)
+
+				alive := make([]uint64, 0)
+				for _, thread := range tt.args.expectAllThreads[i].Threads {
+					alive = append(alive, thread.ID)
+				}
+				cm.MockInfo.
+					EXPECT().
+					RemoveStaleThreads(gomock.Eq(logic.DefaultSource), gomock.Eq(tt.args.boards[i]), gomock.Eq(alive)).
+					Return(nil)
+
+				cm.MockInfo.
+					EXPECT().
+					SetBoardTimestamp(gomock.Eq(logic.DefaultSource), gomock.Eq(tt.args.boards[i]), gomock.Eq(tt.args.lastTimestamp)).
+					Return(nil)
 			}
 
 			for i := range tt.args.expectThreadData {
 				for j := range tt.args.threadsToProcess[i] {
 					sm.
 						EXPECT().
-						GetThread(gomock.Eq(tt.args.boards[i]), gomock.Eq(tt.args.threadsToProcess[i][j])).
-						Return(tt.args.expectThreadData[i][j])
+						GetThread(gomock.Any(), gomock.Eq(tt.args.boards[i]), gomock.Eq(tt.args.threadsToProcess[i][j])).
+						Return(tt.args.expectThreadData[i][j], nil)
 
+					threadID, _ := strconv.ParseUint(tt.args.threadsToProcess[i][j], 10, 64)
 					cm.MockInfo.
 						EXPECT().
-						GetLastTimestamp().
-						Return(uint64(0))
+						SetThreadCursor(gomock.Eq(logic.DefaultSource), gomock.Eq(tt.args.boards[i]), gomock.Eq(threadID), gomock.Eq(tt.args.lastTimestamp), gomock.Eq(uint64(0))).
+						Return(nil)
 				}
 			}
 
@@ -153,23 +192,317 @@ func TestAPIWorkerDvach_InitiateSending(t *testing.T) {
 				for j := range receivers {
 					receivers[j] = &tt.args.users[i][j]
 				}
+				cm.MockLedger.
+					EXPECT().
+					FilterUnsent(gomock.Eq("url:"+tt.args.urlFilesToSend[i]), gomock.Eq(receivers)).
+					Return(receivers, nil)
+				cm.MockLedger.
+					EXPECT().
+					MarkSent(gomock.Eq("url:"+tt.args.urlFilesToSend[i]), gomock.Eq(receivers)).
+					Return(nil)
 				tm.
 					EXPECT().
-					Send(gomock.Eq(receivers),
+					Send(gomock.Any(), gomock.Eq(receivers),
 						gomock.Eq(tt.args.urlFilesToSend[i]),
-						gomock.Eq(tt.args.threadsToProcess[i][0]),
-					).AnyTimes()
+						gomock.Eq(dvach.FileCaption(tt.args.threadsToProcess[i][0], []*logic.Publication{&tt.args.publications[i]})),
+						gomock.Eq("url:"+tt.args.urlFilesToSend[i]),
+					).Return(sentResults(receivers)).Times(1)
 			}
 
-			cm.MockInfo.
-				EXPECT().
-				SetLastTimestamp(gomock.Eq(tt.args.lastTimestamp))
-
-			awdv.InitiateSending()
+			awdv.InitiateSending(context.Background())
 		})
 	}
 }
 
+// Source with single thread which posts have files
+// Returns results of file delivered to every user
+func sentResults(users []*logic.User) []telegram.Result {
+	results := make([]telegram.Result, len(users))
+	for i, user := range users {
+		results[i] = telegram.Result{ChatID: user.ChatID}
+	}
+	return results
+}
+
+type postsSource struct {
+	posts []dvach.Post
+}
+
+func (s *postsSource) ListThreads(ctx context.Context, board string) ([]dvach.Thread, error) {
+	return []dvach.Thread{{ID: 1, Comment: "thread"}}, nil
+}
+
+func (s *postsSource) GetPosts(ctx context.Context, board string, threadID, after uint64) ([]dvach.Post, error) {
+	return s.posts, nil
+}
+
+func (s *postsSource) GetMediaURL(file dvach.File) string {
+	return file.Path
+}
+
+func TestAPIWorkerDvach_Retry(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	src := &postsSource{posts: []dvach.Post{
+		{Num: 1, Timestamp: 100, Files: []dvach.File{{Name: "a.png", Path: "/a.png"}}},
+		{Num: 2, Timestamp: 200, Files: []dvach.File{{Name: "b.png", Path: "/b.png"}}},
+	}}
+	tm := mock_telegram.NewMockSender(ctrl)
+	cm := mock_controller.NewMockController(ctrl)
+	awdv := dvach.NewAPIWorkerDvach(&controller.Controller{
+		User:         cm.MockUser,
+		Subscription: cm.MockSubscription,
+		Info:         cm.MockInfo,
+		Ledger:       cm.MockLedger,
+	}, tm, map[string]dvach.Source{logic.DefaultSource: src})
+
+	publications := []logic.Publication{{ID: 1, Board: "a", Type: ".img", Tags: `"thread"`}}
+	users := []logic.User{{ID: 1, ChatID: 1}, {ID: 2, ChatID: 2}}
+	receivers := []*logic.User{&users[0], &users[1]}
+	caption := dvach.FileCaption("1", []*logic.Publication{&publications[0]})
+
+	cm.MockSubscription.EXPECT().GetAllSubs().Return(publications).Times(2)
+	cm.MockUser.EXPECT().GetUsersByPublication(gomock.Any()).Return(users, nil).Times(2)
+	cm.MockInfo.EXPECT().GetBoardTimestamp(logic.DefaultSource, "a").Return(uint64(0), nil).Times(2)
+	cm.MockInfo.EXPECT().GetThreadCursors(logic.DefaultSource, "a").Return(map[uint64]logic.Cursor{}, nil).Times(2)
+	cm.MockLedger.EXPECT().PruneSent().Return(nil).Times(2)
+
+	// The first file is not delivered to the second user because of network error
+	cm.MockLedger.EXPECT().FilterUnsent("url:/a.png", receivers).Return(receivers, nil)
+	tm.EXPECT().Send(gomock.Any(), receivers, "/a.png", caption, "url:/a.png").Return([]telegram.Result{
+		{ChatID: 1, MessageID: 10},
+		{ChatID: 2, Err: errors.New("connection reset"), Class: telegram.ClassTransient},
+	})
+	cm.MockLedger.EXPECT().MarkSent("url:/a.png", receivers[:1]).Return(nil)
+
+	// The second file is blocked by the second user, only delivered file is recorded
+	cm.MockLedger.EXPECT().FilterUnsent("url:/b.png", receivers).Return(receivers, nil)
+	tm.EXPECT().Send(gomock.Any(), receivers, "/b.png", caption, "url:/b.png").Return([]telegram.Result{
+		{ChatID: 1, MessageID: 11},
+		{ChatID: 2, Err: telebot.ErrBlockedByUser, Class: telegram.ClassBlocked},
+	})
+	cm.MockLedger.EXPECT().MarkSent("url:/b.png", receivers[:1]).Return(nil)
+
+	// Thread cursor stays before the first post, board cursor is kept
+	cm.MockInfo.EXPECT().SetThreadCursor(logic.DefaultSource, "a", uint64(1), uint64(0), uint64(0)).Return(nil)
+
+	_, err := awdv.PollBoards(context.Background(), nil)
+	assert.Equal(&dvach.PollError{Boards: []string{"a"}}, err)
+
+	// The first file is sent again, the second one is not sent to user who blocked bot
+	cm.MockLedger.EXPECT().FilterUnsent("url:/a.png", receivers).Return(receivers[1:], nil)
+	tm.EXPECT().Send(gomock.Any(), receivers[1:], "/a.png", caption, "url:/a.png").Return([]telegram.Result{
+		{ChatID: 2, MessageID: 12},
+	})
+	cm.MockLedger.EXPECT().MarkSent("url:/a.png", receivers[1:]).Return(nil)
+	cm.MockLedger.EXPECT().FilterUnsent("url:/b.png", receivers).Return(receivers[1:], nil)
+
+	cm.MockInfo.EXPECT().SetThreadCursor(logic.DefaultSource, "a", uint64(1), uint64(200), uint64(2)).Return(nil)
+	cm.MockInfo.EXPECT().RemoveStaleThreads(logic.DefaultSource, "a", []uint64{1}).Return(nil)
+	cm.MockInfo.EXPECT().SetBoardTimestamp(logic.DefaultSource, "a", uint64(200)).Return(nil)
+	_, err = awdv.PollBoards(context.Background(), nil)
+	assert.Nil(err)
+}
+
+func TestAPIWorkerDvach_RetryLimit(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	src := &postsSource{posts: []dvach.Post{
+		{Num: 1, Timestamp: 100, Files: []dvach.File{{Name: "a.png", Path: "/a.png"}}},
+	}}
+	tm := mock_telegram.NewMockSender(ctrl)
+	cm := mock_controller.NewMockController(ctrl)
+	awdv := dvach.NewAPIWorkerDvach(&controller.Controller{
+		User:         cm.MockUser,
+		Subscription: cm.MockSubscription,
+		Info:         cm.MockInfo,
+		Ledger:       cm.MockLedger,
+	}, tm, map[string]dvach.Source{logic.DefaultSource: src})
+	awdv.Retries = 2
+
+	publications := []logic.Publication{{ID: 1, Board: "a", Type: ".img", Tags: `"thread"`}}
+	users := []logic.User{{ID: 1, ChatID: 1}}
+	receivers := []*logic.User{&users[0]}
+
+	cm.MockSubscription.EXPECT().GetAllSubs().Return(publications).AnyTimes()
+	cm.MockUser.EXPECT().GetUsersByPublication(gomock.Any()).Return(users, nil).AnyTimes()
+	cm.MockInfo.EXPECT().GetBoardTimestamp(logic.DefaultSource, "a").Return(uint64(0), nil).AnyTimes()
+	cm.MockInfo.EXPECT().GetThreadCursors(logic.DefaultSource, "a").Return(map[uint64]logic.Cursor{}, nil).AnyTimes()
+	cm.MockLedger.EXPECT().PruneSent().Return(nil).AnyTimes()
+	cm.MockLedger.EXPECT().FilterUnsent("url:/a.png", receivers).Return(receivers, nil).Times(3)
+	tm.EXPECT().Send(gomock.Any(), receivers, "/a.png", gomock.Any(), "url:/a.png").Return([]telegram.Result{
+		{ChatID: 1, Err: errors.New("connection reset"), Class: telegram.ClassTransient},
+	}).Times(3)
+
+	// File is sent again while attempts are left
+	cm.MockInfo.EXPECT().SetThreadCursor(logic.DefaultSource, "a", uint64(1), uint64(0), uint64(0)).Return(nil).Times(2)
+	for i := 0; i < 2; i++ {
+		_, err := awdv.PollBoards(context.Background(), nil)
+		assert.Equal(&dvach.PollError{Boards: []string{"a"}}, err)
+	}
+
+	// Then it is skipped without being recorded and cursors move on
+	cm.MockInfo.EXPECT().SetThreadCursor(logic.DefaultSource, "a", uint64(1), uint64(100), uint64(1)).Return(nil)
+	cm.MockInfo.EXPECT().RemoveStaleThreads(logic.DefaultSource, "a", []uint64{1}).Return(nil)
+	cm.MockInfo.EXPECT().SetBoardTimestamp(logic.DefaultSource, "a", uint64(100)).Return(nil)
+	_, err := awdv.PollBoards(context.Background(), nil)
+	assert.Nil(err)
+}
+
+func TestAPIWorkerDvach_RetryRecipients(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The same file is posted twice for different users
+	src := &postsSource{posts: []dvach.Post{
+		{Num: 1, Timestamp: 100, Comment: "cats", Files: []dvach.File{{Name: "a.png", Path: "/a.png", MD5: "abc"}}},
+		{Num: 2, Timestamp: 200, Comment: "dogs", Files: []dvach.File{{Name: "b.png", Path: "/b.png", MD5: "abc"}}},
+	}}
+	tm := mock_telegram.NewMockSender(ctrl)
+	cm := mock_controller.NewMockController(ctrl)
+	awdv := dvach.NewAPIWorkerDvach(&controller.Controller{
+		User:         cm.MockUser,
+		Subscription: cm.MockSubscription,
+		Info:         cm.MockInfo,
+		Ledger:       cm.MockLedger,
+	}, tm, map[string]dvach.Source{logic.DefaultSource: src})
+	awdv.Retries = 1
+
+	publications := []logic.Publication{
+		{ID: 1, Board: "a", Type: ".img", Tags: `"cats"`, PostMode: true},
+		{ID: 2, Board: "a", Type: ".img", Tags: `"dogs"`, PostMode: true},
+	}
+	users := []logic.User{{ID: 1, ChatID: 1}, {ID: 2, ChatID: 2}}
+
+	cm.MockSubscription.EXPECT().GetAllSubs().Return(publications).AnyTimes()
+	cm.MockUser.EXPECT().GetUsersByPublication(gomock.Eq(&publications[0])).Return(users[:1], nil).AnyTimes()
+	cm.MockUser.EXPECT().GetUsersByPublication(gomock.Eq(&publications[1])).Return(users[1:], nil).AnyTimes()
+	cm.MockInfo.EXPECT().GetBoardTimestamp(logic.DefaultSource, "a").Return(uint64(0), nil).AnyTimes()
+	cm.MockInfo.EXPECT().GetThreadCursors(logic.DefaultSource, "a").Return(map[uint64]logic.Cursor{}, nil).AnyTimes()
+	cm.MockLedger.EXPECT().PruneSent().Return(nil).AnyTimes()
+
+	// Every user has own attempts, so both of them get file twice
+	for _, user := range []*logic.User{&users[0], &users[1]} {
+		receivers := []*logic.User{user}
+		cm.MockLedger.EXPECT().FilterUnsent("md5:abc", receivers).Return(receivers, nil).Times(2)
+		tm.EXPECT().Send(gomock.Any(), receivers, gomock.Any(), gomock.Any(), "md5:abc").Return([]telegram.Result{
+			{ChatID: user.ChatID, Err: errors.New("connection reset"), Class: telegram.ClassTransient},
+		}).Times(2)
+	}
+
+	cm.MockInfo.EXPECT().SetThreadCursor(logic.DefaultSource, "a", uint64(1), uint64(0), uint64(0)).Return(nil)
+	_, err := awdv.PollBoards(context.Background(), nil)
+	assert.Equal(&dvach.PollError{Boards: []string{"a"}}, err)
+
+	cm.MockInfo.EXPECT().SetThreadCursor(logic.DefaultSource, "a", uint64(1), uint64(200), uint64(2)).Return(nil)
+	cm.MockInfo.EXPECT().RemoveStaleThreads(logic.DefaultSource, "a", []uint64{1}).Return(nil)
+	cm.MockInfo.EXPECT().SetBoardTimestamp(logic.DefaultSource, "a", uint64(200)).Return(nil)
+	_, err = awdv.PollBoards(context.Background(), nil)
+	assert.Nil(err)
+}
+
+func TestAPIWorkerDvach_Queued(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	src := &postsSource{posts: []dvach.Post{
+		{Num: 1, Timestamp: 100, Files: []dvach.File{{Name: "a.png", Path: "/a.png"}}},
+	}}
+	tm := mock_telegram.NewMockSender(ctrl)
+	cm := mock_controller.NewMockController(ctrl)
+	awdv := dvach.NewAPIWorkerDvach(&controller.Controller{
+		User:         cm.MockUser,
+		Subscription: cm.MockSubscription,
+		Info:         cm.MockInfo,
+		Ledger:       cm.MockLedger,
+	}, tm, map[string]dvach.Source{logic.DefaultSource: src})
+
+	publications := []logic.Publication{{ID: 1, Board: "a", Type: ".img", Tags: `"thread"`}}
+	users := []logic.User{{ID: 1, ChatID: 1}, {ID: 2, ChatID: 2}}
+	receivers := []*logic.User{&users[0], &users[1]}
+
+	cm.MockSubscription.EXPECT().GetAllSubs().Return(publications)
+	cm.MockUser.EXPECT().GetUsersByPublication(gomock.Any()).Return(users, nil)
+	cm.MockInfo.EXPECT().GetBoardTimestamp(logic.DefaultSource, "a").Return(uint64(0), nil)
+	cm.MockInfo.EXPECT().GetThreadCursors(logic.DefaultSource, "a").Return(map[uint64]logic.Cursor{}, nil)
+	cm.MockLedger.EXPECT().PruneSent().Return(nil)
+
+	// Queued file is recorded in ledger by outbox after it is sent, only immediate delivery is marked here
+	cm.MockLedger.EXPECT().FilterUnsent("url:/a.png", receivers).Return(receivers, nil)
+	tm.EXPECT().Send(gomock.Any(), receivers, "/a.png", gomock.Any(), "url:/a.png").Return([]telegram.Result{
+		{ChatID: 1, Queued: true},
+		{ChatID: 2, MessageID: 10},
+	})
+	cm.MockLedger.EXPECT().MarkSent("url:/a.png", receivers[1:]).Return(nil)
+
+	cm.MockInfo.EXPECT().SetThreadCursor(logic.DefaultSource, "a", uint64(1), uint64(100), uint64(1)).Return(nil)
+	cm.MockInfo.EXPECT().RemoveStaleThreads(logic.DefaultSource, "a", []uint64{1}).Return(nil)
+	cm.MockInfo.EXPECT().SetBoardTimestamp(logic.DefaultSource, "a", uint64(100)).Return(nil)
+
+	_, err := awdv.PollBoards(context.Background(), nil)
+	assert.Nil(err)
+}
+
+func TestAPIWorkerDvach_Album(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	src := &postsSource{posts: []dvach.Post{
+		{Num: 1, Timestamp: 100, Files: []dvach.File{
+			{Name: "a.png", Path: "/a.png"},
+			{Name: "b.png", Path: "/b.png", MD5: "ABC"},
+			{Name: "c.gif", Path: "/c.gif"},
+		}},
+	}}
+	tm := mock_telegram.NewMockSender(ctrl)
+	cm := mock_controller.NewMockController(ctrl)
+	awdv := dvach.NewAPIWorkerDvach(&controller.Controller{
+		User:         cm.MockUser,
+		Subscription: cm.MockSubscription,
+		Info:         cm.MockInfo,
+		Ledger:       cm.MockLedger,
+	}, tm, map[string]dvach.Source{logic.DefaultSource: src})
+
+	publications := []logic.Publication{{ID: 1, Board: "a", Type: ".img", Tags: `"thread"`}}
+	users := []logic.User{{ID: 1, ChatID: 1}, {ID: 2, ChatID: 2}}
+	receivers := []*logic.User{&users[0], &users[1]}
+	caption := dvach.FileCaption("1", []*logic.Publication{&publications[0]})
+
+	cm.MockSubscription.EXPECT().GetAllSubs().Return(publications)
+	cm.MockUser.EXPECT().GetUsersByPublication(gomock.Any()).Return(users, nil)
+	cm.MockInfo.EXPECT().GetBoardTimestamp(logic.DefaultSource, "a").Return(uint64(0), nil)
+	cm.MockInfo.EXPECT().GetThreadCursors(logic.DefaultSource, "a").Return(map[uint64]logic.Cursor{}, nil)
+	cm.MockLedger.EXPECT().PruneSent().Return(nil)
+
+	// The second user already received the first image, so it gets the second one alone
+	cm.MockLedger.EXPECT().FilterUnsent("url:/a.png", receivers).Return(receivers[:1], nil)
+	cm.MockLedger.EXPECT().FilterUnsent("md5:abc", receivers).Return(receivers, nil)
+	tm.EXPECT().SendAlbum(gomock.Any(), receivers[:1], []logic.File{
+		{URL: "/a.png", Key: "url:/a.png"},
+		{URL: "/b.png", Key: "md5:abc"},
+	}, caption).Return([][]telegram.Result{
+		{{ChatID: 1, MessageID: 10}},
+		{{ChatID: 1, MessageID: 11}},
+	})
+	tm.EXPECT().Send(gomock.Any(), receivers[1:], "/b.png", caption, "md5:abc").Return([]telegram.Result{
+		{ChatID: 2, Err: errors.New("connection reset"), Class: telegram.ClassTransient},
+	})
+	cm.MockLedger.EXPECT().MarkSent("url:/a.png", receivers[:1]).Return(nil)
+	cm.MockLedger.EXPECT().MarkSent("md5:abc", receivers[:1]).Return(nil)
+
+	cm.MockInfo.EXPECT().SetThreadCursor(logic.DefaultSource, "a", uint64(1), uint64(0), uint64(0)).Return(nil)
+
+	_, err := awdv.PollBoards(context.Background(), nil)
+	assert.Equal(&dvach.PollError{Boards: []string{"a"}}, err)
+}
+
 func Test_CheckFileExtension(t *testing.T) {
 	assert := assert.New(t)
 
@@ -187,7 +520,7 @@ func Test_CheckFileExtension(t *testing.T) {
 			args: args{
 				filename: "default.png",
 				req: dvach.SourceType{
-					Image: true,
+					"img": true,
 				},
 			},
 			want: true,
@@ -197,7 +530,27 @@ func Test_CheckFileExtension(t *testing.T) {
 			args: args{
 				filename: "default.png",
 				req: dvach.SourceType{
-					Webm: true,
+					"webm": true,
+				},
+			},
+			want: false,
+		},
+		{
+			name: "Mp4 is video",
+			args: args{
+				filename: "video.MP4",
+				req: dvach.SourceType{
+					"webm": true,
+				},
+			},
+			want: true,
+		},
+		{
+			name: "Unknown extension",
+			args: args{
+				filename: "archive.zip",
+				req: dvach.SourceType{
+					"img": true,
 				},
 			},
 			want: false,
@@ -213,7 +566,7 @@ func Test_CheckFileExtension(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := dvach.CheckFileExtension(tt.args.filename, tt.args.req)
+			result := dvach.CheckFileExtension(tt.args.filename, tt.args.req, media.Default())
 			assert.Equal(tt.want, result)
 		})
 	}
@@ -265,6 +618,101 @@ func Test_ParseKeywords(t *testing.T) {
 	}
 }
 
+func Test_ParseFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := &filter.Document{
+		Text:    "wallpaper",
+		Subject: "Desktop",
+		Op:      "wallpaper",
+		Post:    "wallpaper",
+	}
+
+	expr := dvach.ParseFilter(`subject:"desktop"&re:/^wall/`, false)
+	assert.NotNil(expr)
+	assert.True(expr.Match(doc))
+
+	// Legacy tags keep quotes and backslashes as they are
+	expr = dvach.ParseFilter(`"wall"paper"`, true)
+	assert.NotNil(expr)
+	assert.False(expr.Match(doc))
+	assert.Nil(dvach.ParseFilter(`"wall"paper"`, false))
+
+	expr = dvach.ParseFilter(`"a\\b"`, true)
+	assert.True(expr.Match(&filter.Document{Text: `a\\b`}))
+	assert.False(expr.Match(&filter.Document{Text: `a\b`}))
+	expr = dvach.ParseFilter(`"a\\b"`, false)
+	assert.True(expr.Match(&filter.Document{Text: `a\b`}))
+
+	assert.Nil(dvach.ParseFilter(`("wallpaper"`, false))
+}
+
+func Test_CheckFileLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	image := dvach.File{Width: 1920, Height: 1080, Size: 500}
+	video := dvach.File{Width: 640, Height: 480, Size: 2048, Duration: 30}
+	unknown := dvach.File{}
+
+	tests := []struct {
+		name   string
+		limits *logic.MediaLimits
+		want   []bool // For image, video and file without metadata
+	}{
+		{name: "No limits", limits: nil, want: []bool{true, true, true}},
+		{name: "Empty limits", limits: &logic.MediaLimits{}, want: []bool{true, true, true}},
+		{name: "Min resolution", limits: &logic.MediaLimits{MinWidth: 1920, MinHeight: 1080}, want: []bool{true, false, true}},
+		{name: "Max resolution", limits: &logic.MediaLimits{MaxWidth: 1280, MaxHeight: 720}, want: []bool{false, true, true}},
+		{name: "Min size", limits: &logic.MediaLimits{MinSize: 1024}, want: []bool{false, true, true}},
+		{name: "Max size", limits: &logic.MediaLimits{MaxSize: 1024}, want: []bool{true, false, true}},
+		{name: "Min duration", limits: &logic.MediaLimits{MinDuration: 60}, want: []bool{true, false, true}},
+		{name: "Max duration", limits: &logic.MediaLimits{MaxDuration: 10}, want: []bool{true, false, true}},
+		{name: "Wide", limits: &logic.MediaLimits{MinAspect: 1.5}, want: []bool{true, false, true}},
+		{name: "Narrow", limits: &logic.MediaLimits{MaxAspect: 1.5}, want: []bool{false, true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, file := range []dvach.File{image, video, unknown} {
+				assert.Equal(tt.want[i], dvach.CheckFileLimits(&file, tt.limits), i)
+			}
+		})
+	}
+}
+
+func Test_CheckThresholds(t *testing.T) {
+	assert := assert.New(t)
+
+	thread := dvach.Thread{
+		Lasthit:   9000,
+		PostCount: 50,
+		Score:     12.5,
+		Timestamp: 5000,
+		Views:     300,
+	}
+	const now = 10000
+
+	tests := []struct {
+		name string
+		pub  logic.Publication
+		want bool
+	}{
+		{name: "No thresholds", pub: logic.Publication{}, want: true},
+		{name: "Enough posts", pub: logic.Publication{MinPosts: 50}, want: true},
+		{name: "Few posts", pub: logic.Publication{MinPosts: 51}, want: false},
+		{name: "Few views", pub: logic.Publication{MinViews: 301}, want: false},
+		{name: "Low score", pub: logic.Publication{MinScore: 13}, want: false},
+		{name: "Fresh", pub: logic.Publication{MaxAge: 5000, MaxInactive: 1000}, want: true},
+		{name: "Too old", pub: logic.Publication{MaxAge: 4999}, want: false},
+		{name: "Inactive", pub: logic.Publication{MaxInactive: 999}, want: false},
+		{name: "Huge limits", pub: logic.Publication{MaxAge: math.MaxUint64, MaxInactive: math.MaxUint64}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(tt.want, dvach.CheckThresholds(&tt.pub, &thread, now))
+		})
+	}
+}
+
 func Test_ParseTypes(t *testing.T) {
 	assert := assert.New(t)
 
@@ -282,7 +730,7 @@ func Test_ParseTypes(t *testing.T) {
 				".img",
 			},
 			want: dvach.SourceType{
-				Image: true,
+				"img": true,
 			},
 		},
 		{
@@ -291,8 +739,8 @@ func Test_ParseTypes(t *testing.T) {
 				".webm.gif",
 			},
 			want: dvach.SourceType{
-				Webm: true,
-				Gif:  true,
+				"webm": true,
+				"gif":  true,
 			},
 		},
 		{
@@ -301,8 +749,8 @@ func Test_ParseTypes(t *testing.T) {
 				".gif.webm",
 			},
 			want: dvach.SourceType{
-				Webm: true,
-				Gif:  true,
+				"webm": true,
+				"gif":  true,
 			},
 		},
 		{
@@ -311,9 +759,9 @@ func Test_ParseTypes(t *testing.T) {
 				".img.webm.gif",
 			},
 			want: dvach.SourceType{
-				Image: true,
-				Webm:  true,
-				Gif:   true,
+				"img":  true,
+				"webm": true,
+				"gif":  true,
 			},
 		},
 	}
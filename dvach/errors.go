@@ -0,0 +1,68 @@
+package dvach
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// NetworkError is returned when request to external api cannot be performed
+type NetworkError struct {
+	URL string
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("request to %s failed: %s", e.URL, e.Err.Error())
+}
+
+// Unwrap returns underlying error
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned when external api responds with non-2xx status
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request to %s returned status %d", e.URL, e.StatusCode)
+}
+
+// DecodeError is returned when response body cannot be read or unmarshalled
+type DecodeError struct {
+	URL string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("cannot decode response of %s: %s", e.URL, e.Err.Error())
+}
+
+// Unwrap returns underlying error
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// PollError is returned when some boards were not fully processed during polling cycle
+type PollError struct {
+	Boards []string
+}
+
+func (e *PollError) Error() string {
+	return fmt.Sprintf("boards not fully processed: %s", strings.Join(e.Boards, ", "))
+}
+
+// Returns true if request may succeed when repeated
+func isTransient(err error) bool {
+	switch e := err.(type) {
+	case *NetworkError:
+		return true
+	case *StatusError:
+		return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+	default:
+		return false
+	}
+}
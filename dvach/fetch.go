@@ -0,0 +1,169 @@
+package dvach
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	neturl "net/url"
+	"time"
+)
+
+// RetryPolicy describes how failed requests are repeated
+type RetryPolicy struct {
+	Attempts   int           // Max amount of attempts, including the first one
+	MinBackoff time.Duration // Delay before the first retry
+	MaxBackoff time.Duration // Upper bound of delay between retries
+}
+
+// Fetcher loads json documents from external api
+type Fetcher struct {
+	Client  *http.Client
+	Retry   *RetryPolicy
+	Cache   *ResponseCache // Enables conditional requests, nil if responses are not cached
+	Limiter *HostLimiter   // Limits rate of requests, nil if requests are not limited
+	Breaker *Breaker       // Pauses requests to failing hosts, nil if failures are not tracked
+}
+
+// NewFetcher constructor for Fetcher
+func NewFetcher(client *http.Client, retry *RetryPolicy) *Fetcher {
+	return &Fetcher{
+		Client: client,
+		Retry:  retry,
+	}
+}
+
+// GetJSON loads url into v, retrying transient failures
+// Returns ErrNotModified if v was loaded from cache because document did not change
+func (f *Fetcher) GetJSON(ctx context.Context, url string, v interface{}) error {
+	host := hostOf(url)
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = f.limitedFetchJSON(ctx, host, url, v)
+		if err == nil || !isTransient(err) || attempt+1 >= f.Retry.Attempts {
+			return err
+		}
+
+		delay := f.backoff(attempt)
+		log.Printf("Retrying request to %s in %s: %s", url, delay, err.Error())
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Performs single request if host is not paused and its rate limit allows it
+func (f *Fetcher) limitedFetchJSON(ctx context.Context, host, url string, v interface{}) error {
+	if f.Breaker != nil {
+		if err := f.Breaker.Allow(host); err != nil {
+			return err
+		}
+	}
+	if f.Limiter != nil {
+		if err := f.Limiter.Wait(ctx, host); err != nil {
+			if f.Breaker != nil {
+				f.Breaker.Abort(host)
+			}
+			return &NetworkError{URL: url, Err: err}
+		}
+	}
+
+	err := f.fetchJSON(ctx, url, v)
+	if f.Breaker != nil {
+		if ctx.Err() != nil {
+			f.Breaker.Abort(host)
+		} else {
+			f.Breaker.Report(host, isTransient(err))
+		}
+	}
+	return err
+}
+
+// Returns host of url, url itself if it cannot be parsed
+func hostOf(rawurl string) string {
+	u, err := neturl.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return rawurl
+	}
+	return u.Host
+}
+
+// Performs single request and decodes response body into v
+func (f *Fetcher) fetchJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return &NetworkError{URL: url, Err: err}
+	}
+
+	var cached *CacheEntry
+	if f.Cache != nil {
+		cached = f.Cache.Get(url)
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return &NetworkError{URL: url, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		f.Cache.count(true)
+		err = json.Unmarshal(cached.Body, v)
+		if err != nil {
+			return &DecodeError{URL: url, Err: err}
+		}
+		return ErrNotModified
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return &NetworkError{URL: url, Err: err}
+	}
+
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		return &DecodeError{URL: url, Err: err}
+	}
+
+	if f.Cache != nil {
+		f.Cache.count(false)
+		etag, modified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		if etag != "" || modified != "" {
+			f.Cache.Put(url, &CacheEntry{ETag: etag, LastModified: modified, Body: body})
+		}
+	}
+
+	return nil
+}
+
+// Returns delay before retry number attempt+1
+// Exponential backoff with jitter in [delay/2, delay)
+func (f *Fetcher) backoff(attempt int) time.Duration {
+	delay := f.Retry.MinBackoff << uint(attempt)
+	if delay > f.Retry.MaxBackoff || delay <= 0 {
+		delay = f.Retry.MaxBackoff
+	}
+	if delay < 2 {
+		return delay
+	}
+
+	// nolint:gosec
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
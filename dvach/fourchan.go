@@ -0,0 +1,160 @@
+package dvach
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// FourchanURL describes endpoints of 4chan-style api
+type FourchanURL struct {
+	CatalogURL  string
+	ThreadURL   string
+	ResourceURL string
+	BoardsURL   string // List of boards, empty if api does not provide it
+}
+
+// FourchanSource gets data from 4chan-style api
+type FourchanSource struct {
+	Requests *FourchanURL
+	Fetcher  *Fetcher
+}
+
+// Page of 4chan catalog
+type fourchanPage struct {
+	Threads []fourchanPost `json:"threads"`
+}
+
+// Thread of 4chan-style api
+type fourchanThread struct {
+	Posts []fourchanPost `json:"posts"`
+}
+
+// List of boards of 4chan-style api
+type fourchanBoards struct {
+	Boards []struct {
+		Board string `json:"board"`
+		Title string `json:"title"`
+	} `json:"boards"`
+}
+
+// Post of 4chan-style api, the first post of thread also describes thread
+type fourchanPost struct {
+	No           uint64 `json:"no"`
+	Time         uint64 `json:"time"`
+	Now          string `json:"now"`
+	Subject      string `json:"sub"`
+	Comment      string `json:"com"`
+	Replies      int    `json:"replies"`
+	LastModified int64  `json:"last_modified"`
+	Tim          int64  `json:"tim"`
+	Filename     string `json:"filename"`
+	Ext          string `json:"ext"`
+	Size         int    `json:"fsize"`
+	Width        int    `json:"w"`
+	Height       int    `json:"h"`
+	MD5          string `json:"md5"`
+}
+
+// NewFourchanSource constructor for FourchanSource
+func NewFourchanSource(u *FourchanURL, f *Fetcher) *FourchanSource {
+	return &FourchanSource{
+		Requests: u,
+		Fetcher:  f,
+	}
+}
+
+// ListThreads returns threads of board
+func (s *FourchanSource) ListThreads(ctx context.Context, board string) ([]Thread, error) {
+	var pages []fourchanPage
+	err := s.Fetcher.GetJSON(ctx, fmt.Sprintf(s.Requests.CatalogURL, board), &pages)
+	if err != nil && err != ErrNotModified {
+		return nil, err
+	}
+
+	threads := make([]Thread, 0)
+	for _, page := range pages {
+		for _, op := range page.Threads {
+			threads = append(threads, Thread{
+				Comment:   op.Comment,
+				Lasthit:   op.LastModified,
+				ID:        op.No,
+				PostCount: op.Replies + 1,
+				Subject:   op.Subject,
+				Timestamp: op.Time,
+			})
+		}
+	}
+
+	return threads, err
+}
+
+// GetPosts returns posts of thread
+// 4chan api has no incremental endpoint, so whole thread is returned
+func (s *FourchanSource) GetPosts(ctx context.Context, board string, threadID, after uint64) ([]Post, error) {
+	var thread fourchanThread
+	err := s.Fetcher.GetJSON(ctx, fmt.Sprintf(s.Requests.ThreadURL, board, threadID), &thread)
+	if err != nil && err != ErrNotModified {
+		return nil, err
+	}
+
+	posts := make([]Post, len(thread.Posts))
+	for i, post := range thread.Posts {
+		posts[i] = Post{
+			Num:       post.No,
+			Comment:   post.Comment,
+			Date:      post.Now,
+			Timestamp: post.Time,
+		}
+		if post.Tim != 0 {
+			posts[i].Files = []File{
+				{
+					Name:      post.Filename + post.Ext,
+					Path:      fmt.Sprintf("/%s/%d%s", board, post.Tim, post.Ext),
+					Size:      post.Size / 1024,
+					Width:     post.Width,
+					Height:    post.Height,
+					MD5:       fourchanMD5(post.MD5),
+					Thumbnail: fmt.Sprintf("/%s/%ds.jpg", board, post.Tim),
+				},
+			}
+		}
+	}
+
+	return posts, err
+}
+
+// ListBoards returns boards of imageboard
+func (s *FourchanSource) ListBoards(ctx context.Context) ([]Board, error) {
+	if s.Requests.BoardsURL == "" {
+		return nil, ErrNotSupported
+	}
+
+	var list fourchanBoards
+	err := s.Fetcher.GetJSON(ctx, s.Requests.BoardsURL, &list)
+	if err != nil && err != ErrNotModified {
+		return nil, err
+	}
+
+	boards := make([]Board, len(list.Boards))
+	for i, board := range list.Boards {
+		boards[i] = Board{ID: board.Board, Name: board.Title}
+	}
+
+	return boards, nil
+}
+
+// Converts base64 md5 of 4chan api to hex, returns empty string if it is malformed
+func fourchanMD5(s string) string {
+	sum, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(sum)
+}
+
+// GetMediaURL returns absolute url of file
+func (s *FourchanSource) GetMediaURL(file File) string {
+	return fmt.Sprintf(s.Requests.ResourceURL, file.Path)
+}
@@ -0,0 +1,82 @@
+package dvach_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aoyako/telegram_2ch_res_bot/dvach"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFourchanSource(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/wg/catalog.json":
+			_, _ = w.Write([]byte(`[{"page":1,"threads":[{"no":10,"time":1000,"sub":"Walls","com":"wallpaper","replies":2,"last_modified":1200}]}]`))
+		case "/wg/thread/10.json":
+			_, _ = w.Write([]byte(`{"posts":[` +
+				`{"no":10,"time":1000,"now":"01/01/21","com":"wallpaper","tim":1600000000000,"filename":"city","ext":".jpg","fsize":204800,` +
+				`"w":1920,"h":1080,"md5":"1B2M2Y8AsgTpgAmY7PhCfg=="},` +
+				`{"no":11,"time":1100,"now":"01/01/21","com":"text only"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	src := dvach.NewFourchanSource(&dvach.FourchanURL{
+		CatalogURL:  server.URL + "/%s/catalog.json",
+		ThreadURL:   server.URL + "/%s/thread/%d.json",
+		ResourceURL: "https://i.4cdn.org%s",
+	}, dvach.NewFetcher(server.Client(), &dvach.RetryPolicy{Attempts: 1}))
+
+	threads, err := src.ListThreads(context.Background(), "wg")
+	assert.Nil(err)
+	assert.Equal([]dvach.Thread{
+		{
+			Comment:   "wallpaper",
+			Lasthit:   1200,
+			ID:        10,
+			PostCount: 3,
+			Subject:   "Walls",
+			Timestamp: 1000,
+		},
+	}, threads)
+
+	posts, err := src.GetPosts(context.Background(), "wg", 10, 0)
+	assert.Nil(err)
+	assert.Equal([]dvach.Post{
+		{
+			Num:       10,
+			Comment:   "wallpaper",
+			Date:      "01/01/21",
+			Timestamp: 1000,
+			Files: []dvach.File{
+				{
+					Name:      "city.jpg",
+					Path:      "/wg/1600000000000.jpg",
+					Size:      200,
+					Width:     1920,
+					Height:    1080,
+					MD5:       "d41d8cd98f00b204e9800998ecf8427e",
+					Thumbnail: "/wg/1600000000000s.jpg",
+				},
+			},
+		},
+		{
+			Num:       11,
+			Comment:   "text only",
+			Date:      "01/01/21",
+			Timestamp: 1100,
+		},
+	}, posts)
+
+	assert.Equal("https://i.4cdn.org/wg/1600000000000.jpg", src.GetMediaURL(posts[0].Files[0]))
+
+	_, err = src.GetPosts(context.Background(), "wg", 20, 0)
+	assert.NotNil(err)
+}
@@ -0,0 +1,79 @@
+package dvach
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// HostLimiter limits rate of requests to every host with token bucket
+type HostLimiter struct {
+	Rate  float64 // Requests per second, 0 means no limit
+	Burst int     // Max amount of requests performed without waiting
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// Tokens of single host
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewHostLimiter constructor for HostLimiter
+func NewHostLimiter(rate float64, burst int) *HostLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &HostLimiter{
+		Rate:    rate,
+		Burst:   burst,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Wait blocks until request to host is allowed or context is done
+func (l *HostLimiter) Wait(ctx context.Context, host string) error {
+	if l.Rate <= 0 {
+		return nil
+	}
+
+	for {
+		delay := l.reserve(host)
+		if delay == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Takes token of host, returns time to wait for it if there are no tokens
+func (l *HostLimiter) reserve(host string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[host]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		l.buckets[host] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.Rate
+	if b.tokens > float64(l.Burst) {
+		b.tokens = float64(l.Burst)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+}
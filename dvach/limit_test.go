@@ -0,0 +1,100 @@
+package dvach_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aoyako/telegram_2ch_res_bot/dvach"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostLimiter_Wait(t *testing.T) {
+	assert := assert.New(t)
+
+	limiter := dvach.NewHostLimiter(50, 2)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.Nil(limiter.Wait(context.Background(), "a"))
+	}
+	// Two requests are allowed at once, two more wait 20ms each
+	assert.GreaterOrEqual(int64(time.Since(start)), int64(30*time.Millisecond))
+
+	// Other host has own bucket
+	start = time.Now()
+	assert.Nil(limiter.Wait(context.Background(), "b"))
+	assert.Less(int64(time.Since(start)), int64(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NotNil(limiter.Wait(ctx, "a"))
+}
+
+func TestBreaker(t *testing.T) {
+	assert := assert.New(t)
+
+	notifications := make([]string, 0)
+	now := time.Unix(1000, 0)
+	breaker := dvach.NewBreaker(2, time.Minute)
+	breaker.Now = func() time.Time { return now }
+	breaker.Notify = func(text string) {
+		notifications = append(notifications, text)
+	}
+
+	assert.Nil(breaker.Allow("a"))
+	breaker.Report("a", true)
+	assert.Nil(breaker.Allow("a"))
+	breaker.Report("a", true)
+
+	var circuitErr *dvach.CircuitError
+	assert.True(errors.As(breaker.Allow("a"), &circuitErr))
+	assert.Nil(breaker.Allow("b"))
+
+	// Failed probe pauses host again
+	now = now.Add(time.Minute)
+	assert.Nil(breaker.Allow("a"))
+	assert.NotNil(breaker.Allow("a"), "single probe at a time")
+	breaker.Report("a", true)
+	assert.NotNil(breaker.Allow("a"))
+
+	now = now.Add(time.Minute)
+	assert.Nil(breaker.Allow("a"))
+	breaker.Report("a", false)
+	assert.Nil(breaker.Allow("a"))
+
+	assert.Equal([]string{
+		"Requests to a are paused for 1m0s after 2 failures",
+		"Requests to a are resumed",
+	}, notifications)
+}
+
+func TestFetcher_Breaker(t *testing.T) {
+	assert := assert.New(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	fetcher := dvach.NewFetcher(server.Client(), &dvach.RetryPolicy{
+		Attempts:   5,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond,
+	})
+	fetcher.Breaker = dvach.NewBreaker(3, time.Hour)
+
+	var list dvach.ListResponse
+	err := fetcher.GetJSON(context.Background(), server.URL+"/a", &list)
+	var circuitErr *dvach.CircuitError
+	assert.True(errors.As(err, &circuitErr), err)
+	assert.Equal(3, calls)
+
+	err = fetcher.GetJSON(context.Background(), server.URL+"/b", &list)
+	assert.True(errors.As(err, &circuitErr), err)
+	assert.Equal(3, calls)
+}
@@ -0,0 +1,104 @@
+package dvach
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// MirrorHealth describes state of mirror
+type MirrorHealth struct {
+	Failures  int   // Consecutive failed requests
+	LastError error // Error of the latest failed request
+}
+
+// MirrorSet is a list of api mirrors
+// Requests go to the current mirror, on failure the next mirrors are tried
+// and the first one which responds becomes current
+type MirrorSet struct {
+	Mirrors []RequestURL
+
+	mu      sync.Mutex
+	current int
+	health  []MirrorHealth
+}
+
+// NewMirrorSet constructor for MirrorSet
+func NewMirrorSet(mirrors []RequestURL) *MirrorSet {
+	return &MirrorSet{
+		Mirrors: mirrors,
+		health:  make([]MirrorHealth, len(mirrors)),
+	}
+}
+
+// Current returns mirror requests are sent to
+func (m *MirrorSet) Current() *RequestURL {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &m.Mirrors[m.current]
+}
+
+// Health returns state of mirrors
+func (m *MirrorSet) Health() []MirrorHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]MirrorHealth, len(m.health))
+	copy(result, m.health)
+	return result
+}
+
+// Do calls request with mirrors starting from the current one until request succeeds
+// Returns error of the last tried mirror
+func (m *MirrorSet) Do(ctx context.Context, request func(u *RequestURL) error) error {
+	m.mu.Lock()
+	start := m.current
+	m.mu.Unlock()
+
+	var err error
+	for i := 0; i < len(m.Mirrors); i++ {
+		index := (start + i) % len(m.Mirrors)
+		err = request(&m.Mirrors[index])
+		if !isMirrorFailure(ctx, err) {
+			m.report(index, nil)
+			return err
+		}
+		m.report(index, err)
+	}
+
+	return err
+}
+
+// Records result of request to mirror, successful mirror becomes current
+func (m *MirrorSet) report(index int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		m.health[index].Failures++
+		m.health[index].LastError = err
+		return
+	}
+
+	m.health[index] = MirrorHealth{}
+	if m.current != index {
+		log.Printf("Switching api mirror from %s to %s", hostOf(m.Mirrors[m.current].AllThreadsURL), hostOf(m.Mirrors[index].AllThreadsURL))
+		m.current = index
+	}
+}
+
+// Returns true if request may succeed on other mirror
+func isMirrorFailure(ctx context.Context, err error) bool {
+	if err == nil || err == ErrNotModified || err == ErrNotSupported || ctx.Err() != nil {
+		return false
+	}
+
+	// Missing thread is missing on every mirror
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,75 @@
+package dvach_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aoyako/telegram_2ch_res_bot/dvach"
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns server which responds with status while it is not 200, and counter of its requests
+func newMirrorServer(status *int) (*httptest.Server, *int) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if *status != http.StatusOK {
+			w.WriteHeader(*status)
+			return
+		}
+		_, _ = w.Write([]byte(`{"board":"a","threads":[{"num":1}]}`))
+	}))
+	return server, &calls
+}
+
+func TestAPIRequester_Mirrors(t *testing.T) {
+	assert := assert.New(t)
+
+	mainStatus, reserveStatus := http.StatusBadGateway, http.StatusOK
+	main, mainCalls := newMirrorServer(&mainStatus)
+	defer main.Close()
+	reserve, reserveCalls := newMirrorServer(&reserveStatus)
+	defer reserve.Close()
+
+	mirrors := dvach.NewMirrorSet([]dvach.RequestURL{
+		{AllThreadsURL: main.URL + "/%s/threads.json", ResourceURL: main.URL + "%s"},
+		{AllThreadsURL: reserve.URL + "/%s/threads.json", ResourceURL: reserve.URL + "%s"},
+	})
+	requester := dvach.NewMirrorRequester(mirrors, dvach.NewFetcher(http.DefaultClient, &dvach.RetryPolicy{Attempts: 1}))
+	want := dvach.ListResponse{Board: "a", Threads: []dvach.Thread{{ID: 1}}}
+
+	list, err := requester.GetAllThreads(context.Background(), "a")
+	assert.Nil(err)
+	assert.Equal(want, list)
+	assert.Equal(1, mirrors.Health()[0].Failures)
+	assert.Equal(reserve.URL+"/src/1.png", requester.GetResourceURL("/src/1.png"))
+
+	// Healthy mirror is kept even when main one recovers
+	mainStatus = http.StatusOK
+	_, err = requester.GetAllThreads(context.Background(), "a")
+	assert.Nil(err)
+	assert.Equal(1, *mainCalls)
+	assert.Equal(2, *reserveCalls)
+
+	// Missing board is not looked for on other mirrors
+	reserveStatus = http.StatusNotFound
+	_, err = requester.GetAllThreads(context.Background(), "a")
+	assert.NotNil(err)
+	assert.Equal(1, *mainCalls)
+
+	reserveStatus = http.StatusServiceUnavailable
+	list, err = requester.GetAllThreads(context.Background(), "a")
+	assert.Nil(err)
+	assert.Equal(want, list)
+	assert.Equal(2, *mainCalls)
+	assert.Equal(main.URL+"/src/1.png", requester.GetResourceURL("/src/1.png"))
+	assert.Equal([]dvach.MirrorHealth{{}, {Failures: 1, LastError: mirrors.Health()[1].LastError}}, mirrors.Health())
+
+	// Error of the last mirror is returned when all of them fail
+	mainStatus = http.StatusBadGateway
+	_, err = requester.GetAllThreads(context.Background(), "a")
+	assert.NotNil(err)
+	assert.Equal(main.URL+"/src/1.png", requester.GetResourceURL("/src/1.png"))
+}
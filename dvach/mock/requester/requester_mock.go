@@ -5,6 +5,7 @@
 package mock_dvach
 
 import (
+	context "context"
 	dvach "github.com/aoyako/telegram_2ch_res_bot/dvach"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
@@ -34,17 +35,18 @@ func (m *MockRequester) EXPECT() *MockRequesterMockRecorder {
 }
 
 // GetAllThreads mocks base method
-func (m *MockRequester) GetAllThreads(arg0 string) dvach.ListResponse {
+func (m *MockRequester) GetAllThreads(arg0 context.Context, arg1 string) (dvach.ListResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllThreads", arg0)
+	ret := m.ctrl.Call(m, "GetAllThreads", arg0, arg1)
 	ret0, _ := ret[0].(dvach.ListResponse)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetAllThreads indicates an expected call of GetAllThreads
-func (mr *MockRequesterMockRecorder) GetAllThreads(arg0 interface{}) *gomock.Call {
+func (mr *MockRequesterMockRecorder) GetAllThreads(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllThreads", reflect.TypeOf((*MockRequester)(nil).GetAllThreads), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllThreads", reflect.TypeOf((*MockRequester)(nil).GetAllThreads), arg0, arg1)
 }
 
 // GetResourceURL mocks base method
@@ -62,15 +64,16 @@ func (mr *MockRequesterMockRecorder) GetResourceURL(arg0 interface{}) *gomock.Ca
 }
 
 // GetThread mocks base method
-func (m *MockRequester) GetThread(arg0, arg1 string) dvach.ThreadData {
+func (m *MockRequester) GetThread(arg0 context.Context, arg1, arg2 string) (dvach.ThreadData, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetThread", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetThread", arg0, arg1, arg2)
 	ret0, _ := ret[0].(dvach.ThreadData)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetThread indicates an expected call of GetThread
-func (mr *MockRequesterMockRecorder) GetThread(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockRequesterMockRecorder) GetThread(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetThread", reflect.TypeOf((*MockRequester)(nil).GetThread), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetThread", reflect.TypeOf((*MockRequester)(nil).GetThread), arg0, arg1, arg2)
 }
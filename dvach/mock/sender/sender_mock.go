@@ -5,7 +5,9 @@
 package mock_telegram
 
 import (
+	context "context"
 	logic "github.com/aoyako/telegram_2ch_res_bot/logic"
+	telegram "github.com/aoyako/telegram_2ch_res_bot/telegram"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
 )
@@ -34,13 +36,29 @@ func (m *MockSender) EXPECT() *MockSenderMockRecorder {
 }
 
 // Send mocks base method
-func (m *MockSender) Send(arg0 []*logic.User, arg1, arg2 string) {
+func (m *MockSender) Send(arg0 context.Context, arg1 []*logic.User, arg2, arg3, arg4 string) []telegram.Result {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Send", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "Send", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].([]telegram.Result)
+	return ret0
 }
 
 // Send indicates an expected call of Send
-func (mr *MockSenderMockRecorder) Send(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockSenderMockRecorder) Send(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockSender)(nil).Send), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockSender)(nil).Send), arg0, arg1, arg2, arg3, arg4)
+}
+
+// SendAlbum mocks base method
+func (m *MockSender) SendAlbum(arg0 context.Context, arg1 []*logic.User, arg2 []logic.File, arg3 string) [][]telegram.Result {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendAlbum", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([][]telegram.Result)
+	return ret0
+}
+
+// SendAlbum indicates an expected call of SendAlbum
+func (mr *MockSenderMockRecorder) SendAlbum(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendAlbum", reflect.TypeOf((*MockSender)(nil).SendAlbum), arg0, arg1, arg2, arg3)
 }
@@ -0,0 +1,98 @@
+package dvach
+
+import (
+	"context"
+	"sync"
+)
+
+// Pool limits amount of threads fetched and delivered at the same time
+// Zero limit means no limit
+type Pool struct {
+	global   chan struct{}
+	perBoard int
+
+	mu     sync.Mutex
+	boards map[boardKey]chan struct{}
+}
+
+// NewPool constructor for Pool
+// global limits threads of all boards, perBoard limits threads of a single board
+func NewPool(global, perBoard int) *Pool {
+	p := &Pool{
+		perBoard: perBoard,
+		boards:   make(map[boardKey]chan struct{}),
+	}
+	if global > 0 {
+		p.global = make(chan struct{}, global)
+	}
+	return p
+}
+
+// Returns semaphore of board, nil if boards are not limited
+func (p *Pool) board(key boardKey) chan struct{} {
+	if p.perBoard <= 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sem, ok := p.boards[key]
+	if !ok {
+		sem = make(chan struct{}, p.perBoard)
+		p.boards[key] = sem
+	}
+	return sem
+}
+
+// Waits for free slot of board and then for global one
+// Returns false if context was cancelled before slots were taken
+func (p *Pool) acquire(ctx context.Context, key boardKey) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	sem := p.board(key)
+	if sem != nil {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	if p.global != nil {
+		select {
+		case p.global <- struct{}{}:
+		case <-ctx.Done():
+			if sem != nil {
+				<-sem
+			}
+			return false
+		}
+	}
+
+	return true
+}
+
+// Frees slots taken by acquire
+func (p *Pool) release(key boardKey) {
+	if p.global != nil {
+		<-p.global
+	}
+	if sem := p.board(key); sem != nil {
+		<-sem
+	}
+}
+
+// Calls f in a new goroutine once slots are free
+// If context is cancelled first, cancelled is called instead
+func (p *Pool) run(ctx context.Context, key boardKey, f func(), cancelled func()) {
+	go func() {
+		if !p.acquire(ctx, key) {
+			cancelled()
+			return
+		}
+		defer p.release(key)
+		f()
+	}()
+}
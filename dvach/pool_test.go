@@ -0,0 +1,140 @@
+package dvach_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aoyako/telegram_2ch_res_bot/controller"
+	"github.com/aoyako/telegram_2ch_res_bot/dvach"
+	mock_telegram "github.com/aoyako/telegram_2ch_res_bot/dvach/mock/sender"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	mock_controller "github.com/aoyako/telegram_2ch_res_bot/telegram/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Source counting threads fetched at the same time
+type concurrencySource struct {
+	threads int
+
+	mu        sync.Mutex
+	active    map[string]int
+	total     int
+	maxBoard  int
+	maxGlobal int
+	fetched   int
+}
+
+func (s *concurrencySource) ListThreads(ctx context.Context, board string) ([]dvach.Thread, error) {
+	threads := make([]dvach.Thread, s.threads)
+	for i := range threads {
+		threads[i] = dvach.Thread{ID: uint64(i + 1), Comment: "thread"}
+	}
+	return threads, nil
+}
+
+func (s *concurrencySource) GetPosts(ctx context.Context, board string, threadID, after uint64) ([]dvach.Post, error) {
+	s.mu.Lock()
+	s.active[board]++
+	s.total++
+	s.fetched++
+	if s.active[board] > s.maxBoard {
+		s.maxBoard = s.active[board]
+	}
+	if s.total > s.maxGlobal {
+		s.maxGlobal = s.total
+	}
+	s.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	s.mu.Lock()
+	s.active[board]--
+	s.total--
+	s.mu.Unlock()
+	return nil, nil
+}
+
+func (s *concurrencySource) GetMediaURL(file dvach.File) string {
+	return file.Path
+}
+
+func TestAPIWorkerDvach_Pool(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	publications := []logic.Publication{
+		{ID: 1, Board: "a", Type: ".img", Tags: `"thread"`},
+		{ID: 2, Board: "b", Type: ".img", Tags: `"thread"`},
+	}
+
+	tests := []struct {
+		name         string
+		global       int
+		perBoard     int
+		cancelled    bool
+		wantBoard    int
+		wantGlobal   int
+		wantFetched  int
+		wantAdvanced bool
+	}{
+		{
+			name:         "Limited",
+			global:       3,
+			perBoard:     2,
+			wantBoard:    2,
+			wantGlobal:   3,
+			wantFetched:  20,
+			wantAdvanced: true,
+		},
+		{
+			name:         "Cancelled",
+			global:       3,
+			perBoard:     2,
+			cancelled:    true,
+			wantFetched:  0,
+			wantAdvanced: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &concurrencySource{threads: 10, active: make(map[string]int)}
+			tm := mock_telegram.NewMockSender(ctrl)
+			cm := mock_controller.NewMockController(ctrl)
+			awdv := dvach.NewAPIWorkerDvach(&controller.Controller{
+				User:         cm.MockUser,
+				Subscription: cm.MockSubscription,
+				Info:         cm.MockInfo,
+				Ledger:       cm.MockLedger,
+			}, tm, map[string]dvach.Source{logic.DefaultSource: src})
+			awdv.Pool = dvach.NewPool(tt.global, tt.perBoard)
+
+			cm.MockSubscription.EXPECT().GetAllSubs().Return(publications)
+			for i := range publications {
+				cm.MockUser.EXPECT().GetUsersByPublication(gomock.Eq(&publications[i])).Return([]logic.User{{ID: 1, ChatID: 1}}, nil)
+			}
+			cm.MockInfo.EXPECT().GetBoardTimestamp(logic.DefaultSource, gomock.Any()).Return(uint64(0), nil).Times(2)
+			cm.MockInfo.EXPECT().GetThreadCursors(logic.DefaultSource, gomock.Any()).Return(map[uint64]logic.Cursor{}, nil).Times(2)
+			if tt.wantAdvanced {
+				cm.MockInfo.EXPECT().RemoveStaleThreads(logic.DefaultSource, gomock.Any(), gomock.Any()).Return(nil).Times(2)
+				cm.MockInfo.EXPECT().SetBoardTimestamp(logic.DefaultSource, gomock.Any(), uint64(0)).Return(nil).Times(2)
+			}
+			cm.MockLedger.EXPECT().PruneSent().Return(nil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+			awdv.InitiateSending(ctx)
+
+			assert.LessOrEqual(src.maxBoard, tt.wantBoard)
+			assert.LessOrEqual(src.maxGlobal, tt.wantGlobal)
+			assert.Equal(tt.wantFetched, src.fetched)
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package dvach
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Fixture directory layout mirrors 2ch api:
+// <dir>/<board>/threads.json and <dir>/<board>/res/<thread>.json
+
+// RecordingRequester saves responses of wrapped requester to fixture directory
+type RecordingRequester struct {
+	Requester
+	Dir string
+	m   sync.Mutex
+}
+
+// NewRecordingRequester constructor for RecordingRequester
+func NewRecordingRequester(req Requester, dir string) *RecordingRequester {
+	return &RecordingRequester{
+		Requester: req,
+		Dir:       dir,
+	}
+}
+
+// GetAllThreads returns list of all threads on board and records it
+// Threads are merged with already recorded ones, so replay can show threads which fell off the board
+func (r *RecordingRequester) GetAllThreads(ctx context.Context, board string) (ListResponse, error) {
+	list, err := r.Requester.GetAllThreads(ctx, board)
+	if err != nil {
+		return list, err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	path := boardFixturePath(r.Dir, board)
+	var recorded ListResponse
+	if err := readFixture(path, &recorded); err != nil && !os.IsNotExist(err) {
+		return list, err
+	}
+
+	recorded.Board = list.Board
+	for _, thread := range list.Threads {
+		found := false
+		for i := range recorded.Threads {
+			if recorded.Threads[i].ID == thread.ID {
+				recorded.Threads[i] = thread
+				found = true
+				break
+			}
+		}
+		if !found {
+			recorded.Threads = append(recorded.Threads, thread)
+		}
+	}
+
+	return list, writeFixture(path, &recorded)
+}
+
+// GetThread returns list of posts in the thread and records it
+// Posts newer than already recorded ones are appended to fixture
+func (r *RecordingRequester) GetThread(ctx context.Context, board, threadID string) (ThreadData, error) {
+	threadData, err := r.Requester.GetThread(ctx, board, threadID)
+	if err != nil || len(threadData.ThreadPosts) == 0 {
+		return threadData, err
+	}
+
+	return threadData, r.recordPosts(board, threadID, threadData.ThreadPosts[0].Posts)
+}
+
+// GetPostsAfter returns posts of thread starting from num and records them
+// ErrNotSupported is returned if wrapped requester does not support it
+func (r *RecordingRequester) GetPostsAfter(ctx context.Context, board, threadID string, num uint64) ([]Post, error) {
+	inc, ok := r.Requester.(IncrementalRequester)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	posts, err := inc.GetPostsAfter(ctx, board, threadID, num)
+	if err != nil || len(posts) == 0 {
+		return posts, err
+	}
+
+	return posts, r.recordPosts(board, threadID, posts)
+}
+
+// GetBoards returns list of boards of wrapped requester
+// ErrNotSupported is returned if wrapped requester does not support it
+func (r *RecordingRequester) GetBoards(ctx context.Context) ([]Board, error) {
+	req, ok := r.Requester.(BoardsRequester)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	return req.GetBoards(ctx)
+}
+
+// Appends posts newer than already recorded ones to thread fixture
+func (r *RecordingRequester) recordPosts(board, threadID string, posts []Post) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	path := threadFixturePath(r.Dir, board, threadID)
+	var recorded ThreadData
+	if err := readFixture(path, &recorded); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if len(recorded.ThreadPosts) == 0 {
+		recorded.ThreadPosts = []ThreadPost{{}}
+	}
+
+	recordedPosts := recorded.ThreadPosts[0].Posts
+	var last uint64
+	if len(recordedPosts) != 0 {
+		last = recordedPosts[len(recordedPosts)-1].Timestamp
+	}
+	for _, post := range posts {
+		if post.Timestamp > last {
+			recordedPosts = append(recordedPosts, post)
+		}
+	}
+	recorded.ThreadPosts[0].Posts = recordedPosts
+
+	return writeFixture(path, &recorded)
+}
+
+// ReplayRequester serves recorded responses from fixture directory
+type ReplayRequester struct {
+	Dir         string
+	ResourceURL string
+	Clock       func() uint64 // Returns simulated time, later threads and posts are hidden; nil shows everything
+}
+
+// NewReplayRequester constructor for ReplayRequester
+func NewReplayRequester(dir, resourceURL string, clock func() uint64) *ReplayRequester {
+	return &ReplayRequester{
+		Dir:         dir,
+		ResourceURL: resourceURL,
+		Clock:       clock,
+	}
+}
+
+// GetAllThreads returns recorded threads of board created before simulated time
+func (r *ReplayRequester) GetAllThreads(ctx context.Context, board string) (ListResponse, error) {
+	path := boardFixturePath(r.Dir, board)
+
+	var list ListResponse
+	if err := readFixture(path, &list); err != nil {
+		return ListResponse{}, &NetworkError{URL: path, Err: err}
+	}
+
+	if r.Clock == nil {
+		return list, nil
+	}
+
+	now := r.Clock()
+	threads := make([]Thread, 0, len(list.Threads))
+	for _, thread := range list.Threads {
+		if thread.Timestamp <= now {
+			threads = append(threads, thread)
+		}
+	}
+	list.Threads = threads
+
+	return list, nil
+}
+
+// GetThread returns recorded posts of thread created before simulated time
+func (r *ReplayRequester) GetThread(ctx context.Context, board, threadID string) (ThreadData, error) {
+	path := threadFixturePath(r.Dir, board, threadID)
+
+	var threadData ThreadData
+	if err := readFixture(path, &threadData); err != nil {
+		return ThreadData{}, &NetworkError{URL: path, Err: err}
+	}
+
+	if r.Clock == nil {
+		return threadData, nil
+	}
+
+	now := r.Clock()
+	for i := range threadData.ThreadPosts {
+		posts := make([]Post, 0, len(threadData.ThreadPosts[i].Posts))
+		for _, post := range threadData.ThreadPosts[i].Posts {
+			if post.Timestamp <= now {
+				posts = append(posts, post)
+			}
+		}
+		threadData.ThreadPosts[i].Posts = posts
+	}
+
+	return threadData, nil
+}
+
+// GetResourceURL converts relative resource path to absolute
+func (r *ReplayRequester) GetResourceURL(path string) string {
+	return fmt.Sprintf(r.ResourceURL, path)
+}
+
+// NewSimulatedClock returns clock, which starts at start and runs speed times faster than real one
+func NewSimulatedClock(start uint64, speed float64) func() uint64 {
+	launch := time.Now()
+	return func() uint64 {
+		return start + uint64(time.Since(launch).Seconds()*speed)
+	}
+}
+
+// Returns path of board's threads fixture
+func boardFixturePath(dir, board string) string {
+	return filepath.Join(dir, board, "threads.json")
+}
+
+// Returns path of thread's posts fixture
+func threadFixturePath(dir, board, threadID string) string {
+	return filepath.Join(dir, board, "res", threadID+".json")
+}
+
+// Reads fixture at path into v
+func readFixture(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// Writes v as fixture at path
+func writeFixture(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
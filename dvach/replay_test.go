@@ -0,0 +1,263 @@
+package dvach_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/aoyako/telegram_2ch_res_bot/controller"
+	"github.com/aoyako/telegram_2ch_res_bot/dvach"
+	mock_telegram "github.com/aoyako/telegram_2ch_res_bot/dvach/mock/sender"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/telegram"
+	mock_controller "github.com/aoyako/telegram_2ch_res_bot/telegram/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+const replayFixtures = "testdata/replay"
+
+func TestReplayRequester_Clock(t *testing.T) {
+	assert := assert.New(t)
+
+	var now uint64 = 1050
+	req := dvach.NewReplayRequester(replayFixtures, "https://2ch.hk%s", func() uint64 { return now })
+
+	list, err := req.GetAllThreads(context.Background(), "a")
+	assert.Nil(err)
+	assert.Len(list.Threads, 1)
+	assert.Equal(uint64(100), list.Threads[0].ID)
+
+	thread, err := req.GetThread(context.Background(), "a", "100")
+	assert.Nil(err)
+	assert.Len(thread.ThreadPosts[0].Posts, 1)
+
+	now = 2000
+	list, err = req.GetAllThreads(context.Background(), "a")
+	assert.Nil(err)
+	assert.Len(list.Threads, 2)
+
+	_, err = req.GetThread(context.Background(), "a", "999")
+	assert.NotNil(err)
+}
+
+func TestRecordingRequester(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	var now uint64 = 1050
+	source := dvach.NewReplayRequester(replayFixtures, "%s", func() uint64 { return now })
+	recorder := dvach.NewRecordingRequester(source, dir)
+
+	for _, now = range []uint64{1050, 2000} {
+		_, err := recorder.GetAllThreads(context.Background(), "a")
+		assert.Nil(err)
+		_, err = recorder.GetThread(context.Background(), "a", "100")
+		assert.Nil(err)
+	}
+
+	original := dvach.NewReplayRequester(replayFixtures, "%s", nil)
+	recorded := dvach.NewReplayRequester(dir, "%s", nil)
+
+	wantList, _ := original.GetAllThreads(context.Background(), "a")
+	gotList, err := recorded.GetAllThreads(context.Background(), "a")
+	assert.Nil(err)
+	assert.Equal(wantList, gotList)
+
+	wantThread, _ := original.GetThread(context.Background(), "a", "100")
+	gotThread, err := recorded.GetThread(context.Background(), "a", "100")
+	assert.Nil(err)
+	assert.Equal(wantThread, gotThread)
+}
+
+// Replayed api which supports incremental requests and list of boards
+type fullRequester struct {
+	*dvach.ReplayRequester
+}
+
+func (r fullRequester) GetPostsAfter(ctx context.Context, board, threadID string, num uint64) ([]dvach.Post, error) {
+	// Fixtures have no post numbers, every post is returned
+	threadData, err := r.GetThread(ctx, board, threadID)
+	if err != nil {
+		return nil, err
+	}
+	return threadData.ThreadPosts[0].Posts, nil
+}
+
+func (r fullRequester) GetBoards(ctx context.Context) ([]dvach.Board, error) {
+	return []dvach.Board{{ID: "a", Name: "Аниме"}}, nil
+}
+
+func TestRecordingRequester_Optional(t *testing.T) {
+	assert := assert.New(t)
+
+	// Wrapped requester does not support optional requests
+	recorder := dvach.NewRecordingRequester(dvach.NewReplayRequester(replayFixtures, "%s", nil), t.TempDir())
+	_, err := recorder.GetPostsAfter(context.Background(), "a", "100", 1)
+	assert.Equal(dvach.ErrNotSupported, err)
+	_, err = recorder.GetBoards(context.Background())
+	assert.Equal(dvach.ErrNotSupported, err)
+
+	dir := t.TempDir()
+	original := dvach.NewReplayRequester(replayFixtures, "%s", nil)
+	src := dvach.NewDvachSource(dvach.NewRecordingRequester(fullRequester{original}, dir))
+
+	boards, err := src.ListBoards(context.Background())
+	assert.Nil(err)
+	assert.Equal([]dvach.Board{{ID: "a", Name: "Аниме"}}, boards)
+
+	// Incremental requests are recorded
+	want, _ := original.GetThread(context.Background(), "a", "100")
+	posts, err := src.GetPosts(context.Background(), "a", 100, 1)
+	assert.Nil(err)
+	assert.Equal(want.ThreadPosts[0].Posts, posts)
+
+	got, err := dvach.NewReplayRequester(dir, "%s", nil).GetThread(context.Background(), "a", "100")
+	assert.Nil(err)
+	assert.Equal(want.ThreadPosts[0].Posts, got.ThreadPosts[0].Posts)
+}
+
+// Runs polling cycles against fixtures while simulated time goes on
+func TestAPIWorkerDvach_Replay(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	publications := []logic.Publication{
+		{ID: 1, Board: "a", Type: ".img", Tags: `"cats"`},
+		{ID: 2, Board: "a", Type: ".img.webm", Tags: `"dogs"`},
+		{ID: 3, Board: "a", Type: ".img.webm", Tags: `"video"|subject:"dogs"`, PostMode: true},
+		{ID: 4, Board: "a", Type: ".img", Tags: `subject:"dogs"`, Alias: "dog threads"},
+	}
+	users := [][]logic.User{
+		{{ID: 1, ChatID: 1}},
+		{{ID: 2, ChatID: 2}},
+		{{ID: 3, ChatID: 3}},
+		{{ID: 2, ChatID: 2}},
+	}
+
+	var now uint64
+	req := dvach.NewReplayRequester(replayFixtures, "https://2ch.hk%s", func() uint64 { return now })
+
+	tm := mock_telegram.NewMockSender(ctrl)
+	cm := mock_controller.NewMockController(ctrl)
+	awdv := dvach.NewAPIWorkerDvach(&controller.Controller{
+		User:         cm.MockUser,
+		Subscription: cm.MockSubscription,
+		Info:         cm.MockInfo,
+		Ledger:       cm.MockLedger,
+	}, tm, map[string]dvach.Source{logic.DefaultSource: dvach.NewDvachSource(req)})
+
+	var m sync.Mutex
+	boardCursor := uint64(900)
+	threadCursors := make(map[uint64]logic.Cursor)
+	sent := make(map[int64][]string)
+	captions := make(map[int64][]string)
+	delivered := make(map[string]map[int64]bool)
+
+	cm.MockSubscription.EXPECT().GetAllSubs().Return(publications).AnyTimes()
+	for i := range publications {
+		cm.MockUser.EXPECT().GetUsersByPublication(gomock.Eq(&publications[i])).Return(users[i], nil).AnyTimes()
+	}
+	cm.MockInfo.EXPECT().GetBoardTimestamp(logic.DefaultSource, "a").DoAndReturn(func(_, _ string) (uint64, error) {
+		return boardCursor, nil
+	}).AnyTimes()
+	cm.MockInfo.EXPECT().SetBoardTimestamp(logic.DefaultSource, "a", gomock.Any()).DoAndReturn(func(_, _ string, tsp uint64) error {
+		if tsp > boardCursor {
+			boardCursor = tsp
+		}
+		return nil
+	}).AnyTimes()
+	cm.MockInfo.EXPECT().GetThreadCursors(logic.DefaultSource, "a").DoAndReturn(func(_, _ string) (map[uint64]logic.Cursor, error) {
+		m.Lock()
+		defer m.Unlock()
+		result := make(map[uint64]logic.Cursor)
+		for k, v := range threadCursors {
+			result[k] = v
+		}
+		return result, nil
+	}).AnyTimes()
+	cm.MockInfo.EXPECT().SetThreadCursor(logic.DefaultSource, "a", gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_, _ string, threadID, tsp, num uint64) error {
+		m.Lock()
+		defer m.Unlock()
+		threadCursors[threadID] = logic.Cursor{Thread: threadID, LastPost: tsp, LastNum: num}
+		return nil
+	}).AnyTimes()
+	cm.MockInfo.EXPECT().RemoveStaleThreads(logic.DefaultSource, "a", gomock.Any()).Return(nil).AnyTimes()
+	cm.MockLedger.EXPECT().FilterUnsent(gomock.Any(), gomock.Any()).DoAndReturn(func(key string, users []*logic.User) ([]*logic.User, error) {
+		m.Lock()
+		defer m.Unlock()
+		result := make([]*logic.User, 0)
+		for _, user := range users {
+			if !delivered[key][user.ChatID] {
+				result = append(result, user)
+			}
+		}
+		return result, nil
+	}).AnyTimes()
+	cm.MockLedger.EXPECT().MarkSent(gomock.Any(), gomock.Any()).DoAndReturn(func(key string, users []*logic.User) error {
+		m.Lock()
+		defer m.Unlock()
+		if delivered[key] == nil {
+			delivered[key] = make(map[int64]bool)
+		}
+		for _, user := range users {
+			delivered[key][user.ChatID] = true
+		}
+		return nil
+	}).AnyTimes()
+	cm.MockLedger.EXPECT().PruneSent().Return(nil).AnyTimes()
+	tm.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, users []*logic.User, path, caption, key string) []telegram.Result {
+		m.Lock()
+		defer m.Unlock()
+		for _, user := range users {
+			sent[user.ChatID] = append(sent[user.ChatID], path)
+			captions[user.ChatID] = append(captions[user.ChatID], caption)
+		}
+		return sentResults(users)
+	}).AnyTimes()
+
+	cycles := []struct {
+		now  uint64
+		want map[int64][]string
+	}{
+		{
+			now: 1050,
+			want: map[int64][]string{
+				1: {"https://2ch.hk/a/src/100/first.png"},
+			},
+		},
+		{
+			now: 1200,
+			want: map[int64][]string{
+				3: {"https://2ch.hk/a/src/100/second.webm"},
+			},
+		},
+		{
+			// third.jpg is a repost of first.png
+			now: 1700,
+			want: map[int64][]string{
+				2: {"https://2ch.hk/a/src/200/dog.png"},
+				3: {"https://2ch.hk/a/src/200/dog.png"},
+			},
+		},
+		{
+			now:  1800,
+			want: map[int64][]string{},
+		},
+	}
+
+	for _, cycle := range cycles {
+		now = cycle.now
+		sent = make(map[int64][]string)
+		captions = make(map[int64][]string)
+
+		awdv.InitiateSending(context.Background())
+
+		assert.Equal(cycle.want, sent, now)
+		if now == 1700 {
+			// User 2 requested dog.png by two publications
+			assert.Equal([]string{"200\n\"dogs\", dog threads"}, captions[2])
+		}
+	}
+}
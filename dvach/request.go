@@ -1,89 +1,110 @@
 package dvach
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
 )
 
 // RequestURL describes endpoints of external api
 type RequestURL struct {
-	AllThreadsURL string
-	ThreadURL     string
-	ResourceURL   string
+	AllThreadsURL  string `mapstructure:"all"`
+	ThreadURL      string `mapstructure:"thread"`
+	ThreadAfterURL string `mapstructure:"thread_after"` // Posts of thread starting from number, empty if api does not support it
+	ResourceURL    string `mapstructure:"resource"`
+	BoardsURL      string `mapstructure:"boards"` // List of boards, empty if api does not provide it
 }
 
 // Requester gets data from external sources
 type Requester interface {
-	GetAllThreads(board string) ListResponse
-	GetThread(board, threadID string) ThreadData
+	GetAllThreads(ctx context.Context, board string) (ListResponse, error)
+	GetThread(ctx context.Context, board, threadID string) (ThreadData, error)
 	GetResourceURL(path string) string
 }
 
+// IncrementalRequester gets only new posts of thread
+type IncrementalRequester interface {
+	GetPostsAfter(ctx context.Context, board, threadID string, num uint64) ([]Post, error)
+}
+
+// BoardsRequester gets list of boards
+type BoardsRequester interface {
+	GetBoards(ctx context.Context) ([]Board, error)
+}
+
+// ErrNotSupported is returned when api does not provide requested endpoint
+var ErrNotSupported = errors.New("not supported by api")
+
 // APIRequester gets data from 2ch
 type APIRequester struct {
-	Requests *RequestURL
+	Mirrors *MirrorSet
+	Fetcher *Fetcher
 }
 
-// NewRequester constructor for APIRequester
-func NewRequester(u *RequestURL) *APIRequester {
+// NewRequester constructor for APIRequester with single mirror
+func NewRequester(u *RequestURL, f *Fetcher) *APIRequester {
+	return NewMirrorRequester(NewMirrorSet([]RequestURL{*u}), f)
+}
+
+// NewMirrorRequester constructor for APIRequester which fails over between mirrors
+func NewMirrorRequester(mirrors *MirrorSet, f *Fetcher) *APIRequester {
 	return &APIRequester{
-		Requests: u,
+		Mirrors: mirrors,
+		Fetcher: f,
 	}
 }
 
 // GetAllThreads returns list of all threads on board
-func (r *APIRequester) GetAllThreads(board string) ListResponse {
-	resp, err := http.Get(fmt.Sprintf(r.Requests.AllThreadsURL, board))
-	if err != nil {
-		log.Printf("Error creating request to 2ch: %s", err.Error())
-		return ListResponse{}
-	}
-	defer resp.Body.Close()
-
+func (r *APIRequester) GetAllThreads(ctx context.Context, board string) (ListResponse, error) {
 	var list ListResponse
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Error reading request body")
-		return ListResponse{}
-	}
-
-	err = json.Unmarshal(body, &list)
-	if err != nil {
-		log.Printf("Error unmarshalling board request body: %s", err.Error())
-		return ListResponse{}
-	}
+	err := r.Mirrors.Do(ctx, func(u *RequestURL) error {
+		list = ListResponse{}
+		return r.Fetcher.GetJSON(ctx, fmt.Sprintf(u.AllThreadsURL, board), &list)
+	})
 
-	return list
+	return list, err
 }
 
 // GetThread returns list of posts in the thread with id = threadID
-func (r *APIRequester) GetThread(board, threadID string) ThreadData {
-	resp, err := http.Get(fmt.Sprintf(r.Requests.ThreadURL, board, threadID))
-	if err != nil {
-		log.Printf("Error creating request to 2ch.hk: %s", err.Error())
-		return ThreadData{}
-	}
-	defer resp.Body.Close()
-
+func (r *APIRequester) GetThread(ctx context.Context, board, threadID string) (ThreadData, error) {
 	var threadData ThreadData
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Error reading request body")
-		return ThreadData{}
-	}
-	err = json.Unmarshal(body, &threadData)
-	if err != nil {
-		log.Printf("Error unmarshalling thread request body: %s", err.Error())
-		return ThreadData{}
-	}
+	err := r.Mirrors.Do(ctx, func(u *RequestURL) error {
+		threadData = ThreadData{}
+		return r.Fetcher.GetJSON(ctx, fmt.Sprintf(u.ThreadURL, board, threadID), &threadData)
+	})
+
+	return threadData, err
+}
+
+// GetPostsAfter returns posts of the thread starting from post number num
+func (r *APIRequester) GetPostsAfter(ctx context.Context, board, threadID string, num uint64) ([]Post, error) {
+	var posts PostsResponse
+	err := r.Mirrors.Do(ctx, func(u *RequestURL) error {
+		if u.ThreadAfterURL == "" {
+			return ErrNotSupported
+		}
+		posts = PostsResponse{}
+		return r.Fetcher.GetJSON(ctx, fmt.Sprintf(u.ThreadAfterURL, board, threadID, num), &posts)
+	})
+
+	return posts.Posts, err
+}
+
+// GetBoards returns list of boards
+func (r *APIRequester) GetBoards(ctx context.Context) ([]Board, error) {
+	var boards []Board
+	err := r.Mirrors.Do(ctx, func(u *RequestURL) error {
+		if u.BoardsURL == "" {
+			return ErrNotSupported
+		}
+		boards = nil
+		return r.Fetcher.GetJSON(ctx, u.BoardsURL, &boards)
+	})
 
-	return threadData
+	return boards, err
 }
 
-// GetResourceURL converts relative resource path to absolute
+// GetResourceURL converts relative resource path to absolute with the current mirror
 func (r *APIRequester) GetResourceURL(path string) string {
-	return fmt.Sprintf(r.Requests.ResourceURL, path)
+	return fmt.Sprintf(r.Mirrors.Current().ResourceURL, path)
 }
@@ -0,0 +1,183 @@
+package dvach_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aoyako/telegram_2ch_res_bot/dvach"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIRequester_GetAllThreads(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name      string
+		responses []int
+		body      string
+		attempts  int
+		wantCalls int
+		wantErr   interface{}
+		want      dvach.ListResponse
+	}{
+		{
+			name:      "Success",
+			responses: []int{http.StatusOK},
+			body:      `{"board":"a","threads":[{"num":1,"comment":"abc"}]}`,
+			attempts:  3,
+			wantCalls: 1,
+			want: dvach.ListResponse{
+				Board:   "a",
+				Threads: []dvach.Thread{{ID: 1, Comment: "abc"}},
+			},
+		},
+		{
+			name:      "Retry transient status",
+			responses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			body:      `{"board":"a","threads":[]}`,
+			attempts:  3,
+			wantCalls: 2,
+			want: dvach.ListResponse{
+				Board:   "a",
+				Threads: []dvach.Thread{},
+			},
+		},
+		{
+			name:      "Retries exhausted",
+			responses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			attempts:  3,
+			wantCalls: 3,
+			wantErr:   new(*dvach.StatusError),
+		},
+		{
+			name:      "Not found is not retried",
+			responses: []int{http.StatusNotFound},
+			attempts:  3,
+			wantCalls: 1,
+			wantErr:   new(*dvach.StatusError),
+		},
+		{
+			name:      "Bad body",
+			responses: []int{http.StatusOK},
+			body:      `{"board":`,
+			attempts:  3,
+			wantCalls: 1,
+			wantErr:   new(*dvach.DecodeError),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.responses[calls])
+				calls++
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			requester := dvach.NewRequester(&dvach.RequestURL{
+				AllThreadsURL: server.URL + "/%s/threads.json",
+			}, dvach.NewFetcher(server.Client(), &dvach.RetryPolicy{
+				Attempts:   tt.attempts,
+				MinBackoff: time.Millisecond,
+				MaxBackoff: 2 * time.Millisecond,
+			}))
+
+			list, err := requester.GetAllThreads(context.Background(), "a")
+
+			assert.Equal(tt.wantCalls, calls)
+			if tt.wantErr != nil {
+				assert.True(errors.As(err, tt.wantErr), err)
+				return
+			}
+			assert.Nil(err)
+			assert.Equal(tt.want, list)
+		})
+	}
+}
+
+func TestDvachSource_GetPosts(t *testing.T) {
+	assert := assert.New(t)
+
+	const thread = `{"threads":[{"posts":[{"num":100,"comment":"op"},{"num":101,"comment":"reply"}]}]}`
+	const after = `{"posts":[{"num":101,"comment":"reply"}]}`
+
+	tests := []struct {
+		name     string
+		afterURL string
+		after    uint64
+		status   int
+		want     []string
+		wantURLs []string
+	}{
+		{
+			name:     "Incremental",
+			afterURL: "/after/%s/%s/%d",
+			after:    101,
+			status:   http.StatusOK,
+			want:     []string{"reply"},
+			wantURLs: []string{"/after/a/100/101"},
+		},
+		{
+			name:     "Unknown number",
+			afterURL: "/after/%s/%s/%d",
+			status:   http.StatusOK,
+			want:     []string{"op", "reply"},
+			wantURLs: []string{"/a/res/100.json"},
+		},
+		{
+			name:     "Not configured",
+			after:    101,
+			status:   http.StatusOK,
+			want:     []string{"op", "reply"},
+			wantURLs: []string{"/a/res/100.json"},
+		},
+		{
+			name:     "Fallback to whole thread",
+			afterURL: "/after/%s/%s/%d",
+			after:    101,
+			status:   http.StatusNotFound,
+			want:     []string{"op", "reply"},
+			wantURLs: []string{"/after/a/100/101", "/a/res/100.json"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls := make([]string, 0)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				urls = append(urls, r.URL.Path)
+				if r.URL.Path == "/a/res/100.json" {
+					_, _ = w.Write([]byte(thread))
+					return
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(after))
+			}))
+			defer server.Close()
+
+			requestURL := &dvach.RequestURL{ThreadURL: server.URL + "/%s/res/%s.json"}
+			if tt.afterURL != "" {
+				requestURL.ThreadAfterURL = server.URL + tt.afterURL
+			}
+			src := dvach.NewDvachSource(dvach.NewRequester(requestURL, dvach.NewFetcher(server.Client(), &dvach.RetryPolicy{
+				Attempts: 1,
+			})))
+
+			posts, err := src.GetPosts(context.Background(), "a", 100, tt.after)
+			assert.Nil(err)
+
+			comments := make([]string, len(posts))
+			for i := range posts {
+				comments[i] = posts[i].Comment
+			}
+			assert.Equal(tt.want, comments)
+			assert.Equal(tt.wantURLs, urls)
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package dvach
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Status describes polling cycle
+type Status struct {
+	Start    time.Time
+	End      time.Time
+	Duration time.Duration
+	Boards   []string // Boards polled during cycle
+	Err      error    // Error of cycle, nil if every board was processed
+}
+
+// Scheduler starts polling cycles of worker, cycles never overlap
+// Board is polled when its interval has passed since it was polled last time
+// If MinInterval and MaxInterval are set, intervals of boards without own one adapt to board activity
+type Scheduler struct {
+	Worker      APIWorker
+	Interval    time.Duration            // Interval of boards without own one
+	Boards      map[string]time.Duration // Intervals by board, e.g. "b" or "4chan/g"
+	MinInterval time.Duration            // Bounds of adaptive intervals
+	MaxInterval time.Duration
+	Busy        int // Bumped threads per poll after which board is polled twice as often
+	Now         func() time.Time
+
+	mu       sync.Mutex
+	running  bool
+	last     Status
+	polled   map[string]time.Time     // Start of cycle which polled board last time
+	adaptive map[string]time.Duration // Current adaptive intervals by board
+}
+
+// NewScheduler constructor for Scheduler
+func NewScheduler(worker APIWorker, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		Worker:   worker,
+		Interval: interval,
+		Boards:   make(map[string]time.Duration),
+		Busy:     10,
+		Now:      time.Now,
+		polled:   make(map[string]time.Time),
+		adaptive: make(map[string]time.Duration),
+	}
+}
+
+// Returns true if intervals adapt to activity
+func (s *Scheduler) isAdaptive() bool {
+	return s.MinInterval > 0 && s.MaxInterval >= s.MinInterval
+}
+
+// Limits interval by adaptive bounds
+func (s *Scheduler) clamp(interval time.Duration) time.Duration {
+	if interval < s.MinInterval {
+		return s.MinInterval
+	}
+	if interval > s.MaxInterval {
+		return s.MaxInterval
+	}
+	return interval
+}
+
+// Returns how often due boards are looked for
+func (s *Scheduler) tick() time.Duration {
+	tick := s.Interval
+	if s.isAdaptive() && s.MinInterval < tick {
+		tick = s.MinInterval
+	}
+	for _, interval := range s.Boards {
+		if interval < tick {
+			tick = interval
+		}
+	}
+	return tick
+}
+
+// Returns polling interval of board
+func (s *Scheduler) interval(board string) time.Duration {
+	if interval, ok := s.Boards[board]; ok {
+		return interval
+	}
+	if !s.isAdaptive() {
+		return s.Interval
+	}
+	if interval, ok := s.adaptive[board]; ok {
+		return interval
+	}
+	return s.clamp(s.Interval)
+}
+
+// Updates adaptive interval of board after poll
+// Busy board is polled twice as often, board without changes twice as rarely
+func (s *Scheduler) adapt(board string, activity Activity) {
+	if !s.isAdaptive() {
+		return
+	}
+	if _, ok := s.Boards[board]; ok {
+		return
+	}
+
+	interval := s.interval(board)
+	switch {
+	case activity.Threads >= s.Busy:
+		interval /= 2
+	case activity.Threads == 0 && activity.Posts == 0:
+		interval *= 2
+	}
+	s.adaptive[board] = s.clamp(interval)
+}
+
+// BoardInterval returns current polling interval of board
+func (s *Scheduler) BoardInterval(board string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interval(board)
+}
+
+// Run starts cycles until context is done
+// Tick which fires while cycle is running is delayed until cycle ends
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.tick())
+	defer ticker.Stop()
+
+	for {
+		s.RunCycle(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunCycle polls boards which are due
+// Returns false if another cycle is running
+func (s *Scheduler) RunCycle(ctx context.Context) bool {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return false
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	start := s.Now()
+	// Ticks are not precise, board which is due in less than half of tick is polled now
+	tolerance := s.tick() / 2
+	boards := make([]string, 0)
+	activity, err := s.Worker.PollBoards(ctx, func(board string) bool {
+		last, ok := s.polled[board]
+		if ok && start.Sub(last)+tolerance < s.interval(board) {
+			return false
+		}
+		s.polled[board] = start
+		boards = append(boards, board)
+		return true
+	})
+	end := s.Now()
+
+	if err != nil {
+		log.Printf("Polling cycle failed: %s", err.Error())
+	}
+
+	s.mu.Lock()
+	for board := range activity {
+		s.adapt(board, activity[board])
+	}
+	s.running = false
+	s.last = Status{
+		Start:    start,
+		End:      end,
+		Duration: end.Sub(start),
+		Boards:   boards,
+		Err:      err,
+	}
+	s.mu.Unlock()
+
+	return true
+}
+
+// Status returns the last finished cycle and whether a cycle is running now
+func (s *Scheduler) Status() (Status, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last, s.running
+}
@@ -0,0 +1,224 @@
+package dvach_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aoyako/telegram_2ch_res_bot/controller"
+	"github.com/aoyako/telegram_2ch_res_bot/dvach"
+	mock_telegram "github.com/aoyako/telegram_2ch_res_bot/dvach/mock/sender"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	mock_controller "github.com/aoyako/telegram_2ch_res_bot/telegram/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Worker polling fixed boards
+type boardsWorker struct {
+	boards   []string
+	polled   [][]string
+	activity map[string]dvach.Activity
+	err      error
+	release  chan struct{} // Blocks polling until closed, nil if polling is not blocked
+	started  chan struct{}
+}
+
+func (w *boardsWorker) InitiateSending(ctx context.Context) {
+	_, _ = w.PollBoards(ctx, nil)
+}
+
+func (w *boardsWorker) PollBoards(ctx context.Context, due func(board string) bool) (map[string]dvach.Activity, error) {
+	polled := make([]string, 0)
+	for _, board := range w.boards {
+		if due(board) {
+			polled = append(polled, board)
+		}
+	}
+	w.polled = append(w.polled, polled)
+
+	activity := make(map[string]dvach.Activity)
+	for _, board := range polled {
+		if a, ok := w.activity[board]; ok {
+			activity[board] = a
+		}
+	}
+
+	if w.release != nil {
+		close(w.started)
+		<-w.release
+	}
+	return activity, w.err
+}
+
+func TestScheduler_RunCycle(t *testing.T) {
+	assert := assert.New(t)
+
+	worker := &boardsWorker{boards: []string{"a", "b", "4chan/g"}}
+	scheduler := dvach.NewScheduler(&dvach.APIController{APIWorker: worker}, 10*time.Minute)
+	scheduler.Boards["b"] = time.Minute
+
+	now := time.Unix(1000, 0)
+	scheduler.Now = func() time.Time { return now }
+
+	for _, step := range []time.Duration{0, time.Minute, time.Minute, 8 * time.Minute} {
+		now = now.Add(step)
+		assert.True(scheduler.RunCycle(context.Background()))
+	}
+
+	assert.Equal([][]string{
+		{"a", "b", "4chan/g"},
+		{"b"},
+		{"b"},
+		{"a", "b", "4chan/g"},
+	}, worker.polled)
+
+	worker.err = &dvach.PollError{Boards: []string{"b"}}
+	now = now.Add(time.Minute)
+	assert.True(scheduler.RunCycle(context.Background()))
+
+	status, running := scheduler.Status()
+	assert.False(running)
+	assert.Equal(now, status.Start)
+	assert.Equal([]string{"b"}, status.Boards)
+	assert.True(errors.Is(status.Err, worker.err))
+}
+
+func TestScheduler_RunCycleOverlap(t *testing.T) {
+	assert := assert.New(t)
+
+	worker := &boardsWorker{
+		boards:  []string{"a"},
+		release: make(chan struct{}),
+		started: make(chan struct{}),
+	}
+	scheduler := dvach.NewScheduler(&dvach.APIController{APIWorker: worker}, time.Minute)
+
+	done := make(chan bool)
+	go func() {
+		done <- scheduler.RunCycle(context.Background())
+	}()
+
+	<-worker.started
+	_, running := scheduler.Status()
+	assert.True(running)
+	assert.False(scheduler.RunCycle(context.Background()))
+
+	close(worker.release)
+	assert.True(<-done)
+	assert.Len(worker.polled, 1)
+}
+
+func TestScheduler_Run(t *testing.T) {
+	worker := &boardsWorker{boards: []string{"a"}}
+	scheduler := dvach.NewScheduler(&dvach.APIController{APIWorker: worker}, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	scheduler.Run(ctx)
+
+	assert.Len(t, worker.polled, 1)
+}
+
+func TestScheduler_Adaptive(t *testing.T) {
+	assert := assert.New(t)
+
+	worker := &boardsWorker{boards: []string{"b", "a", "fixed"}}
+	scheduler := dvach.NewScheduler(&dvach.APIController{APIWorker: worker}, 4*time.Minute)
+	scheduler.Boards["fixed"] = 3 * time.Minute
+	scheduler.MinInterval = time.Minute
+	scheduler.MaxInterval = 10 * time.Minute
+	scheduler.Busy = 5
+
+	now := time.Unix(1000, 0)
+	scheduler.Now = func() time.Time { return now }
+
+	worker.activity = map[string]dvach.Activity{
+		"b":     {Threads: 20, Posts: 100},
+		"a":     {},
+		"fixed": {},
+	}
+
+	wantB := []time.Duration{2 * time.Minute, time.Minute, time.Minute}
+	wantA := []time.Duration{8 * time.Minute, 10 * time.Minute, 10 * time.Minute}
+	for i := range wantB {
+		now = now.Add(10 * time.Minute)
+		assert.True(scheduler.RunCycle(context.Background()))
+		assert.Equal(wantB[i], scheduler.BoardInterval("b"))
+		assert.Equal(wantA[i], scheduler.BoardInterval("a"))
+		assert.Equal(3*time.Minute, scheduler.BoardInterval("fixed"))
+	}
+
+	worker.activity["b"] = dvach.Activity{Threads: 2, Posts: 3}
+	now = now.Add(10 * time.Minute)
+	assert.True(scheduler.RunCycle(context.Background()))
+	assert.Equal(time.Minute, scheduler.BoardInterval("b"))
+}
+
+// Source with threads bumped long ago
+type bumpedSource struct {
+	threads []dvach.Thread
+}
+
+func (s *bumpedSource) ListThreads(ctx context.Context, board string) ([]dvach.Thread, error) {
+	return s.threads, nil
+}
+
+func (s *bumpedSource) GetPosts(ctx context.Context, board string, threadID, after uint64) ([]dvach.Post, error) {
+	return nil, nil
+}
+
+func (s *bumpedSource) GetMediaURL(file dvach.File) string {
+	return file.Path
+}
+
+func TestScheduler_AdaptiveNoMatches(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	src := &bumpedSource{}
+	for i := 1; i <= 12; i++ {
+		src.threads = append(src.threads, dvach.Thread{ID: uint64(i), Comment: "thread", Lasthit: int64(500 + i)})
+	}
+	cm := mock_controller.NewMockController(ctrl)
+	worker := dvach.NewAPIWorkerDvach(&controller.Controller{
+		User:         cm.MockUser,
+		Subscription: cm.MockSubscription,
+		Info:         cm.MockInfo,
+		Ledger:       cm.MockLedger,
+	}, mock_telegram.NewMockSender(ctrl), map[string]dvach.Source{logic.DefaultSource: src})
+
+	// Board cursor does not move, because no thread matches
+	publications := []logic.Publication{{ID: 1, Board: "a", Type: ".img", Tags: `"missing"`}}
+	cm.MockSubscription.EXPECT().GetAllSubs().Return(publications).AnyTimes()
+	cm.MockUser.EXPECT().GetUsersByPublication(gomock.Any()).Return([]logic.User{{ID: 1, ChatID: 1}}, nil).AnyTimes()
+	cm.MockLedger.EXPECT().PruneSent().Return(nil).AnyTimes()
+	cm.MockInfo.EXPECT().GetBoardTimestamp(logic.DefaultSource, "a").Return(uint64(0), nil).AnyTimes()
+	cm.MockInfo.EXPECT().GetThreadCursors(logic.DefaultSource, "a").Return(map[uint64]logic.Cursor{}, nil).AnyTimes()
+	cm.MockInfo.EXPECT().RemoveStaleThreads(logic.DefaultSource, "a", gomock.Any()).Return(nil).AnyTimes()
+	cm.MockInfo.EXPECT().SetBoardTimestamp(logic.DefaultSource, "a", uint64(0)).Return(nil).AnyTimes()
+
+	scheduler := dvach.NewScheduler(&dvach.APIController{APIWorker: worker}, 4*time.Minute)
+	scheduler.MinInterval = time.Minute
+	scheduler.MaxInterval = 10 * time.Minute
+	scheduler.Busy = 5
+
+	now := time.Unix(1000, 0)
+	scheduler.Now = func() time.Time { return now }
+
+	// Threads bumped before the first poll are not counted again
+	for _, want := range []time.Duration{4 * time.Minute, 8 * time.Minute, 10 * time.Minute} {
+		assert.True(scheduler.RunCycle(context.Background()))
+		assert.Equal(want, scheduler.BoardInterval("a"))
+		now = now.Add(10 * time.Minute)
+	}
+
+	// Bumps since previous poll are counted
+	for i := 0; i < 6; i++ {
+		src.threads[i].Lasthit += 1000
+	}
+	assert.True(scheduler.RunCycle(context.Background()))
+	assert.Equal(5*time.Minute, scheduler.BoardInterval("a"))
+}
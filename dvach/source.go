@@ -0,0 +1,87 @@
+package dvach
+
+import (
+	"context"
+	"log"
+	"strconv"
+)
+
+// Source provides threads and posts of an imageboard
+// Methods return data with ErrNotModified if it did not change since previous request
+type Source interface {
+	ListThreads(ctx context.Context, board string) ([]Thread, error)                    // Returns threads of board
+	GetPosts(ctx context.Context, board string, threadID, after uint64) ([]Post, error) // Returns posts of thread, may skip posts with number up to after
+	GetMediaURL(file File) string                                                       // Returns absolute url of file
+}
+
+// BoardLister is implemented by sources which provide list of their boards
+type BoardLister interface {
+	ListBoards(ctx context.Context) ([]Board, error) // Returns boards of imageboard
+}
+
+// DvachSource adapts 2ch requester to Source
+type DvachSource struct {
+	Requester Requester
+}
+
+// NewDvachSource constructor for DvachSource
+func NewDvachSource(req Requester) *DvachSource {
+	return &DvachSource{
+		Requester: req,
+	}
+}
+
+// ListThreads returns threads of board
+func (s *DvachSource) ListThreads(ctx context.Context, board string) ([]Thread, error) {
+	list, err := s.Requester.GetAllThreads(ctx, board)
+	if err != nil && err != ErrNotModified {
+		return nil, err
+	}
+
+	return list.Threads, err
+}
+
+// GetPosts returns posts of thread
+// If after is known and requester supports it, only posts starting from after are requested
+func (s *DvachSource) GetPosts(ctx context.Context, board string, threadID, after uint64) ([]Post, error) {
+	if inc, ok := s.Requester.(IncrementalRequester); ok && after != 0 {
+		posts, err := inc.GetPostsAfter(ctx, board, strconv.FormatUint(threadID, 10), after)
+		if err == nil || err == ErrNotModified {
+			return posts, err
+		}
+		if err != ErrNotSupported {
+			log.Printf("Error getting posts of %s/%d after %d, loading whole thread: %s", board, threadID, after, err.Error())
+		}
+	}
+
+	threadData, err := s.Requester.GetThread(ctx, board, strconv.FormatUint(threadID, 10))
+	if err != nil && err != ErrNotModified {
+		return nil, err
+	}
+
+	if len(threadData.ThreadPosts) == 0 {
+		return nil, err
+	}
+
+	return threadData.ThreadPosts[0].Posts, err
+}
+
+// ListBoards returns boards of imageboard
+func (s *DvachSource) ListBoards(ctx context.Context) ([]Board, error) {
+	req, ok := s.Requester.(BoardsRequester)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	boards, err := req.GetBoards(ctx)
+	if err != nil && err != ErrNotModified {
+		return nil, err
+	}
+
+	return boards, nil
+}
+
+// GetMediaURL returns absolute url of file
+func (s *DvachSource) GetMediaURL(file File) string {
+	return s.Requester.GetResourceURL(file.Path)
+}
@@ -0,0 +1,105 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Field selects text of document checked by predicate
+type Field int
+
+// Fields of document
+const (
+	FieldText    Field = iota // Default text, used when field is not specified
+	FieldSubject              // Thread subject, "subject:"
+	FieldOp                   // Comment of the first post of thread, "op:"
+	FieldPost                 // Comment of checked post, "post:"
+)
+
+// Names of fields in expression
+var fieldNames = map[string]Field{
+	"subject": FieldSubject,
+	"op":      FieldOp,
+	"post":    FieldPost,
+}
+
+// Document is a set of texts expression is checked against
+type Document struct {
+	Text    string // Text checked by predicates without field
+	Subject string // Thread subject
+	Op      string // Comment of the first post of thread
+	Post    string // Comment of checked post
+}
+
+// Returns text of field
+func (d *Document) field(f Field) string {
+	switch f {
+	case FieldSubject:
+		return d.Subject
+	case FieldOp:
+		return d.Op
+	case FieldPost:
+		return d.Post
+	default:
+		return d.Text
+	}
+}
+
+// Expr is a node of parsed filter expression
+type Expr interface {
+	Match(doc *Document) bool // Returns true if document satisfies expression
+}
+
+// And matches if both operands match
+type And struct {
+	Left  Expr
+	Right Expr
+}
+
+// Or matches if any operand matches
+type Or struct {
+	Left  Expr
+	Right Expr
+}
+
+// Not matches if operand does not match
+type Not struct {
+	Expr Expr
+}
+
+// Keyword matches field containing value, case insensitive
+type Keyword struct {
+	Field Field
+	Value string
+}
+
+// Regexp matches field containing match of regular expression
+type Regexp struct {
+	Field Field
+	Re    *regexp.Regexp
+}
+
+// Match returns true if both operands match document
+func (e *And) Match(doc *Document) bool {
+	return e.Left.Match(doc) && e.Right.Match(doc)
+}
+
+// Match returns true if any operand matches document
+func (e *Or) Match(doc *Document) bool {
+	return e.Left.Match(doc) || e.Right.Match(doc)
+}
+
+// Match returns true if operand does not match document
+func (e *Not) Match(doc *Document) bool {
+	return !e.Expr.Match(doc)
+}
+
+// Match returns true if field contains keyword
+func (e *Keyword) Match(doc *Document) bool {
+	return strings.Contains(strings.ToLower(doc.field(e.Field)), strings.ToLower(e.Value))
+}
+
+// Match returns true if field contains match of expression
+func (e *Regexp) Match(doc *Document) bool {
+	return e.Re.MatchString(doc.field(e.Field))
+}
@@ -0,0 +1,59 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Format of tags accepted before the expression grammar was introduced
+var legacyFormat = regexp.MustCompile(`^(!?".+"[|&])*!?"[^&|]+"$`)
+
+// IsLegacy returns true if s has the format of tags
+// accepted before the expression grammar was introduced
+func IsLegacy(s string) bool {
+	return legacyFormat.MatchString(s)
+}
+
+// ParseLegacy parses s the way tags were parsed before the expression grammar:
+// string is split by `"|` and `"&` only, so keywords may contain quotes
+func ParseLegacy(s string) Expr {
+	var result Expr
+	for _, dis := range strings.Split(s, `"|`) {
+		var conj Expr
+		for _, con := range strings.Split(dis, `"&`) {
+			var term Expr
+			if strings.HasPrefix(con, "!") {
+				term = &Not{Expr: &Keyword{Value: strings.TrimPrefix(con, `!"`)}}
+			} else {
+				term = &Keyword{Value: strings.TrimPrefix(con, `"`)}
+			}
+			if conj == nil {
+				conj = term
+			} else {
+				conj = &And{Left: conj, Right: term}
+			}
+		}
+		if result == nil {
+			result = conj
+		} else {
+			result = &Or{Left: result, Right: conj}
+		}
+	}
+
+	trimLastQuote(result)
+	return result
+}
+
+// Removes closing quote from the rightmost keyword
+func trimLastQuote(e Expr) {
+	switch e := e.(type) {
+	case *Or:
+		trimLastQuote(e.Right)
+	case *And:
+		trimLastQuote(e.Right)
+	case *Not:
+		trimLastQuote(e.Expr)
+	case *Keyword:
+		e.Value = strings.TrimSuffix(e.Value, `"`)
+	}
+}
@@ -0,0 +1,341 @@
+// Package filter implements language of subscription tags
+//
+// Grammar:
+//
+//	expr    = and { "|" and }
+//	and     = unary { "&" unary }
+//	unary   = "!" unary | primary
+//	primary = "(" expr ")" | [ field ":" ] ( string | regexp )
+//	field   = "subject" | "op" | "post"
+//	string  = '"' { char | '\"' | '\\' } '"'
+//	regexp  = "re:/" { char | '\/' } "/" { "i" | "m" | "s" }
+//
+// Negation binds tighter than conjunction, conjunction tighter than disjunction,
+// so strings like "cats"|"dogs"&!"big" keep their meaning.
+// Predicates without field are checked against default text of document.
+// Spaces between tokens are ignored.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// SyntaxError describes malformed filter expression
+type SyntaxError struct {
+	Pos int    // Position of error in expression, starting from 1
+	Msg string // Error description
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenString
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+	tokenField
+	tokenRegexp
+	tokenIllegal
+)
+
+// Lexical token of expression
+type token struct {
+	kind  tokenKind
+	value string // Unescaped string, field name, regexp or error message for tokenIllegal
+	flags string // Flags of regexp
+	pos   int    // Index of the first rune
+}
+
+// Splits expression into tokens
+type lexer struct {
+	input []rune
+	pos   int
+}
+
+// Returns next token of input
+func (l *lexer) next() token {
+	for l.pos < len(l.input) && (l.input[l.pos] == ' ' || l.input[l.pos] == '\t') {
+		l.pos++
+	}
+
+	start := l.pos
+	if l.pos >= len(l.input) {
+		return token{kind: tokenEOF, pos: start}
+	}
+
+	kinds := map[rune]tokenKind{
+		'&': tokenAnd,
+		'|': tokenOr,
+		'!': tokenNot,
+		'(': tokenLParen,
+		')': tokenRParen,
+	}
+
+	r := l.input[l.pos]
+	if kind, ok := kinds[r]; ok {
+		l.pos++
+		return token{kind: kind, value: string(r), pos: start}
+	}
+
+	if unicode.IsLetter(r) {
+		return l.readWord()
+	}
+
+	if r != '"' {
+		return token{kind: tokenIllegal, value: fmt.Sprintf("unexpected %q", r), pos: start}
+	}
+
+	var value strings.Builder
+	for l.pos++; l.pos < len(l.input); l.pos++ {
+		switch r := l.input[l.pos]; {
+		case r == '"':
+			l.pos++
+			return token{kind: tokenString, value: value.String(), pos: start}
+		case r == '\\' && l.pos+1 < len(l.input) && (l.input[l.pos+1] == '"' || l.input[l.pos+1] == '\\'):
+			l.pos++
+			value.WriteRune(l.input[l.pos])
+		default:
+			value.WriteRune(r)
+		}
+	}
+
+	l.pos = start
+	return token{kind: tokenIllegal, value: "unterminated string", pos: start}
+}
+
+// Reads field name or regexp, the word must be followed by colon
+func (l *lexer) readWord() token {
+	start := l.pos
+	for l.pos < len(l.input) && unicode.IsLetter(l.input[l.pos]) {
+		l.pos++
+	}
+	name := string(l.input[start:l.pos])
+
+	if l.pos >= len(l.input) || l.input[l.pos] != ':' {
+		l.pos = start
+		return token{kind: tokenIllegal, value: fmt.Sprintf("unexpected %q", l.input[start]), pos: start}
+	}
+	l.pos++
+
+	if name == "re" {
+		return l.readRegexp(start)
+	}
+
+	if _, ok := fieldNames[name]; !ok {
+		l.pos = start
+		return token{kind: tokenIllegal, value: fmt.Sprintf("unknown field %q", name), pos: start}
+	}
+
+	return token{kind: tokenField, value: name, pos: start}
+}
+
+// Reads regexp between slashes and its flags
+func (l *lexer) readRegexp(start int) token {
+	if l.pos >= len(l.input) || l.input[l.pos] != '/' {
+		l.pos = start
+		return token{kind: tokenIllegal, value: "expected / after re:", pos: start}
+	}
+
+	var value strings.Builder
+	for l.pos++; l.pos < len(l.input); l.pos++ {
+		switch r := l.input[l.pos]; {
+		case r == '/':
+			l.pos++
+			flagsStart := l.pos
+			for l.pos < len(l.input) && strings.ContainsRune("ims", l.input[l.pos]) {
+				l.pos++
+			}
+			return token{
+				kind:  tokenRegexp,
+				value: value.String(),
+				flags: string(l.input[flagsStart:l.pos]),
+				pos:   start,
+			}
+		case r == '\\' && l.pos+1 < len(l.input) && l.input[l.pos+1] == '/':
+			l.pos++
+			value.WriteRune('/')
+		default:
+			value.WriteRune(r)
+		}
+	}
+
+	l.pos = start
+	return token{kind: tokenIllegal, value: "unterminated regexp", pos: start}
+}
+
+// Recursive descent parser of expression
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// Parse parses whole string s into expression
+func Parse(s string) (Expr, error) {
+	p := newParser(s)
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+
+	return expr, nil
+}
+
+// ParsePrefix parses the longest expression at the beginning of s
+// and returns text after it with leading spaces removed
+func ParsePrefix(s string) (Expr, string, error) {
+	p := newParser(s)
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return expr, string(p.lex.input[p.tok.pos:]), nil
+}
+
+// Returns parser positioned at the first token of s
+func newParser(s string) *parser {
+	p := &parser{lex: &lexer{input: []rune(s)}}
+	p.advance()
+	return p
+}
+
+// Moves to the next token
+func (p *parser) advance() {
+	p.tok = p.lex.next()
+}
+
+// Returns error about current token
+func (p *parser) unexpected() error {
+	switch p.tok.kind {
+	case tokenEOF:
+		return &SyntaxError{Pos: p.tok.pos + 1, Msg: "unexpected end of expression"}
+	case tokenIllegal:
+		return &SyntaxError{Pos: p.tok.pos + 1, Msg: p.tok.value}
+	default:
+		return &SyntaxError{Pos: p.tok.pos + 1, Msg: fmt.Sprintf("unexpected %q", p.tok.value)}
+	}
+}
+
+// expr = and { "|" and }
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokenOr {
+		p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// and = unary { "&" unary }
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokenAnd {
+		p.advance()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// unary = "!" unary | primary
+func (p *parser) parseUnary() (Expr, error) {
+	if p.tok.kind == tokenNot {
+		p.advance()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: expr}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+// primary = "(" expr ")" | [ field ":" ] ( string | regexp )
+func (p *parser) parsePrimary() (Expr, error) {
+	switch p.tok.kind {
+	case tokenLParen:
+		open := p.tok
+		p.advance()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokenRParen {
+			if p.tok.kind == tokenEOF {
+				return nil, &SyntaxError{Pos: open.pos + 1, Msg: "unclosed parenthesis"}
+			}
+			return nil, p.unexpected()
+		}
+		p.advance()
+		return expr, nil
+	case tokenField:
+		field := fieldNames[p.tok.value]
+		p.advance()
+		if p.tok.kind != tokenString && p.tok.kind != tokenRegexp {
+			return nil, p.unexpected()
+		}
+		return p.parsePredicate(field)
+	case tokenString, tokenRegexp:
+		return p.parsePredicate(FieldText)
+	default:
+		return nil, p.unexpected()
+	}
+}
+
+// Builds predicate of current string or regexp token checking field
+func (p *parser) parsePredicate(field Field) (Expr, error) {
+	tok := p.tok
+	if tok.value == "" {
+		return nil, &SyntaxError{Pos: tok.pos + 1, Msg: "empty keyword"}
+	}
+	p.advance()
+
+	if tok.kind == tokenString {
+		return &Keyword{Field: field, Value: tok.value}, nil
+	}
+
+	pattern := tok.value
+	if tok.flags != "" {
+		pattern = "(?" + tok.flags + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, &SyntaxError{Pos: tok.pos + 1, Msg: err.Error()}
+	}
+
+	return &Regexp{Field: field, Re: re}, nil
+}
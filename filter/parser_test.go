@@ -0,0 +1,219 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/aoyako/telegram_2ch_res_bot/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns document with default text s
+func text(s string) *filter.Document {
+	return &filter.Document{Text: s}
+}
+
+func TestParse_Match(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name   string
+		expr   string
+		inputs []string
+		want   []bool
+	}{
+		{
+			name:   "Precedence",
+			expr:   `"ac"|"ab"&"bc"|"a"&!"z"`,
+			inputs: []string{`acz`, `abc`, `ab`, `abz`, `bc`, `bcz`},
+			want:   []bool{true, true, true, false, false, false},
+		},
+		{
+			name:   "Parentheses",
+			expr:   `("cat" | "dog") & !"big"`,
+			inputs: []string{`Cat`, `small dog`, `big cat`, `bird`},
+			want:   []bool{true, true, false, false},
+		},
+		{
+			name:   "Double negation",
+			expr:   `!!"a"`,
+			inputs: []string{`a`, `b`},
+			want:   []bool{true, false},
+		},
+		{
+			name:   "Escaped quote",
+			expr:   `"say \"hi\""`,
+			inputs: []string{`they say "hi"`, `say hi`},
+			want:   []bool{true, false},
+		},
+		{
+			name:   "Escaped backslash",
+			expr:   `"a\\b"`,
+			inputs: []string{`a\b`, `ab`},
+			want:   []bool{true, false},
+		},
+		{
+			name:   "Operators inside string",
+			expr:   `"a|b&!(c)"`,
+			inputs: []string{`a|b&!(c)`, `a`},
+			want:   []bool{true, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := filter.Parse(tt.expr)
+			assert.Nil(err)
+			for i := range tt.inputs {
+				assert.Equal(tt.want[i], expr.Match(text(tt.inputs[i])), tt.inputs[i])
+			}
+		})
+	}
+}
+
+func TestParse_Fields(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := &filter.Document{
+		Text:    "Thread about cats",
+		Subject: "Wallpaper thread #42",
+		Op:      "Thread about cats",
+		Post:    "Look at this DOG",
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{expr: `"cats"`, want: true},
+		{expr: `"wallpaper"`, want: false},
+		{expr: `subject:"wallpaper"`, want: true},
+		{expr: `op:"cats" & post:"dog"`, want: true},
+		{expr: `post:"cats"`, want: false},
+		{expr: `re:/^thread/`, want: false},
+		{expr: `re:/^thread/i`, want: true},
+		{expr: `subject:re:/#\d+$/`, want: true},
+		{expr: `subject:re:/^\/wp\//`, want: false},
+		{expr: `!post:re:/dog/ | subject:"nothing"`, want: true},
+		{expr: `!post:re:/dog/i`, want: false},
+	}
+
+	for _, tt := range tests {
+		expr, err := filter.Parse(tt.expr)
+		assert.Nil(err, tt.expr)
+		assert.Equal(tt.want, expr.Match(doc), tt.expr)
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name string
+		expr string
+		want *filter.SyntaxError
+	}{
+		{
+			name: "Empty",
+			expr: ``,
+			want: &filter.SyntaxError{Pos: 1, Msg: "unexpected end of expression"},
+		},
+		{
+			name: "Empty keyword",
+			expr: `"a"|""`,
+			want: &filter.SyntaxError{Pos: 5, Msg: "empty keyword"},
+		},
+		{
+			name: "Unterminated string",
+			expr: `"a"&"b`,
+			want: &filter.SyntaxError{Pos: 5, Msg: "unterminated string"},
+		},
+		{
+			name: "Unclosed parenthesis",
+			expr: `("a"|("b")`,
+			want: &filter.SyntaxError{Pos: 1, Msg: "unclosed parenthesis"},
+		},
+		{
+			name: "Extra parenthesis",
+			expr: `"a")`,
+			want: &filter.SyntaxError{Pos: 4, Msg: `unexpected ")"`},
+		},
+		{
+			name: "Missing operand",
+			expr: `"a"&`,
+			want: &filter.SyntaxError{Pos: 5, Msg: "unexpected end of expression"},
+		},
+		{
+			name: "Missing operator",
+			expr: `"a" "b"`,
+			want: &filter.SyntaxError{Pos: 5, Msg: `unexpected "b"`},
+		},
+		{
+			name: "Unknown field",
+			expr: `"a"|title:"b"`,
+			want: &filter.SyntaxError{Pos: 5, Msg: `unknown field "title"`},
+		},
+		{
+			name: "Field without predicate",
+			expr: `subject:!"b"`,
+			want: &filter.SyntaxError{Pos: 9, Msg: `unexpected "!"`},
+		},
+		{
+			name: "Unterminated regexp",
+			expr: `re:/a\/`,
+			want: &filter.SyntaxError{Pos: 1, Msg: "unterminated regexp"},
+		},
+		{
+			name: "Invalid regexp",
+			expr: `op:re:/(a/`,
+			want: &filter.SyntaxError{Pos: 4, Msg: "error parsing regexp: missing closing ): `(a`"},
+		},
+		{
+			name: "Regexp without slashes",
+			expr: `re:"a"`,
+			want: &filter.SyntaxError{Pos: 1, Msg: "expected / after re:"},
+		},
+		{
+			name: "Unquoted keyword",
+			expr: `"a"|b`,
+			want: &filter.SyntaxError{Pos: 5, Msg: "unexpected 'b'"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := filter.Parse(tt.expr)
+			assert.Nil(expr)
+			assert.Equal(tt.want, err)
+		})
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	expr, rest, err := filter.ParsePrefix(`("a"|"b") & !"c" Default name`)
+	assert.Nil(err)
+	assert.Equal("Default name", rest)
+	assert.True(expr.Match(text("a")))
+	assert.False(expr.Match(text("ac")))
+
+	_, rest, err = filter.ParsePrefix(`"a"`)
+	assert.Nil(err)
+	assert.Equal("", rest)
+
+	_, _, err = filter.ParsePrefix(`Default`)
+	assert.Equal(&filter.SyntaxError{Pos: 1, Msg: "unexpected 'D'"}, err)
+}
+
+func TestParseLegacy(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(filter.IsLegacy(`"say "hi""&!"bye"`))
+	assert.False(filter.IsLegacy(`("a")`))
+
+	expr := filter.ParseLegacy(`"say "hi""&!"bye"|"a\\b"`)
+	assert.True(expr.Match(text(`they say "hi"`)))
+	assert.False(expr.Match(text(`say "hi" and bye`)))
+	assert.True(expr.Match(text(`a\\b`)))
+	assert.False(expr.Match(text(`a\b`)))
+}
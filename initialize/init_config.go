@@ -2,6 +2,7 @@ package initialize
 
 import (
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"time"
@@ -9,6 +10,8 @@ import (
 	"github.com/aoyako/telegram_2ch_res_bot/controller"
 	"github.com/aoyako/telegram_2ch_res_bot/downloader"
 	"github.com/aoyako/telegram_2ch_res_bot/dvach"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/media"
 	"github.com/aoyako/telegram_2ch_res_bot/storage"
 	"github.com/aoyako/telegram_2ch_res_bot/telegram"
 	"github.com/spf13/viper"
@@ -16,7 +19,8 @@ import (
 )
 
 // App initializes application
-func App() (*telegram.TgBot, *dvach.APIController, uint64) {
+// Outbox is nil if files are sent without queue
+func App() (*telegram.TgBot, *dvach.Scheduler, *telegram.Outbox) {
 	if err := initConfig(); err != nil {
 		log.Fatalf("Error initializing config file: %s", err.Error())
 	}
@@ -50,24 +54,153 @@ func App() (*telegram.TgBot, *dvach.APIController, uint64) {
 		Admin: stringToInt64Slice(viper.GetStringSlice("tg.admin_id")),
 	}
 
-	requestURL := &dvach.RequestURL{
-		AllThreadsURL: viper.GetString("dapi.all"),
-		ThreadURL:     viper.GetString("dapi.thread"),
-		ResourceURL:   viper.GetString("dapi.resource"),
-	}
+	mirrors := newMirrors()
+	catalog := dvach.NewCatalog(nil, viper.GetDuration("catalog.ttl"))
+	catalog.Timeout = viper.GetDuration("dapi.timeout")
 
+	mediaTypes := newMediaRegistry()
 	Storage := storage.NewStorage(db, &admins)
-	controller := controller.NewController(Storage)
+	controller := controller.NewController(Storage, &controller.Config{
+		LedgerRetention:  viper.GetDuration("ledger.retention"),
+		Catalog:          catalog,
+		Media:            mediaTypes,
+		OutboxAttempts:   viper.GetInt("outbox.attempts"),
+		OutboxMinBackoff: viper.GetDuration("outbox.min_backoff"),
+		OutboxMaxBackoff: viper.GetDuration("outbox.max_backoff"),
+	})
 
 	bot := telegram.NewTelegramBot(os.Getenv("BOT_TOKEN"), controller, downloader.NewDownloader(
 		viper.GetString("disk.path")))
-	requester := dvach.NewRequester(requestURL)
-	apicnt := dvach.NewAPIController(controller, bot, requester)
+	bot.Admins = admins.Admin
+	if viper.IsSet("tg.rate_limit.rate") {
+		bot.Limiter.Rate = viper.GetFloat64("tg.rate_limit.rate")
+	}
+	if viper.IsSet("tg.rate_limit.chat") {
+		bot.Limiter.Chat = viper.GetDuration("tg.rate_limit.chat")
+	}
+	if viper.IsSet("tg.rate_limit.group") {
+		bot.Limiter.Group = viper.GetDuration("tg.rate_limit.group")
+	}
+	retryPolicy := &dvach.RetryPolicy{
+		Attempts:   viper.GetInt("dapi.retry.attempts"),
+		MinBackoff: viper.GetDuration("dapi.retry.min_backoff"),
+		MaxBackoff: viper.GetDuration("dapi.retry.max_backoff"),
+	}
+	client := &http.Client{
+		Timeout: viper.GetDuration("dapi.timeout"),
+	}
+
+	fetcher := dvach.NewFetcher(client, retryPolicy)
+	if viper.GetBool("dapi.cache.enabled") {
+		fetcher.Cache = dvach.NewResponseCache(viper.GetInt("dapi.cache.size"), viper.GetString("dapi.cache.dir"))
+	}
+	fetcher.Limiter = dvach.NewHostLimiter(viper.GetFloat64("dapi.rate_limit.rate"), viper.GetInt("dapi.rate_limit.burst"))
+	fetcher.Breaker = dvach.NewBreaker(viper.GetInt("dapi.breaker.threshold"), viper.GetDuration("dapi.breaker.cooldown"))
+	fetcher.Breaker.Notify = telegram.NewNotifier(bot, adminNotifications).Notify
+
+	requester, clock := newRequester(mirrors, fetcher)
+	sources := map[string]dvach.Source{
+		logic.DefaultSource: dvach.NewDvachSource(requester),
+	}
+	if viper.GetBool("fourchan.enabled") {
+		sources["4chan"] = dvach.NewFourchanSource(&dvach.FourchanURL{
+			CatalogURL:  viper.GetString("fourchan.catalog"),
+			ThreadURL:   viper.GetString("fourchan.thread"),
+			ResourceURL: viper.GetString("fourchan.resource"),
+			BoardsURL:   viper.GetString("fourchan.boards"),
+		}, fetcher)
+	}
+	catalog.Sources = sources
+
+	bot.Media = mediaTypes
+
+	var sender telegram.Sender = bot
+	var outbox *telegram.Outbox
+	if viper.GetBool("outbox.enabled") {
+		outbox = telegram.NewOutbox(bot, viper.GetDuration("outbox.interval"), viper.GetInt("outbox.batch"))
+		sender = outbox
+	}
+
+	worker := dvach.NewAPIWorkerDvach(controller, sender, sources)
+	worker.Media = mediaTypes
+	worker.Pool = dvach.NewPool(viper.GetInt("polling.workers"), viper.GetInt("polling.board_workers"))
+	if viper.IsSet("polling.retries") {
+		worker.Retries = viper.GetInt("polling.retries")
+	}
+	if clock != nil {
+		worker.Clock = clock
+	}
+	apicnt := &dvach.APIController{
+		APIWorker: worker,
+	}
 
 	telegram.SetupHandlers(bot)
 	storage.MigrateDatabase(db)
 
-	return bot, apicnt, viper.GetUint64("polling.time")
+	return bot, newScheduler(apicnt), outbox
+}
+
+// Max amount of admin notifications waiting to be sent, the following ones are dropped
+const adminNotifications = 16
+
+// Selects requester by dapi.mode: "live" (default), "record" or "replay"
+// Returns simulated clock of replay, nil if real time is used
+func newRequester(mirrors *dvach.MirrorSet, f *dvach.Fetcher) (dvach.Requester, func() uint64) {
+	fixtures := viper.GetString("dapi.fixtures")
+	switch mode := viper.GetString("dapi.mode"); mode {
+	case "", "live":
+		return dvach.NewMirrorRequester(mirrors, f), nil
+	case "record":
+		return dvach.NewRecordingRequester(dvach.NewMirrorRequester(mirrors, f), fixtures), nil
+	case "replay":
+		var clock func() uint64
+		if viper.IsSet("dapi.replay.start") {
+			clock = dvach.NewSimulatedClock(viper.GetUint64("dapi.replay.start"), viper.GetFloat64("dapi.replay.speed"))
+		}
+		return dvach.NewReplayRequester(fixtures, mirrors.Current().ResourceURL, clock), clock
+	default:
+		log.Fatalf("Unknown dapi mode: %s", mode)
+		return nil, nil
+	}
+}
+
+// Loads api endpoints from "dapi" section
+// The main endpoints are followed by ones listed in "dapi.mirrors"
+func newMirrors() *dvach.MirrorSet {
+	mirrors := []dvach.RequestURL{{
+		AllThreadsURL:  viper.GetString("dapi.all"),
+		ThreadURL:      viper.GetString("dapi.thread"),
+		ThreadAfterURL: viper.GetString("dapi.thread_after"),
+		ResourceURL:    viper.GetString("dapi.resource"),
+		BoardsURL:      viper.GetString("dapi.boards"),
+	}}
+
+	var extra []dvach.RequestURL
+	if err := viper.UnmarshalKey("dapi.mirrors", &extra); err != nil {
+		log.Fatalf("Error reading api mirrors: %s", err.Error())
+	}
+
+	return dvach.NewMirrorSet(append(mirrors, extra...))
+}
+
+// Loads media types from "media" section, defaults are used if it is missing
+func newMediaRegistry() *media.Registry {
+	if !viper.IsSet("media") {
+		return media.Default()
+	}
+
+	var groups map[string][]media.Format
+	err := viper.UnmarshalKey("media", &groups)
+	if err != nil {
+		log.Fatalf("Error reading media types: %s", err.Error())
+	}
+
+	registry, err := media.NewRegistry(groups)
+	if err != nil {
+		log.Fatalf("Error reading media types: %s", err.Error())
+	}
+
+	return registry
 }
 
 func initConfig() error {
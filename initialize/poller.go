@@ -1,15 +1,43 @@
 package initialize
 
 import (
+	"context"
+	"log"
 	"time"
 
 	"github.com/aoyako/telegram_2ch_res_bot/dvach"
+	"github.com/aoyako/telegram_2ch_res_bot/telegram"
+	"github.com/spf13/viper"
 )
 
-// StartPolling starts file sending
-func StartPolling(api *dvach.APIController, minutes uint64) {
-	for {
-		go api.InitiateSending()
-		<-time.After(time.Duration(minutes) * time.Minute)
+// StartPolling starts file sending, returns when context is done and running cycle is finished
+func StartPolling(ctx context.Context, scheduler *dvach.Scheduler) {
+	scheduler.Run(ctx)
+}
+
+// StartSending sends queued files, returns when context is done
+// Returns immediately if outbox is nil
+func StartSending(ctx context.Context, outbox *telegram.Outbox) {
+	if outbox == nil {
+		return
+	}
+	outbox.Run(ctx)
+}
+
+// Creates scheduler of polling cycles from configuration
+func newScheduler(api *dvach.APIController) *dvach.Scheduler {
+	scheduler := dvach.NewScheduler(api, time.Duration(viper.GetUint64("polling.time"))*time.Minute)
+	scheduler.MinInterval = viper.GetDuration("polling.adaptive.min")
+	scheduler.MaxInterval = viper.GetDuration("polling.adaptive.max")
+	if viper.IsSet("polling.adaptive.busy") {
+		scheduler.Busy = viper.GetInt("polling.adaptive.busy")
+	}
+	for board, value := range viper.GetStringMapString("polling.boards") {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			log.Fatalf("Error reading polling interval of board %s: %s", board, value)
+		}
+		scheduler.Boards[board] = interval
 	}
+	return scheduler
 }
@@ -1,5 +1,8 @@
 package logic
 
+// DefaultSource is imageboard used when publication does not specify one
+const DefaultSource = "2ch"
+
 // User stores info about user
 type User struct {
 	ID        int
@@ -17,17 +20,103 @@ type Admin struct {
 
 // Publication stores info about origin of data sent to user
 type Publication struct {
-	ID        int
-	Board     string // 2ch board name
-	Tags      string // Array of strings to search in thread title
-	IsDefault bool   // Publication owner
-	Type      string // File formats
-	Alias     string // String alias
-	Users     []User `gorm:"many2many:user_subscribtion;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ID          int
+	Source      string      // Imageboard name, empty for DefaultSource
+	Board       string      // Board name
+	Tags        string      // Array of strings to search in thread title
+	LegacyTags  bool        // Tags were saved before expression grammar and keep their old meaning
+	IsDefault   bool        // Publication owner
+	Type        string      // File formats
+	Alias       string      // String alias
+	PostMode    bool        // Tags are checked against every post instead of thread
+	MinPosts    int         // Minimal amount of posts in thread
+	MinViews    int         // Minimal amount of thread views
+	MinScore    float64     // Minimal thread score
+	MaxAge      uint64      // Maximal thread age in seconds, 0 for no limit
+	MaxInactive uint64      // Maximal time since the last bump of thread in seconds, 0 for no limit
+	Media       MediaLimits `gorm:"embedded"` // Limits of sent files
+	Users       []User      `gorm:"many2many:user_subscribtion;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
-// Info stores addition information about bot
-type Info struct {
+// MediaLimits restricts files sent by publication, zero value means no limit
+// Limit is not checked if file does not report the value
+type MediaLimits struct {
+	MinWidth    int     // Minimal width in pixels
+	MinHeight   int     // Minimal height in pixels
+	MaxWidth    int     // Maximal width in pixels
+	MaxHeight   int     // Maximal height in pixels
+	MinSize     int     // Minimal size in kilobytes
+	MaxSize     int     // Maximal size in kilobytes
+	MinDuration uint64  // Minimal video duration in seconds
+	MaxDuration uint64  // Maximal video duration in seconds
+	MinAspect   float64 // Minimal ratio of width to height
+	MaxAspect   float64 // Maximal ratio of width to height
+}
+
+// SourceName returns imageboard of publication
+func (p *Publication) SourceName() string {
+	if p.Source == "" {
+		return DefaultSource
+	}
+	return p.Source
+}
+
+// Cursor stores delivery progress of board or thread
+type Cursor struct {
 	ID       int
-	LastPost uint64 // Time of the latest post
+	Source   string `gorm:"uniqueIndex:idx_cursor_source_board_thread"` // Imageboard name
+	Board    string `gorm:"uniqueIndex:idx_cursor_source_board_thread"` // Board name
+	Thread   uint64 `gorm:"uniqueIndex:idx_cursor_source_board_thread"` // Thread number, 0 for board cursor
+	LastPost uint64 // Time of the latest delivered post
+	LastNum  uint64 // Number of the latest seen post of thread, 0 if unknown
+}
+
+// SentFile records file delivered to chat
+type SentFile struct {
+	ID     int
+	ChatID int64  `gorm:"uniqueIndex:idx_sent_file_chat_key"` // Telegram's chat id
+	Key    string `gorm:"uniqueIndex:idx_sent_file_chat_key"` // File identity, md5 or url
+	SentAt uint64 `gorm:"index"`                              // Time of the latest delivery
+}
+
+// Board describes board listed in imageboard catalog
+type Board struct {
+	Source string // Imageboard name
+	Name   string // Board name used in requests
+	Title  string // Human readable board title
+}
+
+// Statuses of Delivery
+const (
+	DeliveryPending = "pending" // Waiting for the next attempt
+	DeliveryDead    = "dead"    // Failed permanently, kept for inspection
+)
+
+// Delivery is a file queued for sending to chat
+type Delivery struct {
+	ID            int
+	ChatID        int64  // Telegram's chat id
+	URL           string // Absolute url of file
+	Key           string // File identity, md5 or url, empty if unknown
+	Caption       string
+	Album         string // Deliveries of chat with the same album are sent together, empty for single file
+	Status        string `gorm:"index:idx_delivery_due"` // DeliveryPending or DeliveryDead
+	Attempts      int    // Amount of failed attempts
+	NextAttemptAt uint64 `gorm:"index:idx_delivery_due"` // Time of the next attempt
+	LastError     string // Error of the latest failed attempt
+	QueuedAt      uint64 // Time file was queued
+}
+
+// File is a file of post sent to chats
+type File struct {
+	URL string // Absolute url of file
+	Key string // File identity, md5 or url, empty if unknown
+}
+
+// UploadedFile records telegram file id of file which was already uploaded
+type UploadedFile struct {
+	ID         int
+	Key        string `gorm:"uniqueIndex"` // File identity, md5 or url
+	FileID     string // Telegram's file id, file is sent by it without uploading
+	UploadedAt uint64 // Time file was uploaded
 }
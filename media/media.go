@@ -0,0 +1,107 @@
+// Package media describes file types which can be requested and sent to users
+package media
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Method is a way file is sent to telegram
+type Method string
+
+// Supported send methods
+const (
+	MethodPhoto     Method = "photo"
+	MethodVideo     Method = "video"
+	MethodAnimation Method = "animation"
+	MethodDocument  Method = "document"
+)
+
+// Format describes single file extension
+type Format struct {
+	Extension string `mapstructure:"ext"`       // Extension with leading dot, e.g. ".png"
+	Method    Method `mapstructure:"method"`    // Telegram send method
+	Transcode bool   `mapstructure:"transcode"` // File is converted to mp4 before sending
+	Group     string `mapstructure:"-"`         // Name of group containing format
+}
+
+// Registry stores formats grouped by names used in publications, e.g. "img"
+type Registry struct {
+	groups  map[string][]Format
+	formats map[string]Format // Formats by extension
+}
+
+// DefaultGroups returns groups used when configuration does not specify them
+func DefaultGroups() map[string][]Format {
+	return map[string][]Format{
+		"img": {
+			{Extension: ".png", Method: MethodPhoto},
+			{Extension: ".jpg", Method: MethodPhoto},
+			{Extension: ".jpeg", Method: MethodPhoto},
+			{Extension: ".webp", Method: MethodPhoto},
+		},
+		"gif": {
+			{Extension: ".gif", Method: MethodAnimation},
+		},
+		"webm": {
+			{Extension: ".webm", Method: MethodVideo, Transcode: true},
+			{Extension: ".mp4", Method: MethodVideo},
+		},
+	}
+}
+
+// NewRegistry constructor for Registry
+// Returns error if extension belongs to several groups or method is unknown
+func NewRegistry(groups map[string][]Format) (*Registry, error) {
+	r := &Registry{
+		groups:  make(map[string][]Format),
+		formats: make(map[string]Format),
+	}
+
+	for name, formats := range groups {
+		for _, format := range formats {
+			format.Extension = strings.ToLower(format.Extension)
+			format.Group = name
+			if !strings.HasPrefix(format.Extension, ".") {
+				return nil, fmt.Errorf("media group %s: extension %q must start with dot", name, format.Extension)
+			}
+
+			switch format.Method {
+			case MethodPhoto, MethodVideo, MethodAnimation, MethodDocument:
+			default:
+				return nil, fmt.Errorf("media group %s: unknown method %q", name, format.Method)
+			}
+
+			if other, ok := r.formats[format.Extension]; ok {
+				return nil, fmt.Errorf("extension %s belongs to groups %s and %s", format.Extension, other.Group, name)
+			}
+
+			r.formats[format.Extension] = format
+			r.groups[name] = append(r.groups[name], format)
+		}
+	}
+
+	return r, nil
+}
+
+// Lookup returns format of file by its name or url
+func (r *Registry) Lookup(name string) (Format, bool) {
+	format, ok := r.formats[strings.ToLower(path.Ext(name))]
+	return format, ok
+}
+
+// HasGroup returns true if group is registered
+func (r *Registry) HasGroup(name string) bool {
+	_, ok := r.groups[name]
+	return ok
+}
+
+// Default returns registry of DefaultGroups
+func Default() *Registry {
+	r, err := NewRegistry(DefaultGroups())
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
@@ -0,0 +1,80 @@
+package media_test
+
+import (
+	"testing"
+
+	"github.com/aoyako/telegram_2ch_res_bot/media"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Lookup(t *testing.T) {
+	assert := assert.New(t)
+
+	r := media.Default()
+
+	format, ok := r.Lookup("https://2ch.hk/b/src/1/video.WEBM")
+	assert.True(ok)
+	assert.Equal(media.Format{Extension: ".webm", Method: media.MethodVideo, Transcode: true, Group: "webm"}, format)
+
+	format, ok = r.Lookup("image.webp")
+	assert.True(ok)
+	assert.Equal("img", format.Group)
+
+	_, ok = r.Lookup("archive.zip")
+	assert.False(ok)
+
+	assert.True(r.HasGroup("gif"))
+	assert.False(r.HasGroup("zip"))
+}
+
+func TestNewRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name    string
+		groups  map[string][]media.Format
+		wantErr bool
+	}{
+		{
+			name: "Valid",
+			groups: map[string][]media.Format{
+				"doc": {{Extension: ".PDF", Method: media.MethodDocument}},
+			},
+		},
+		{
+			name: "Extension without dot",
+			groups: map[string][]media.Format{
+				"doc": {{Extension: "pdf", Method: media.MethodDocument}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unknown method",
+			groups: map[string][]media.Format{
+				"doc": {{Extension: ".pdf", Method: "audio"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Extension in two groups",
+			groups: map[string][]media.Format{
+				"img": {{Extension: ".png", Method: media.MethodPhoto}},
+				"doc": {{Extension: ".png", Method: media.MethodDocument}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := media.NewRegistry(tt.groups)
+			if tt.wantErr {
+				assert.NotNil(err)
+				return
+			}
+			assert.Nil(err)
+			_, ok := r.Lookup("file.pdf")
+			assert.True(ok)
+		})
+	}
+}
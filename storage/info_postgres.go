@@ -1,8 +1,11 @@
 package storage
 
 import (
+	"time"
+
 	"github.com/aoyako/telegram_2ch_res_bot/logic"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // InfoPostgres is an implementation of storage.Info
@@ -17,19 +20,43 @@ func NewInfoPostgres(db *gorm.DB) *InfoPostgres {
 	}
 }
 
-// GetLastTimestamp returns time of the latest post
-func (infoStorage *InfoPostgres) GetLastTimestamp() uint64 {
-	var info logic.Info
-	infoStorage.db.First(&info)
+// GetBoardCursor returns cursor of board
+// New boards start from current time, so old posts are not sent
+func (infoStorage *InfoPostgres) GetBoardCursor(source, board string) (*logic.Cursor, error) {
+	var cursor logic.Cursor
+	result := infoStorage.db.
+		Where("source = ? AND board = ? AND thread = ?", source, board, 0).
+		Attrs(logic.Cursor{Source: source, Board: board, LastPost: uint64(time.Now().Unix())}).
+		FirstOrCreate(&cursor)
+
+	return &cursor, result.Error
+}
+
+// GetThreadCursors returns cursors of board's threads
+func (infoStorage *InfoPostgres) GetThreadCursors(source, board string) ([]logic.Cursor, error) {
+	cursors := make([]logic.Cursor, 0)
+	result := infoStorage.db.Where("source = ? AND board = ? AND thread <> ?", source, board, 0).Find(&cursors)
 
-	return info.LastPost
+	return cursors, result.Error
 }
 
-// SetLastTimestamp sets time of the latest post
-func (infoStorage *InfoPostgres) SetLastTimestamp(timestamp uint64) {
-	var info logic.Info
-	infoStorage.db.First(&info)
-	info.LastPost = timestamp
+// SaveCursor creates or updates cursor
+func (infoStorage *InfoPostgres) SaveCursor(cursor *logic.Cursor) error {
+	result := infoStorage.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "source"}, {Name: "board"}, {Name: "thread"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_post", "last_num"}),
+	}).Create(cursor)
+
+	return result.Error
+}
+
+// RemoveThreadCursors removes cursors of board's threads missing in alive
+func (infoStorage *InfoPostgres) RemoveThreadCursors(source, board string, alive []uint64) error {
+	query := infoStorage.db.Where("source = ? AND board = ? AND thread <> ?", source, board, 0)
+	if len(alive) != 0 {
+		query = query.Where("thread NOT IN ?", alive)
+	}
+	result := query.Delete(&logic.Cursor{})
 
-	infoStorage.db.Save(&info)
+	return result.Error
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -52,11 +53,12 @@ func TestNewInfoPostgres(t *testing.T) {
 	assert.Equal(gdb, infop.db, "Equal db instances")
 }
 
-func TestInfoPostgres_GetLastTimestamp(t *testing.T) {
+func TestInfoPostgres_GetBoardCursor(t *testing.T) {
 	assert := assert.New(t)
 	dbmock := StorageMock{}
 
 	type fields struct {
+		board     string
 		timestamp uint64
 	}
 	tests := []struct {
@@ -64,7 +66,7 @@ func TestInfoPostgres_GetLastTimestamp(t *testing.T) {
 		fields fields
 		want   uint64
 	}{
-		{"Get Timestamp", fields{123}, 123},
+		{"Get cursor", fields{"a", 123}, 123},
 	}
 
 	for _, tt := range tests {
@@ -73,48 +75,124 @@ func TestInfoPostgres_GetLastTimestamp(t *testing.T) {
 
 			infoStorage := dbmock.storage
 			rows := sqlmock.
-				NewRows([]string{"id", "last_post"}).
-				AddRow(1, tt.fields.timestamp)
-			const sqlSelectOne = `SELECT * FROM "infos" ORDER BY "infos"."id" LIMIT 1`
-			dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlSelectOne)).WillReturnRows(rows)
+				NewRows([]string{"id", "source", "board", "thread", "last_post"}).
+				AddRow(1, "2ch", tt.fields.board, 0, tt.fields.timestamp)
+			const sqlSelectOne = `SELECT * FROM "cursors" WHERE source = $1 AND board = $2 AND thread = $3 ORDER BY "cursors"."id" LIMIT 1`
+			dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlSelectOne)).
+				WithArgs("2ch", tt.fields.board, 0).
+				WillReturnRows(rows)
 
-			tstp := infoStorage.GetLastTimestamp()
-			assert.Equal(tt.want, tstp)
+			cursor, err := infoStorage.GetBoardCursor("2ch", tt.fields.board)
+			assert.Nil(err)
+			assert.Equal(tt.want, cursor.LastPost)
 
 			dbmock.AfterEach(t)
 		})
 	}
 }
 
-func TestInfoPostgres_SetLastTimestamp(t *testing.T) {
+func TestInfoPostgres_GetThreadCursors(t *testing.T) {
+	assert := assert.New(t)
 	dbmock := StorageMock{}
-	type fields struct {
-		timestamp uint64
+
+	tests := []struct {
+		name  string
+		board string
+		want  []logic.Cursor
+	}{
+		{
+			"Get cursors",
+			"a",
+			[]logic.Cursor{
+				{ID: 2, Source: "2ch", Board: "a", Thread: 10, LastPost: 100},
+				{ID: 3, Source: "2ch", Board: "a", Thread: 11, LastPost: 110, LastNum: 1100},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbmock.BeforeEach(t)
+
+			infoStorage := dbmock.storage
+			rows := sqlmock.NewRows([]string{"id", "source", "board", "thread", "last_post", "last_num"})
+			for _, c := range tt.want {
+				rows.AddRow(c.ID, c.Source, c.Board, c.Thread, c.LastPost, c.LastNum)
+			}
+			const sqlSelect = `SELECT * FROM "cursors" WHERE source = $1 AND board = $2 AND thread <> $3`
+			dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlSelect)).
+				WithArgs("2ch", tt.board, 0).
+				WillReturnRows(rows)
+
+			cursors, err := infoStorage.GetThreadCursors("2ch", tt.board)
+			assert.Nil(err)
+			assert.Equal(tt.want, cursors)
+
+			dbmock.AfterEach(t)
+		})
 	}
+}
+
+func TestInfoPostgres_SaveCursor(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := StorageMock{}
+
 	tests := []struct {
 		name   string
-		fields fields
+		cursor *logic.Cursor
 	}{
-		{"Set Timestamp", fields{123}},
+		{"Save cursor", &logic.Cursor{Source: "2ch", Board: "a", Thread: 10, LastPost: 100, LastNum: 1000}},
 	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			dbmock.BeforeEach(t)
 
 			infoStorage := dbmock.storage
 
-			const sqlSelect = `SELECT * FROM "infos" ORDER BY "infos"."id" LIMIT 1`
+			const sqlInsert = `INSERT INTO "cursors" ("source","board","thread","last_post","last_num") VALUES ($1,$2,$3,$4,$5) ` +
+				`ON CONFLICT ("source","board","thread") DO UPDATE SET "last_post"="excluded"."last_post","last_num"="excluded"."last_num" RETURNING "id"`
+			dbmock.mock.ExpectBegin()
+			dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlInsert)).
+				WithArgs(tt.cursor.Source, tt.cursor.Board, tt.cursor.Thread, tt.cursor.LastPost, tt.cursor.LastNum).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			dbmock.mock.ExpectCommit()
 
-			dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlSelect)).
-				WillReturnRows(sqlmock.
-					NewRows([]string{"id", "last_post"}).
-					AddRow(1, 1))
+			err := infoStorage.SaveCursor(tt.cursor)
+			assert.Nil(err)
+
+			dbmock.AfterEach(t)
+		})
+	}
+}
+
+func TestInfoPostgres_RemoveThreadCursors(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := StorageMock{}
+
+	tests := []struct {
+		name  string
+		board string
+		alive []uint64
+	}{
+		{"Remove cursors", "a", []uint64{10, 11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbmock.BeforeEach(t)
+
+			infoStorage := dbmock.storage
 
-			dbmock.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "infos" SET "last_post"=$1 WHERE "id" = $2`)).
-				WithArgs(tt.fields.timestamp, 1).
-				WillReturnResult(sqlmock.NewResult(1, 1))
+			const sqlDelete = `DELETE FROM "cursors" WHERE (source = $1 AND board = $2 AND thread <> $3) AND thread NOT IN ($4,$5)`
+			dbmock.mock.ExpectBegin()
+			dbmock.mock.ExpectExec(regexp.QuoteMeta(sqlDelete)).
+				WithArgs("2ch", tt.board, 0, tt.alive[0], tt.alive[1]).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			dbmock.mock.ExpectCommit()
 
-			infoStorage.SetLastTimestamp(tt.fields.timestamp)
+			err := infoStorage.RemoveThreadCursors("2ch", tt.board, tt.alive)
+			assert.Nil(err)
 
 			dbmock.AfterEach(t)
 		})
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LedgerPostgres is an implementation of storage.Ledger
+type LedgerPostgres struct {
+	db *gorm.DB
+}
+
+// NewLedgerPostgres constructor of LedgerPostgres struct
+func NewLedgerPostgres(db *gorm.DB) *LedgerPostgres {
+	return &LedgerPostgres{
+		db: db,
+	}
+}
+
+// GetSentChats returns chats from chatIDs which received file with key since time
+func (ledgerStorage *LedgerPostgres) GetSentChats(key string, chatIDs []int64, since uint64) ([]int64, error) {
+	sent := make([]int64, 0)
+	if len(chatIDs) == 0 {
+		return sent, nil
+	}
+
+	result := ledgerStorage.db.Model(&logic.SentFile{}).
+		Where("key = ? AND sent_at >= ?", key, since).
+		Where("chat_id IN ?", chatIDs).
+		Pluck("chat_id", &sent)
+
+	return sent, result.Error
+}
+
+// SaveSent records delivery of file with key to chats at time tsp
+func (ledgerStorage *LedgerPostgres) SaveSent(key string, chatIDs []int64, tsp uint64) error {
+	if len(chatIDs) == 0 {
+		return nil
+	}
+
+	records := make([]logic.SentFile, len(chatIDs))
+	for i, chatID := range chatIDs {
+		records[i] = logic.SentFile{
+			ChatID: chatID,
+			Key:    key,
+			SentAt: tsp,
+		}
+	}
+
+	result := ledgerStorage.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chat_id"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"sent_at"}),
+	}).Create(&records)
+
+	return result.Error
+}
+
+// RemoveSentBefore removes records of deliveries older than tsp
+func (ledgerStorage *LedgerPostgres) RemoveSentBefore(tsp uint64) error {
+	result := ledgerStorage.db.Where("sent_at < ?", tsp).Delete(&logic.SentFile{})
+
+	return result.Error
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type LedgerMock struct {
+	storage *LedgerPostgres
+	mock    sqlmock.Sqlmock
+}
+
+func (mock *LedgerMock) BeforeEach(t *testing.T) {
+	var db *sql.DB
+	var err error
+
+	db, mocked, err := sqlmock.New()
+	mock.mock = mocked
+	assert.Nil(t, err)
+
+	gdb, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{})
+	assert.Nil(t, err)
+
+	mock.storage = NewLedgerPostgres(gdb)
+}
+
+func (mock *LedgerMock) AfterEach(t *testing.T) {
+	err := mock.mock.ExpectationsWereMet()
+	assert.Nil(t, err)
+}
+
+func TestNewLedgerPostgres(t *testing.T) {
+	assert := assert.New(t)
+
+	db, _, err := sqlmock.New()
+	assert.Nil(err)
+	gdb, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{})
+	assert.Nil(err)
+
+	ledgerp := NewLedgerPostgres(gdb)
+
+	assert.Equal(gdb, ledgerp.db, "Equal db instances")
+}
+
+func TestLedgerPostgres_GetSentChats(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := LedgerMock{}
+
+	tests := []struct {
+		name    string
+		chatIDs []int64
+		sent    []int64
+	}{
+		{"Some sent", []int64{1, 2}, []int64{2}},
+		{"No chats", []int64{}, []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbmock.BeforeEach(t)
+
+			ledgerStorage := dbmock.storage
+
+			if len(tt.chatIDs) != 0 {
+				rows := sqlmock.NewRows([]string{"chat_id"})
+				for _, chatID := range tt.sent {
+					rows.AddRow(chatID)
+				}
+				const sqlSelect = `SELECT "chat_id" FROM "sent_files" WHERE (key = $1 AND sent_at >= $2) AND chat_id IN ($3,$4)`
+				dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlSelect)).
+					WithArgs("md5:abc", 100, tt.chatIDs[0], tt.chatIDs[1]).
+					WillReturnRows(rows)
+			}
+
+			sent, err := ledgerStorage.GetSentChats("md5:abc", tt.chatIDs, 100)
+			assert.Nil(err)
+			assert.Equal(tt.sent, sent)
+
+			dbmock.AfterEach(t)
+		})
+	}
+}
+
+func TestLedgerPostgres_SaveSent(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := LedgerMock{}
+
+	dbmock.BeforeEach(t)
+
+	const sqlInsert = `INSERT INTO "sent_files" ("chat_id","key","sent_at") VALUES ($1,$2,$3),($4,$5,$6) ` +
+		`ON CONFLICT ("chat_id","key") DO UPDATE SET "sent_at"="excluded"."sent_at" RETURNING "id"`
+	dbmock.mock.ExpectBegin()
+	dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlInsert)).
+		WithArgs(1, "md5:abc", 100, 2, "md5:abc", 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	dbmock.mock.ExpectCommit()
+
+	err := dbmock.storage.SaveSent("md5:abc", []int64{1, 2}, 100)
+	assert.Nil(err)
+
+	dbmock.AfterEach(t)
+}
+
+func TestLedgerPostgres_RemoveSentBefore(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := LedgerMock{}
+
+	dbmock.BeforeEach(t)
+
+	const sqlDelete = `DELETE FROM "sent_files" WHERE sent_at < $1`
+	dbmock.mock.ExpectBegin()
+	dbmock.mock.ExpectExec(regexp.QuoteMeta(sqlDelete)).
+		WithArgs(100).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	dbmock.mock.ExpectCommit()
+
+	err := dbmock.storage.RemoveSentBefore(100)
+	assert.Nil(err)
+
+	dbmock.AfterEach(t)
+}
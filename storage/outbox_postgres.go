@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"gorm.io/gorm"
+)
+
+// OutboxPostgres is an implementation of storage.Outbox
+type OutboxPostgres struct {
+	db *gorm.DB
+}
+
+// NewOutboxPostgres constructor of OutboxPostgres struct
+func NewOutboxPostgres(db *gorm.DB) *OutboxPostgres {
+	return &OutboxPostgres{
+		db: db,
+	}
+}
+
+// AddDeliveries queues deliveries
+func (outboxStorage *OutboxPostgres) AddDeliveries(deliveries []logic.Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	result := outboxStorage.db.Create(&deliveries)
+
+	return result.Error
+}
+
+// GetDueDeliveries returns up to limit pending deliveries with attempt time up to now, the oldest first
+// Albums are not split: due deliveries of albums in batch are returned as well, even beyond limit
+func (outboxStorage *OutboxPostgres) GetDueDeliveries(now uint64, limit int) ([]logic.Delivery, error) {
+	deliveries := make([]logic.Delivery, 0)
+	result := outboxStorage.db.
+		Where("status = ? AND next_attempt_at <= ?", logic.DeliveryPending, now).
+		Order("id").
+		Limit(limit).
+		Find(&deliveries)
+	if result.Error != nil || len(deliveries) == 0 {
+		return deliveries, result.Error
+	}
+
+	albums := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, delivery := range deliveries {
+		if delivery.Album != "" && !seen[delivery.Album] {
+			seen[delivery.Album] = true
+			albums = append(albums, delivery.Album)
+		}
+	}
+	if len(albums) == 0 {
+		return deliveries, nil
+	}
+
+	// Deliveries up to the last one are already in batch
+	rest := make([]logic.Delivery, 0)
+	result = outboxStorage.db.
+		Where("status = ? AND next_attempt_at <= ? AND album IN ? AND id > ?",
+			logic.DeliveryPending, now, albums, deliveries[len(deliveries)-1].ID).
+		Order("id").
+		Find(&rest)
+
+	return append(deliveries, rest...), result.Error
+}
+
+// GetQueuedChats returns chats which file with key is waiting to be sent to
+func (outboxStorage *OutboxPostgres) GetQueuedChats(key string, chatIDs []int64) ([]int64, error) {
+	queued := make([]int64, 0)
+	if len(chatIDs) == 0 {
+		return queued, nil
+	}
+
+	result := outboxStorage.db.Model(&logic.Delivery{}).
+		Where("key = ? AND status = ?", key, logic.DeliveryPending).
+		Where("chat_id IN ?", chatIDs).
+		Pluck("chat_id", &queued)
+
+	return queued, result.Error
+}
+
+// SaveDelivery updates delivery
+func (outboxStorage *OutboxPostgres) SaveDelivery(delivery *logic.Delivery) error {
+	result := outboxStorage.db.Save(delivery)
+
+	return result.Error
+}
+
+// RemoveDelivery removes delivery from queue
+func (outboxStorage *OutboxPostgres) RemoveDelivery(delivery *logic.Delivery) error {
+	result := outboxStorage.db.Delete(delivery)
+
+	return result.Error
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type OutboxMock struct {
+	storage *OutboxPostgres
+	mock    sqlmock.Sqlmock
+}
+
+func (mock *OutboxMock) BeforeEach(t *testing.T) {
+	var db *sql.DB
+	var err error
+
+	db, mocked, err := sqlmock.New()
+	mock.mock = mocked
+	assert.Nil(t, err)
+
+	gdb, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{})
+	assert.Nil(t, err)
+
+	mock.storage = NewOutboxPostgres(gdb)
+}
+
+func (mock *OutboxMock) AfterEach(t *testing.T) {
+	err := mock.mock.ExpectationsWereMet()
+	assert.Nil(t, err)
+}
+
+func TestOutboxPostgres_AddDeliveries(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := OutboxMock{}
+
+	dbmock.BeforeEach(t)
+
+	const sqlInsert = `INSERT INTO "deliveries" ("chat_id","url","key","caption","album","status","attempts","next_attempt_at","last_error","queued_at") ` +
+		`VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10),($11,$12,$13,$14,$15,$16,$17,$18,$19,$20) RETURNING "id"`
+	dbmock.mock.ExpectBegin()
+	dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlInsert)).
+		WithArgs(1, "url", "md5:abc", "caption", "", logic.DeliveryPending, 0, 100, "", 100,
+			2, "url", "md5:abc", "caption", "", logic.DeliveryPending, 0, 100, "", 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	dbmock.mock.ExpectCommit()
+
+	err := dbmock.storage.AddDeliveries([]logic.Delivery{
+		{ChatID: 1, URL: "url", Key: "md5:abc", Caption: "caption", Status: logic.DeliveryPending, NextAttemptAt: 100, QueuedAt: 100},
+		{ChatID: 2, URL: "url", Key: "md5:abc", Caption: "caption", Status: logic.DeliveryPending, NextAttemptAt: 100, QueuedAt: 100},
+	})
+	assert.Nil(err)
+
+	dbmock.AfterEach(t)
+}
+
+func TestOutboxPostgres_GetDueDeliveries(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := OutboxMock{}
+
+	dbmock.BeforeEach(t)
+
+	const sqlSelect = `SELECT * FROM "deliveries" WHERE status = $1 AND next_attempt_at <= $2 ORDER BY id LIMIT 10`
+	dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlSelect)).
+		WithArgs(logic.DeliveryPending, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "chat_id", "url", "status", "attempts"}).
+			AddRow(1, 10, "url", logic.DeliveryPending, 2))
+
+	deliveries, err := dbmock.storage.GetDueDeliveries(100, 10)
+	assert.Nil(err)
+	assert.Equal([]logic.Delivery{
+		{ID: 1, ChatID: 10, URL: "url", Status: logic.DeliveryPending, Attempts: 2},
+	}, deliveries)
+
+	dbmock.AfterEach(t)
+}
+
+func TestOutboxPostgres_GetDueDeliveriesAlbum(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := OutboxMock{}
+
+	dbmock.BeforeEach(t)
+
+	const sqlSelect = `SELECT * FROM "deliveries" WHERE status = $1 AND next_attempt_at <= $2 ORDER BY id LIMIT 2`
+	dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlSelect)).
+		WithArgs(logic.DeliveryPending, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "chat_id", "url", "album", "status"}).
+			AddRow(1, 10, "url", "", logic.DeliveryPending).
+			AddRow(2, 10, "a.png", "album", logic.DeliveryPending))
+	const sqlAlbum = `SELECT * FROM "deliveries" WHERE status = $1 AND next_attempt_at <= $2 AND album IN ($3) AND id > $4 ORDER BY id`
+	dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlAlbum)).
+		WithArgs(logic.DeliveryPending, 100, "album", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "chat_id", "url", "album", "status"}).
+			AddRow(3, 10, "b.png", "album", logic.DeliveryPending))
+
+	deliveries, err := dbmock.storage.GetDueDeliveries(100, 2)
+	assert.Nil(err)
+	assert.Equal([]logic.Delivery{
+		{ID: 1, ChatID: 10, URL: "url", Status: logic.DeliveryPending},
+		{ID: 2, ChatID: 10, URL: "a.png", Album: "album", Status: logic.DeliveryPending},
+		{ID: 3, ChatID: 10, URL: "b.png", Album: "album", Status: logic.DeliveryPending},
+	}, deliveries)
+
+	dbmock.AfterEach(t)
+}
+
+func TestOutboxPostgres_GetQueuedChats(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := OutboxMock{}
+
+	tests := []struct {
+		name    string
+		chatIDs []int64
+		queued  []int64
+	}{
+		{"Some queued", []int64{1, 2}, []int64{2}},
+		{"No chats", []int64{}, []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbmock.BeforeEach(t)
+
+			if len(tt.chatIDs) != 0 {
+				rows := sqlmock.NewRows([]string{"chat_id"})
+				for _, chatID := range tt.queued {
+					rows.AddRow(chatID)
+				}
+				const sqlSelect = `SELECT "chat_id" FROM "deliveries" WHERE (key = $1 AND status = $2) AND chat_id IN ($3,$4)`
+				dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlSelect)).
+					WithArgs("md5:abc", logic.DeliveryPending, tt.chatIDs[0], tt.chatIDs[1]).
+					WillReturnRows(rows)
+			}
+
+			queued, err := dbmock.storage.GetQueuedChats("md5:abc", tt.chatIDs)
+			assert.Nil(err)
+			assert.Equal(tt.queued, queued)
+
+			dbmock.AfterEach(t)
+		})
+	}
+}
+
+func TestOutboxPostgres_SaveDelivery(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := OutboxMock{}
+
+	dbmock.BeforeEach(t)
+
+	const sqlUpdate = `UPDATE "deliveries" SET "chat_id"=$1,"url"=$2,"key"=$3,"caption"=$4,"album"=$5,"status"=$6,"attempts"=$7,` +
+		`"next_attempt_at"=$8,"last_error"=$9,"queued_at"=$10 WHERE "id" = $11`
+	dbmock.mock.ExpectBegin()
+	dbmock.mock.ExpectExec(regexp.QuoteMeta(sqlUpdate)).
+		WithArgs(10, "url", "", "", "", logic.DeliveryDead, 3, 200, "error", 100, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbmock.mock.ExpectCommit()
+
+	err := dbmock.storage.SaveDelivery(&logic.Delivery{
+		ID:            1,
+		ChatID:        10,
+		URL:           "url",
+		Status:        logic.DeliveryDead,
+		Attempts:      3,
+		NextAttemptAt: 200,
+		LastError:     "error",
+		QueuedAt:      100,
+	})
+	assert.Nil(err)
+
+	dbmock.AfterEach(t)
+}
+
+func TestOutboxPostgres_RemoveDelivery(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := OutboxMock{}
+
+	dbmock.BeforeEach(t)
+
+	const sqlDelete = `DELETE FROM "deliveries" WHERE "deliveries"."id" = $1`
+	dbmock.mock.ExpectBegin()
+	dbmock.mock.ExpectExec(regexp.QuoteMeta(sqlDelete)).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbmock.mock.ExpectCommit()
+
+	err := dbmock.storage.RemoveDelivery(&logic.Delivery{ID: 1})
+	assert.Nil(err)
+
+	dbmock.AfterEach(t)
+}
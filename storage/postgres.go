@@ -4,11 +4,11 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"time"
 
 	"github.com/aoyako/telegram_2ch_res_bot/logic"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -34,22 +34,96 @@ func formatPostgresConfig(cfg Config) string {
 
 // MigrateDatabase migrates database
 func MigrateDatabase(db *gorm.DB) {
-	err := db.AutoMigrate(&logic.User{}, &logic.Admin{}, &logic.Publication{}, &logic.Info{})
+	err := migrateCursorIndex(db)
+	if err != nil {
+		log.Fatalf("Error migrating database: %s", err.Error())
+	}
+
+	legacyTags := hasLegacyTags(db)
+	err = db.AutoMigrate(&logic.User{}, &logic.Admin{}, &logic.Publication{}, &logic.Cursor{}, &logic.SentFile{}, &logic.Delivery{}, &logic.UploadedFile{})
 
 	if err != nil {
 		log.Fatalf("Error migrating database")
 	}
 
-	var count int64
-	db.Find(&logic.Info{}).Count(&count)
-	if count == 0 {
-		db.Create(&logic.Info{
-			LastPost: uint64(time.Now().Unix()),
-		})
-	} else {
-		var info logic.Info
-		db.Find(&logic.Info{}).First(&info)
-		info.LastPost = uint64(time.Now().Unix())
-		db.Save(info)
+	// Cursors created before imageboards were added belong to default one
+	err = db.Model(&logic.Cursor{}).
+		Where("source IS NULL OR source = ?", "").
+		Update("source", logic.DefaultSource).Error
+	if err != nil {
+		log.Fatalf("Error migrating database: %s", err.Error())
+	}
+
+	err = migrateInfos(db)
+	if err != nil {
+		log.Fatalf("Error migrating database: %s", err.Error())
 	}
+
+	if legacyTags {
+		err = markLegacyTags(db)
+		if err != nil {
+			log.Fatalf("Error migrating database: %s", err.Error())
+		}
+	}
+}
+
+// Returns true if publications were saved before tags got expression grammar
+func hasLegacyTags(db *gorm.DB) bool {
+	migrator := db.Migrator()
+	return migrator.HasTable(&logic.Publication{}) && !migrator.HasColumn(&logic.Publication{}, "LegacyTags")
+}
+
+// Flags tags of existing publications as legacy, so they keep their old meaning
+func markLegacyTags(db *gorm.DB) error {
+	return db.Model(&logic.Publication{}).
+		Where("legacy_tags IS NULL OR legacy_tags = ?", false).
+		Update("legacy_tags", true).Error
+}
+
+// Moves time of the latest post from infos table, used before per board cursors, to cursors of subscribed boards
+// Table is dropped afterwards, existing cursors are kept
+func migrateInfos(db *gorm.DB) error {
+	if !db.Migrator().HasTable("infos") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var lastPost uint64
+		err := tx.Table("infos").Select("last_post").Order("id").Limit(1).Scan(&lastPost).Error
+		if err != nil {
+			return err
+		}
+
+		boards := make([]string, 0)
+		if lastPost != 0 {
+			err = tx.Model(&logic.Publication{}).Distinct("board").Pluck("board", &boards).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(boards) != 0 {
+			cursors := make([]logic.Cursor, len(boards))
+			for i, board := range boards {
+				cursors[i] = logic.Cursor{Source: logic.DefaultSource, Board: board, LastPost: lastPost}
+			}
+			err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&cursors).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Migrator().DropTable("infos")
+	})
+}
+
+// Drops unique index of cursors which does not include source
+// AutoMigrate does not change columns of existing index, so it is created again under new name
+func migrateCursorIndex(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&logic.Cursor{}) || !migrator.HasIndex(&logic.Cursor{}, "idx_cursor_board_thread") {
+		return nil
+	}
+
+	return migrator.DropIndex(&logic.Cursor{}, "idx_cursor_board_thread")
 }
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const (
+	sqlHasTable = `SELECT count(*) FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_name = $1 AND table_type = $2`
+	sqlHasIndex = `SELECT count(*) FROM pg_indexes WHERE tablename = $1 AND indexname = $2 AND schemaname = CURRENT_SCHEMA()`
+)
+
+func Test_migrateCursorIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+	gdb, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{})
+	assert.Nil(err)
+
+	// Index without source is dropped
+	mock.ExpectQuery(regexp.QuoteMeta(sqlHasTable)).
+		WithArgs("cursors", "BASE TABLE").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlHasIndex)).
+		WithArgs("cursors", "idx_cursor_board_thread").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP INDEX "idx_cursor_board_thread"`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.Nil(migrateCursorIndex(gdb))
+
+	// Nothing to do in new database
+	mock.ExpectQuery(regexp.QuoteMeta(sqlHasTable)).
+		WithArgs("cursors", "BASE TABLE").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	assert.Nil(migrateCursorIndex(gdb))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func Test_migrateInfos(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+	gdb, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{})
+	assert.Nil(err)
+
+	// Time of the latest post is copied to subscribed boards
+	mock.ExpectQuery(regexp.QuoteMeta(sqlHasTable)).
+		WithArgs("infos", "BASE TABLE").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT last_post FROM "infos" ORDER BY id LIMIT 1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"last_post"}).AddRow(100))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT "board" FROM "publications"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"board"}).AddRow("b").AddRow("wp"))
+	const sqlInsert = `INSERT INTO "cursors" ("source","board","thread","last_post","last_num") ` +
+		`VALUES ($1,$2,$3,$4,$5),($6,$7,$8,$9,$10) ON CONFLICT DO NOTHING RETURNING "id"`
+	mock.ExpectQuery(regexp.QuoteMeta(sqlInsert)).
+		WithArgs("2ch", "b", 0, 100, 0, "2ch", "wp", 0, 100, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE IF EXISTS "infos" CASCADE`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	assert.Nil(migrateInfos(gdb))
+
+	// Nothing to do after table is dropped
+	mock.ExpectQuery(regexp.QuoteMeta(sqlHasTable)).
+		WithArgs("infos", "BASE TABLE").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	assert.Nil(migrateInfos(gdb))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func Test_legacyTags(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+	gdb, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{})
+	assert.Nil(err)
+
+	const sqlHasColumn = `SELECT count(*) FROM INFORMATION_SCHEMA.columns WHERE table_schema = CURRENT_SCHEMA() AND table_name = $1 AND column_name = $2`
+
+	// Publications saved before flag was added have legacy tags
+	mock.ExpectQuery(regexp.QuoteMeta(sqlHasTable)).
+		WithArgs("publications", "BASE TABLE").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlHasColumn)).
+		WithArgs("publications", "legacy_tags").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	assert.True(hasLegacyTags(gdb))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "publications" SET "legacy_tags"=$1 WHERE legacy_tags IS NULL OR legacy_tags = $2`)).
+		WithArgs(true, false).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	assert.Nil(markLegacyTags(gdb))
+
+	// Publications with flag are parsed by their flag
+	mock.ExpectQuery(regexp.QuoteMeta(sqlHasTable)).
+		WithArgs("publications", "BASE TABLE").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlHasColumn)).
+		WithArgs("publications", "legacy_tags").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	assert.False(hasLegacyTags(gdb))
+
+	// Nothing to mark in new database
+	mock.ExpectQuery(regexp.QuoteMeta(sqlHasTable)).
+		WithArgs("publications", "BASE TABLE").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	assert.False(hasLegacyTags(gdb))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
@@ -32,8 +32,33 @@ type Subscription interface {
 
 // Info interface definces methods for Info Storage
 type Info interface {
-	GetLastTimestamp() uint64    // Returns time of the latest post
-	SetLastTimestamp(tsp uint64) // Sets time of the latest post
+	GetBoardCursor(source, board string) (*logic.Cursor, error)     // Returns cursor of board, creates it if missing
+	GetThreadCursors(source, board string) ([]logic.Cursor, error)  // Returns cursors of board's threads
+	SaveCursor(cursor *logic.Cursor) error                          // Creates or updates cursor
+	RemoveThreadCursors(source, board string, alive []uint64) error // Removes cursors of threads missing in alive
+}
+
+// Ledger interface defines methods for storage of delivered files
+type Ledger interface {
+	GetSentChats(key string, chatIDs []int64, since uint64) ([]int64, error) // Returns chats which received file since time
+	SaveSent(key string, chatIDs []int64, tsp uint64) error                  // Records delivery of file to chats
+	RemoveSentBefore(tsp uint64) error                                       // Removes deliveries older than time
+}
+
+// Outbox interface defines methods for storage of queued deliveries
+type Outbox interface {
+	AddDeliveries(deliveries []logic.Delivery) error                  // Queues deliveries
+	GetDueDeliveries(now uint64, limit int) ([]logic.Delivery, error) // Returns pending deliveries with attempt time up to now, the oldest first, albums are not split
+	SaveDelivery(delivery *logic.Delivery) error                      // Updates delivery
+	RemoveDelivery(delivery *logic.Delivery) error                    // Removes delivery from queue
+	GetQueuedChats(key string, chatIDs []int64) ([]int64, error)      // Returns chats which file is waiting to be sent to
+}
+
+// Upload interface defines methods for storage of uploaded files
+type Upload interface {
+	GetFileID(key string) (string, error)            // Returns telegram file id of file, empty if it was not uploaded
+	SaveFileID(key, fileID string, tsp uint64) error // Records file id of file uploaded at time
+	RemoveFileID(key string) error                   // Forgets file id of file
 }
 
 // Storage struct is used to access database
@@ -41,6 +66,9 @@ type Storage struct {
 	User
 	Subscription
 	Info
+	Ledger
+	Outbox
+	Upload
 }
 
 // NewStorage constructor of Storage
@@ -49,5 +77,8 @@ func NewStorage(db *gorm.DB, cfg *InitDatabase) *Storage {
 		User:         NewUserPostgres(db, cfg),
 		Subscription: NewSubscriptionPostgres(db),
 		Info:         NewInfoPostgres(db),
+		Ledger:       NewLedgerPostgres(db),
+		Outbox:       NewOutboxPostgres(db),
+		Upload:       NewUploadPostgres(db),
 	}
 }
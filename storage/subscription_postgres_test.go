@@ -103,8 +103,8 @@ func TestSubscriptionPostgres_Add(t *testing.T) {
 			dbmock.BeforeEach(t)
 
 			subsStorage := dbmock.storage
-			const sqlInsertPublicationWithID = `INSERT INTO "publications" ("board","tags","is_default","type","alias","id") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`
-			const sqlInsertPublication = `INSERT INTO "publications" ("board","tags","is_default","type","alias") VALUES ($1,$2,$3,$4,$5) RETURNING "id"`
+			const sqlInsertPublicationWithID = `INSERT INTO "publications" ("source","board","tags","legacy_tags","is_default","type","alias","post_mode","min_posts","min_views","min_score","max_age","max_inactive","min_width","min_height","max_width","max_height","min_size","max_size","min_duration","max_duration","min_aspect","max_aspect","id") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24) RETURNING "id"`
+			const sqlInsertPublication = `INSERT INTO "publications" ("source","board","tags","legacy_tags","is_default","type","alias","post_mode","min_posts","min_views","min_score","max_age","max_inactive","min_width","min_height","max_width","max_height","min_size","max_size","min_duration","max_duration","min_aspect","max_aspect") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23) RETURNING "id"`
 			const sqlInsertUser = `INSERT INTO "users" ("chat_id","subs_count","id") VALUES ($1,$2,$3) ON CONFLICT DO NOTHING RETURNING "id"`
 			const sqlInsertUserSubscribtion = `INSERT INTO "user_subscribtion" ("publication_id","user_id") VALUES ($1,$2) ON CONFLICT DO NOTHING`
 
@@ -113,13 +113,13 @@ func TestSubscriptionPostgres_Add(t *testing.T) {
 
 			if pubInst.ID == 0 {
 				dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlInsertPublication)).
-					WithArgs(pubInst.Board, pubInst.Tags, pubInst.IsDefault, pubInst.Type, pubInst.Alias).
+					WithArgs(pubInst.Source, pubInst.Board, pubInst.Tags, pubInst.LegacyTags, pubInst.IsDefault, pubInst.Type, pubInst.Alias, pubInst.PostMode, pubInst.MinPosts, pubInst.MinViews, pubInst.MinScore, pubInst.MaxAge, pubInst.MaxInactive, pubInst.Media.MinWidth, pubInst.Media.MinHeight, pubInst.Media.MaxWidth, pubInst.Media.MaxHeight, pubInst.Media.MinSize, pubInst.Media.MaxSize, pubInst.Media.MinDuration, pubInst.Media.MaxDuration, pubInst.Media.MinAspect, pubInst.Media.MaxAspect).
 					WillReturnRows(
 						sqlmock.NewRows([]string{"id"}).
 							AddRow(1))
 			} else {
 				dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlInsertPublicationWithID)).
-					WithArgs(pubInst.Board, pubInst.Tags, pubInst.IsDefault, pubInst.Type, pubInst.Alias, pubInst.ID).
+					WithArgs(pubInst.Source, pubInst.Board, pubInst.Tags, pubInst.LegacyTags, pubInst.IsDefault, pubInst.Type, pubInst.Alias, pubInst.PostMode, pubInst.MinPosts, pubInst.MinViews, pubInst.MinScore, pubInst.MaxAge, pubInst.MaxInactive, pubInst.Media.MinWidth, pubInst.Media.MinHeight, pubInst.Media.MaxWidth, pubInst.Media.MaxHeight, pubInst.Media.MinSize, pubInst.Media.MaxSize, pubInst.Media.MinDuration, pubInst.Media.MaxDuration, pubInst.Media.MinAspect, pubInst.Media.MaxAspect, pubInst.ID).
 					WillReturnRows(
 						sqlmock.NewRows([]string{"id"}).
 							AddRow(pubInst.ID))
@@ -187,20 +187,20 @@ func TestSubscriptionPostgres_AddDefault(t *testing.T) {
 			dbmock.BeforeEach(t)
 
 			subsStorage := dbmock.storage
-			const sqlInsertPublicationWithID = `INSERT INTO "publications" ("board","tags","is_default","type","alias","id") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`
-			const sqlInsertPublication = `INSERT INTO "publications" ("board","tags","is_default","type","alias") VALUES ($1,$2,$3,$4,$5) RETURNING "id"`
+			const sqlInsertPublicationWithID = `INSERT INTO "publications" ("source","board","tags","legacy_tags","is_default","type","alias","post_mode","min_posts","min_views","min_score","max_age","max_inactive","min_width","min_height","max_width","max_height","min_size","max_size","min_duration","max_duration","min_aspect","max_aspect","id") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24) RETURNING "id"`
+			const sqlInsertPublication = `INSERT INTO "publications" ("source","board","tags","legacy_tags","is_default","type","alias","post_mode","min_posts","min_views","min_score","max_age","max_inactive","min_width","min_height","max_width","max_height","min_size","max_size","min_duration","max_duration","min_aspect","max_aspect") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23) RETURNING "id"`
 
 			pubInst := tt.args.publication
 
 			if pubInst.ID == 0 {
 				dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlInsertPublication)).
-					WithArgs(pubInst.Board, pubInst.Tags, true, pubInst.Type, pubInst.Alias).
+					WithArgs(pubInst.Source, pubInst.Board, pubInst.Tags, pubInst.LegacyTags, true, pubInst.Type, pubInst.Alias, pubInst.PostMode, pubInst.MinPosts, pubInst.MinViews, pubInst.MinScore, pubInst.MaxAge, pubInst.MaxInactive, pubInst.Media.MinWidth, pubInst.Media.MinHeight, pubInst.Media.MaxWidth, pubInst.Media.MaxHeight, pubInst.Media.MinSize, pubInst.Media.MaxSize, pubInst.Media.MinDuration, pubInst.Media.MaxDuration, pubInst.Media.MinAspect, pubInst.Media.MaxAspect).
 					WillReturnRows(
 						sqlmock.NewRows([]string{"id"}).
 							AddRow(1))
 			} else {
 				dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlInsertPublicationWithID)).
-					WithArgs(pubInst.Board, pubInst.Tags, true, pubInst.Type, pubInst.Alias, pubInst.ID).
+					WithArgs(pubInst.Source, pubInst.Board, pubInst.Tags, pubInst.LegacyTags, true, pubInst.Type, pubInst.Alias, pubInst.PostMode, pubInst.MinPosts, pubInst.MinViews, pubInst.MinScore, pubInst.MaxAge, pubInst.MaxInactive, pubInst.Media.MinWidth, pubInst.Media.MinHeight, pubInst.Media.MaxWidth, pubInst.Media.MaxHeight, pubInst.Media.MinSize, pubInst.Media.MaxSize, pubInst.Media.MinDuration, pubInst.Media.MaxDuration, pubInst.Media.MinAspect, pubInst.Media.MaxAspect, pubInst.ID).
 					WillReturnRows(
 						sqlmock.NewRows([]string{"id"}).
 							AddRow(pubInst.ID))
@@ -347,11 +347,11 @@ func TestSubscriptionPostgres_Update(t *testing.T) {
 			dbmock.BeforeEach(t)
 
 			subsStorage := dbmock.storage
-			const sqlInsertPublication = `UPDATE "publications" SET "board"=$1,"tags"=$2,"is_default"=$3,"type"=$4,"alias"=$5 WHERE "id" = $6`
+			const sqlInsertPublication = `UPDATE "publications" SET "source"=$1,"board"=$2,"tags"=$3,"legacy_tags"=$4,"is_default"=$5,"type"=$6,"alias"=$7,"post_mode"=$8,"min_posts"=$9,"min_views"=$10,"min_score"=$11,"max_age"=$12,"max_inactive"=$13,"min_width"=$14,"min_height"=$15,"max_width"=$16,"max_height"=$17,"min_size"=$18,"max_size"=$19,"min_duration"=$20,"max_duration"=$21,"min_aspect"=$22,"max_aspect"=$23 WHERE "id" = $24`
 			pubInst := tt.args.publication
 
 			dbmock.mock.ExpectExec(regexp.QuoteMeta(sqlInsertPublication)).
-				WithArgs(pubInst.Board, pubInst.Tags, pubInst.IsDefault, pubInst.Type, pubInst.Alias, pubInst.ID).
+				WithArgs(pubInst.Source, pubInst.Board, pubInst.Tags, pubInst.LegacyTags, pubInst.IsDefault, pubInst.Type, pubInst.Alias, pubInst.PostMode, pubInst.MinPosts, pubInst.MinViews, pubInst.MinScore, pubInst.MaxAge, pubInst.MaxInactive, pubInst.Media.MinWidth, pubInst.Media.MinHeight, pubInst.Media.MaxWidth, pubInst.Media.MaxHeight, pubInst.Media.MinSize, pubInst.Media.MaxSize, pubInst.Media.MinDuration, pubInst.Media.MaxDuration, pubInst.Media.MinAspect, pubInst.Media.MaxAspect, pubInst.ID).
 				WillReturnResult(sqlmock.NewResult(1, 1))
 
 			tstp := subsStorage.Update(tt.args.user, tt.args.publication)
@@ -410,7 +410,7 @@ func TestSubscriptionPostgres_GetSubsByUser(t *testing.T) {
 
 			userInst := tt.args.user
 			const sqlInsertUserSubscribtion = `SELECT 
-				"publications"."id","publications"."board","publications"."tags","publications"."is_default","publications"."type","publications"."alias"
+				"publications"."id","publications"."source","publications"."board","publications"."tags","publications"."legacy_tags","publications"."is_default","publications"."type","publications"."alias","publications"."post_mode","publications"."min_posts","publications"."min_views","publications"."min_score","publications"."max_age","publications"."max_inactive","publications"."min_width","publications"."min_height","publications"."max_width","publications"."max_height","publications"."min_size","publications"."max_size","publications"."min_duration","publications"."max_duration","publications"."min_aspect","publications"."max_aspect"
 				FROM "publications" JOIN "user_subscribtion" ON "user_subscribtion"."publication_id" = "publications"."id" AND "user_subscribtion"."user_id" = $1`
 
 			rows := sqlmock.NewRows([]string{"id", "board", "tags", "is_default", "type", "alias"})
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UploadPostgres is an implementation of storage.Upload
+type UploadPostgres struct {
+	db *gorm.DB
+}
+
+// NewUploadPostgres constructor of UploadPostgres struct
+func NewUploadPostgres(db *gorm.DB) *UploadPostgres {
+	return &UploadPostgres{
+		db: db,
+	}
+}
+
+// GetFileID returns telegram file id of file with key, empty string if file was not uploaded
+func (uploadStorage *UploadPostgres) GetFileID(key string) (string, error) {
+	files := make([]logic.UploadedFile, 0)
+	result := uploadStorage.db.Where("key = ?", key).Limit(1).Find(&files)
+	if result.Error != nil || len(files) == 0 {
+		return "", result.Error
+	}
+
+	return files[0].FileID, nil
+}
+
+// SaveFileID records file id of file with key uploaded at time tsp
+func (uploadStorage *UploadPostgres) SaveFileID(key, fileID string, tsp uint64) error {
+	file := logic.UploadedFile{
+		Key:        key,
+		FileID:     fileID,
+		UploadedAt: tsp,
+	}
+
+	result := uploadStorage.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"file_id", "uploaded_at"}),
+	}).Create(&file)
+
+	return result.Error
+}
+
+// RemoveFileID forgets file id of file with key
+func (uploadStorage *UploadPostgres) RemoveFileID(key string) error {
+	result := uploadStorage.db.Where("key = ?", key).Delete(&logic.UploadedFile{})
+
+	return result.Error
+}
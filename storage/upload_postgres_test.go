@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type UploadMock struct {
+	storage *UploadPostgres
+	mock    sqlmock.Sqlmock
+}
+
+func (mock *UploadMock) BeforeEach(t *testing.T) {
+	var db *sql.DB
+	var err error
+
+	db, mocked, err := sqlmock.New()
+	mock.mock = mocked
+	assert.Nil(t, err)
+
+	gdb, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{})
+	assert.Nil(t, err)
+
+	mock.storage = NewUploadPostgres(gdb)
+}
+
+func (mock *UploadMock) AfterEach(t *testing.T) {
+	err := mock.mock.ExpectationsWereMet()
+	assert.Nil(t, err)
+}
+
+func TestNewUploadPostgres(t *testing.T) {
+	assert := assert.New(t)
+
+	db, _, err := sqlmock.New()
+	assert.Nil(err)
+	gdb, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{})
+	assert.Nil(err)
+
+	uploadp := NewUploadPostgres(gdb)
+
+	assert.Equal(gdb, uploadp.db, "Equal db instances")
+}
+
+func TestUploadPostgres_GetFileID(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := UploadMock{}
+
+	tests := []struct {
+		name   string
+		fileID string
+	}{
+		{"Uploaded", "AgAD"},
+		{"Not uploaded", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbmock.BeforeEach(t)
+
+			rows := sqlmock.NewRows([]string{"id", "key", "file_id", "uploaded_at"})
+			if tt.fileID != "" {
+				rows.AddRow(1, "md5:abc", tt.fileID, 100)
+			}
+			const sqlSelect = `SELECT * FROM "uploaded_files" WHERE key = $1 LIMIT 1`
+			dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlSelect)).
+				WithArgs("md5:abc").
+				WillReturnRows(rows)
+
+			fileID, err := dbmock.storage.GetFileID("md5:abc")
+			assert.Nil(err)
+			assert.Equal(tt.fileID, fileID)
+
+			dbmock.AfterEach(t)
+		})
+	}
+}
+
+func TestUploadPostgres_SaveFileID(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := UploadMock{}
+
+	dbmock.BeforeEach(t)
+
+	const sqlInsert = `INSERT INTO "uploaded_files" ("key","file_id","uploaded_at") VALUES ($1,$2,$3) ` +
+		`ON CONFLICT ("key") DO UPDATE SET "file_id"="excluded"."file_id","uploaded_at"="excluded"."uploaded_at" RETURNING "id"`
+	dbmock.mock.ExpectBegin()
+	dbmock.mock.ExpectQuery(regexp.QuoteMeta(sqlInsert)).
+		WithArgs("md5:abc", "AgAD", 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	dbmock.mock.ExpectCommit()
+
+	err := dbmock.storage.SaveFileID("md5:abc", "AgAD", 100)
+	assert.Nil(err)
+
+	dbmock.AfterEach(t)
+}
+
+func TestUploadPostgres_RemoveFileID(t *testing.T) {
+	assert := assert.New(t)
+	dbmock := UploadMock{}
+
+	dbmock.BeforeEach(t)
+
+	const sqlDelete = `DELETE FROM "uploaded_files" WHERE key = $1`
+	dbmock.mock.ExpectBegin()
+	dbmock.mock.ExpectExec(regexp.QuoteMeta(sqlDelete)).
+		WithArgs("md5:abc").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbmock.mock.ExpectCommit()
+
+	err := dbmock.storage.RemoveFileID("md5:abc")
+	assert.Nil(err)
+
+	dbmock.AfterEach(t)
+}
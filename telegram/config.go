@@ -4,6 +4,7 @@ package telegram
 const HelpMessage = "List all commands: /help\n" +
 	"Subscribe: /create [board_name] {.img | .webm | .gif} [\"keyword1\", \"keywoard2\",...]\n" +
 	"List all publcations: /list\n" +
+	"List boards: /boards [source]\n" +
 	"List your subscriptions: /subs\n" +
 	"Subscribe: /subscribe [id]\n" +
 	"Delete subscription: /rm [subscription_number]"
@@ -1,11 +1,16 @@
 package telegram
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"regexp"
+	"strings"
+	"unicode/utf8"
 
+	"github.com/aoyako/telegram_2ch_res_bot/controller"
+	"github.com/aoyako/telegram_2ch_res_bot/filter"
 	"github.com/aoyako/telegram_2ch_res_bot/logic"
 
 	telebot "gopkg.in/tucnak/telebot.v2"
@@ -29,14 +34,14 @@ func subs(tb *TgBot) func(m *telebot.Message) {
 		subs, err := tb.Controller.Subscription.GetSubsByChatID(m.Chat.ID)
 		if err != nil {
 			log.Println(err)
-			_, err := tb.Bot.Send(m.Sender, "Bad request")
+			err := tb.reply(m, "Bad request")
 			if err != nil {
 				log.Println("Send message error", err)
 			}
 			return
 		}
 		result := fmt.Sprintf("Your subs:%s", marshallSubs(subs, true))
-		_, err = tb.Bot.Send(m.Sender, result)
+		err = tb.reply(m, result)
 		if err != nil {
 			log.Println("Send message error", err)
 		}
@@ -48,7 +53,7 @@ func list(tb *TgBot) func(m *telebot.Message) {
 	return func(m *telebot.Message) {
 		subs := tb.Controller.Subscription.GetAllDefaultSubs()
 		result := fmt.Sprintf("Available subs:%s", marshallSubs(subs, true))
-		_, err := tb.Bot.Send(m.Sender, result)
+		err := tb.reply(m, result)
 		if err != nil {
 			log.Println("Send message error", err)
 		}
@@ -60,17 +65,42 @@ func cleverList(tb *TgBot) func(m *telebot.Message) {
 	return func(m *telebot.Message) {
 		subs := tb.Controller.Subscription.GetAllDefaultSubs()
 		result := fmt.Sprintf("Available subs:%s", marshallSubs(subs, false))
-		_, err := tb.Bot.Send(m.Sender, result)
+		err := tb.reply(m, result)
 		if err != nil {
 			log.Println("Send message error", err)
 		}
 	}
 }
 
+// /boards endpoint
+func boards(tb *TgBot) func(m *telebot.Message) {
+	return func(m *telebot.Message) {
+		source, _ := parseCommand(m.Text)
+		boards, err := tb.Controller.Board.GetBoards(source)
+		if err != nil {
+			log.Println(err)
+			err := tb.reply(m, "Boards are not available")
+			if err != nil {
+				log.Println("Send message error", err)
+			}
+			return
+		}
+
+		result := fmt.Sprintf("Available boards:%s", marshallBoards(boards))
+		for _, text := range splitMessage(result) {
+			err = tb.reply(m, text)
+			if err != nil {
+				log.Println("Send message error", err)
+				return
+			}
+		}
+	}
+}
+
 // /help endpoint
 func help(tb *TgBot) func(m *telebot.Message) {
 	return func(m *telebot.Message) {
-		_, err := tb.Bot.Send(m.Sender, HelpMessage, telebot.ModeMarkdown)
+		err := tb.reply(m, HelpMessage, telebot.ModeMarkdown)
 		if err != nil {
 			log.Println("Send message error", err)
 		}
@@ -82,7 +112,7 @@ func create(tb *TgBot) func(m *telebot.Message) {
 	return func(m *telebot.Message) {
 		args, err := parseCommand(m.Text)
 		if err != nil {
-			_, err_send := tb.Bot.Send(m.Sender, "Bad request")
+			err_send := tb.reply(m, "Bad request")
 			if err != nil {
 				log.Println("Send message error", err_send, "caused by", err)
 			}
@@ -91,14 +121,14 @@ func create(tb *TgBot) func(m *telebot.Message) {
 
 		err = tb.Controller.AddNew(m.Chat.ID, args)
 		if err != nil {
-			_, err_send := tb.Bot.Send(m.Sender, "Bad request")
+			err_send := tb.reply(m, badRequestMessage(err))
 			if err != nil {
 				log.Println("Send message error", err_send, "caused by", err)
 			}
 			return
 		}
 
-		_, err = tb.Bot.Send(m.Sender, "OK")
+		err = tb.reply(m, "OK")
 		if err != nil {
 			log.Println("Send message error", err)
 		}
@@ -110,7 +140,7 @@ func subscribe(tb *TgBot) func(m *telebot.Message) {
 	return func(m *telebot.Message) {
 		args, err := parseCommand(m.Text)
 		if err != nil {
-			_, err_send := tb.Bot.Send(m.Sender, "Bad request")
+			err_send := tb.reply(m, "Bad request")
 			if err != nil {
 				log.Println("Send message error", err_send, "caused by", err)
 			}
@@ -119,14 +149,14 @@ func subscribe(tb *TgBot) func(m *telebot.Message) {
 
 		err = tb.Controller.Subscription.Subscribe(m.Chat.ID, args)
 		if err != nil {
-			_, err_send := tb.Bot.Send(m.Sender, "Bad request")
+			err_send := tb.reply(m, "Bad request")
 			if err != nil {
 				log.Println("Send message error", err_send, "caused by", err)
 			}
 			return
 		}
 
-		_, err = tb.Bot.Send(m.Sender, "OK")
+		err = tb.reply(m, "OK")
 		if err != nil {
 			log.Println("Send message error", err)
 		}
@@ -138,7 +168,7 @@ func createDefault(tb *TgBot) func(m *telebot.Message) {
 	return func(m *telebot.Message) {
 		args, err := parseCommand(m.Text)
 		if err != nil {
-			_, err_send := tb.Bot.Send(m.Sender, "Bad request")
+			err_send := tb.reply(m, "Bad request")
 			if err != nil {
 				log.Println("Send message error", err_send, "caused by", err)
 			}
@@ -147,14 +177,14 @@ func createDefault(tb *TgBot) func(m *telebot.Message) {
 
 		err = tb.Controller.Create(m.Chat.ID, args)
 		if err != nil {
-			_, err_send := tb.Bot.Send(m.Sender, "Bad request")
+			err_send := tb.reply(m, badRequestMessage(err))
 			if err != nil {
 				log.Println("Send message error", err_send, "caused by", err)
 			}
 			return
 		}
 
-		_, err = tb.Bot.Send(m.Sender, "OK")
+		err = tb.reply(m, "OK")
 		if err != nil {
 			log.Println("Send message error", err)
 		}
@@ -166,7 +196,7 @@ func deleleSub(tb *TgBot) func(m *telebot.Message) {
 	return func(m *telebot.Message) {
 		args, err := parseCommand(m.Text)
 		if err != nil {
-			_, err_send := tb.Bot.Send(m.Sender, "Bad request")
+			err_send := tb.reply(m, "Bad request")
 			if err != nil {
 				log.Println("Send message error", err_send, "caused by", err)
 			}
@@ -175,14 +205,14 @@ func deleleSub(tb *TgBot) func(m *telebot.Message) {
 
 		err = tb.Controller.Subscription.Remove(m.Chat.ID, args)
 		if err != nil {
-			_, err_send := tb.Bot.Send(m.Sender, "Bad index")
+			err_send := tb.reply(m, "Bad index")
 			if err != nil {
 				log.Println("Send message error", err_send, "caused by", err)
 			}
 			return
 		}
 
-		_, err = tb.Bot.Send(m.Sender, "OK")
+		err = tb.reply(m, "OK")
 		if err != nil {
 			log.Println("Send message error", err)
 		}
@@ -194,7 +224,7 @@ func removeDefault(tb *TgBot) func(m *telebot.Message) {
 	return func(m *telebot.Message) {
 		args, err := parseCommand(m.Text)
 		if err != nil {
-			_, err_send := tb.Bot.Send(m.Sender, "Bad request")
+			err_send := tb.reply(m, "Bad request")
 			if err != nil {
 				log.Println("Send message error", err_send, "caused by", err)
 			}
@@ -203,20 +233,37 @@ func removeDefault(tb *TgBot) func(m *telebot.Message) {
 
 		err = tb.Controller.Subscription.RemoveDefault(m.Chat.ID, args)
 		if err != nil {
-			_, err_send := tb.Bot.Send(m.Sender, "Bad index")
+			err_send := tb.reply(m, "Bad index")
 			if err != nil {
 				log.Println("Send message error", err_send, "caused by", err)
 			}
 			return
 		}
 
-		_, err = tb.Bot.Send(m.Sender, "OK")
+		err = tb.reply(m, "OK")
 		if err != nil {
 			log.Println("Send message error", err)
 		}
 	}
 }
 
+// Returns reply for invalid request, describing tags and options errors
+func badRequestMessage(err error) string {
+	var syntaxErr *filter.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return "Bad request: tags " + syntaxErr.Error()
+	}
+	var optionErr *controller.OptionError
+	if errors.As(err, &optionErr) {
+		return "Bad request: " + optionErr.Error()
+	}
+	var boardErr *controller.BoardError
+	if errors.As(err, &boardErr) {
+		return "Bad request: " + boardErr.Error() + "\nList boards: /boards"
+	}
+	return "Bad request"
+}
+
 // Format command as ([comand_name] [command_text])
 func parseCommand(cmd string) (string, error) {
 	separator := regexp.MustCompile(` `)
@@ -241,7 +288,59 @@ func marshallSubs(subs []logic.Publication, displayAlias bool) string {
 	return result
 }
 
+// Format []logic.Board to string
+func marshallBoards(boards []logic.Board) string {
+	result := ""
+	for _, board := range boards {
+		name := "/" + board.Name
+		if board.Source != "" && board.Source != logic.DefaultSource {
+			name = fmt.Sprintf("/%s/%s", board.Source, board.Name)
+		}
+		result = fmt.Sprintf("%s\n%s - %s", result, name, board.Title)
+	}
+	return result
+}
+
+// Max length of telegram message
+const maxMessageLength = 4096
+
+// Replies to sender of message, replies share telegram limits with sent files
+func (tb *TgBot) reply(m *telebot.Message, what interface{}, options ...interface{}) error {
+	return tb.limited(context.Background(), m.Chat.ID, func() error {
+		_, err := tb.Bot.Send(m.Sender, what, options...)
+		return err
+	})
+}
+
+// Splits text by lines into messages fitting into telegram limit
+// Line longer than limit is cut at character boundary
+func splitMessage(text string) []string {
+	messages := make([]string, 0, 1)
+	for len(text) > maxMessageLength {
+		cut := strings.LastIndexByte(text[:maxMessageLength], '\n')
+		if cut <= 0 {
+			cut = maxMessageLength
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				cut = maxMessageLength
+			}
+		}
+		messages = append(messages, text[:cut])
+		text = strings.TrimPrefix(text[cut:], "\n")
+	}
+	return append(messages, text)
+}
+
 // Format logic.Publication to string
 func marshallSub(sub logic.Publication) string {
-	return fmt.Sprintf("/%s %s %s", sub.Board, sub.Type, sub.Tags)
+	board := "/" + sub.Board
+	if sub.Source != "" && sub.Source != logic.DefaultSource {
+		board = fmt.Sprintf("/%s/%s", sub.Source, sub.Board)
+	}
+	if options := controller.FormatOptions(&sub); options != "" {
+		return fmt.Sprintf("%s %s %s %s", board, sub.Type, options, sub.Tags)
+	}
+	return fmt.Sprintf("%s %s %s", board, sub.Type, sub.Tags)
 }
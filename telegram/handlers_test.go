@@ -2,7 +2,9 @@ package telegram
 
 import (
 	"errors"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/aoyako/telegram_2ch_res_bot/logic"
 	"github.com/stretchr/testify/assert"
@@ -302,6 +304,106 @@ func Test_cleverList(t *testing.T) {
 	}
 }
 
+func Test_boards(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type args struct {
+		chatID  int64
+		request string
+		source  string
+		boards  []logic.Board
+		err     error
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "List 2ch boards",
+			args: args{
+				chatID:  1,
+				request: "/boards",
+				boards: []logic.Board{
+					{Source: logic.DefaultSource, Name: "b", Title: "Бред"},
+					{Source: logic.DefaultSource, Name: "wp", Title: "Обои"},
+				},
+			},
+			want: "Available boards:\n/b - Бред\n/wp - Обои",
+		},
+		{
+			name: "List boards of source",
+			args: args{
+				chatID:  1,
+				request: "/boards 4chan",
+				source:  "4chan",
+				boards:  []logic.Board{{Source: "4chan", Name: "wg", Title: "Wallpapers/General"}},
+			},
+			want: "Available boards:\n/4chan/wg - Wallpapers/General",
+		},
+		{
+			name: "Catalog error",
+			args: args{
+				chatID:  1,
+				request: "/boards",
+				err:     errors.New("catalog error"),
+			},
+			want: "Boards are not available",
+		},
+	}
+	for _, tt := range tests {
+		cm := mock_controller.NewMockController(ctrl)
+		sm := mock_sender.NewMockMessageSender(ctrl)
+
+		bot := &TgBot{
+			Controller: &controller.Controller{Board: cm.MockBoard},
+			Bot:        sm,
+		}
+
+		handler := boards(bot)
+
+		cm.MockBoard.
+			EXPECT().
+			GetBoards(tt.args.source).
+			Return(tt.args.boards, tt.args.err)
+		sm.
+			EXPECT().
+			Send(nil, tt.want).
+			Return(&telebot.Message{}, nil)
+
+		message := telebot.Message{
+			Chat: &telebot.Chat{
+				ID: int64(tt.args.chatID),
+			},
+			Text: tt.args.request,
+		}
+
+		handler(&message)
+	}
+}
+
+func Test_splitMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"a\nb"}, splitMessage("a\nb"))
+
+	line := strings.Repeat("a", 3000)
+	assert.Equal([]string{line, line, line}, splitMessage(line+"\n"+line+"\n"+line))
+
+	long := strings.Repeat("a", maxMessageLength+1)
+	assert.Equal([]string{long[:maxMessageLength], "a"}, splitMessage(long))
+
+	// Two byte letters are not cut in half
+	cyrillic := "a" + strings.Repeat("я", maxMessageLength/2)
+	messages := splitMessage(cyrillic)
+	assert.Equal([]string{cyrillic[:maxMessageLength-1], cyrillic[maxMessageLength-1:]}, messages)
+	for _, message := range messages {
+		assert.True(utf8.ValidString(message))
+	}
+}
+
 func Test_help(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -903,6 +1005,29 @@ func Test_marshallSub(t *testing.T) {
 			},
 			want: "/a .g \"b\"",
 		},
+		{
+			name: "List sub with options",
+			args: args{
+				sub: logic.Publication{ID: 1, Source: "4chan", Board: "a", Tags: "\"b\"", Type: ".g", PostMode: true},
+			},
+			want: "/4chan/a .g mode=post \"b\"",
+		},
+		{
+			name: "List sub with thresholds",
+			args: args{
+				sub: logic.Publication{ID: 1, Board: "a", Tags: "\"b\"", Type: ".g",
+					MinPosts: 10, MinScore: 0.5, MaxAge: 2 * 24 * 60 * 60, MaxInactive: 90},
+			},
+			want: "/a .g min_posts=10 min_score=0.5 max_age=2d max_inactive=90s \"b\"",
+		},
+		{
+			name: "List sub with media limits",
+			args: args{
+				sub: logic.Publication{ID: 1, Board: "a", Tags: "\"b\"", Type: ".g",
+					Media: logic.MediaLimits{MinWidth: 1920, MinHeight: 1080, MaxSize: 2048, MinAspect: 16.0 / 9.0}},
+			},
+			want: "/a .g min_res=1920x1080 max_size=2m min_aspect=1.778 \"b\"",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,188 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter keeps messages within telegram limits
+// Chats waiting for messages take turns, so chat with many messages does not delay others
+type Limiter struct {
+	Rate  float64       // Messages per second to all chats, 0 means no limit
+	Chat  time.Duration // Min interval between messages to private chat
+	Group time.Duration // Min interval between messages to group chat
+
+	mu     sync.Mutex
+	global time.Time                // Time next message may be sent
+	chats  map[int64]time.Time      // Time next message to chat may be sent
+	queues map[int64][]*limitWaiter // Waiting messages of chats
+	ring   []int64                  // Chats with waiting messages in order of their turn
+	timer  *time.Timer              // Grants next message when limits allow it
+}
+
+// Message waiting for its turn
+type limitWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// NewLimiter constructor for Limiter
+func NewLimiter(rate float64, chat, group time.Duration) *Limiter {
+	return &Limiter{
+		Rate:   rate,
+		Chat:   chat,
+		Group:  group,
+		chats:  make(map[int64]time.Time),
+		queues: make(map[int64][]*limitWaiter),
+	}
+}
+
+// Wait blocks until message to chat may be sent or context is done
+// Nil limiter does not limit messages
+func (l *Limiter) Wait(ctx context.Context, chatID int64) error {
+	if l == nil {
+		return nil
+	}
+
+	w := &limitWaiter{ready: make(chan struct{})}
+	l.mu.Lock()
+	if len(l.queues[chatID]) == 0 {
+		l.ring = append(l.ring, chatID)
+	}
+	l.queues[chatID] = append(l.queues[chatID], w)
+	l.dispatch()
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if w.granted {
+			return nil
+		}
+		l.remove(chatID, w)
+		return ctx.Err()
+	}
+}
+
+// Pause delays messages to chat, e.g. after telegram asked to retry later
+func (l *Limiter) Pause(chatID int64, d time.Duration) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if next := time.Now().Add(d); next.After(l.chats[chatID]) {
+		l.chats[chatID] = next
+	}
+	l.dispatch()
+}
+
+// Returns min interval between messages to chat
+// Groups have negative ids
+func (l *Limiter) interval(chatID int64) time.Duration {
+	if chatID < 0 {
+		return l.Group
+	}
+	return l.Chat
+}
+
+// Grants messages which may be sent now, chats are served in turn
+func (l *Limiter) dispatch() {
+	now := time.Now()
+	for len(l.ring) > 0 && !now.Before(l.global) {
+		index := -1
+		for i, chatID := range l.ring {
+			if !now.Before(l.chats[chatID]) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			break
+		}
+
+		chatID := l.ring[index]
+		w := l.queues[chatID][0]
+		l.queues[chatID] = l.queues[chatID][1:]
+		w.granted = true
+		close(w.ready)
+
+		l.chats[chatID] = now.Add(l.interval(chatID))
+		if l.Rate > 0 {
+			l.global = now.Add(time.Duration(float64(time.Second) / l.Rate))
+		}
+
+		// Chat which got its turn waits behind other chats
+		l.ring = append(l.ring[:index], l.ring[index+1:]...)
+		if len(l.queues[chatID]) != 0 {
+			l.ring = append(l.ring, chatID)
+		} else {
+			delete(l.queues, chatID)
+		}
+	}
+
+	l.schedule(now)
+}
+
+// Starts timer which grants the next message, forgets chats without waiting messages if there are none
+func (l *Limiter) schedule(now time.Time) {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+
+	if len(l.ring) == 0 {
+		for chatID, next := range l.chats {
+			if !next.After(now) {
+				delete(l.chats, chatID)
+			}
+		}
+		return
+	}
+
+	next := l.chats[l.ring[0]]
+	for _, chatID := range l.ring[1:] {
+		if l.chats[chatID].Before(next) {
+			next = l.chats[chatID]
+		}
+	}
+	if l.global.After(next) {
+		next = l.global
+	}
+
+	l.timer = time.AfterFunc(next.Sub(now), func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.dispatch()
+	})
+}
+
+// Removes waiter which context is done
+func (l *Limiter) remove(chatID int64, w *limitWaiter) {
+	queue := l.queues[chatID]
+	for i := range queue {
+		if queue[i] == w {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+
+	if len(queue) != 0 {
+		l.queues[chatID] = queue
+		return
+	}
+
+	delete(l.queues, chatID)
+	for i := range l.ring {
+		if l.ring[i] == chatID {
+			l.ring = append(l.ring[:i], l.ring[i+1:]...)
+			break
+		}
+	}
+	l.dispatch()
+}
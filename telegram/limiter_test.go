@@ -0,0 +1,184 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/media"
+	mock_sender "github.com/aoyako/telegram_2ch_res_bot/telegram/mock/bot"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestLimiter_Wait(t *testing.T) {
+	assert := assert.New(t)
+	limiter := NewLimiter(0, 50*time.Millisecond, 100*time.Millisecond)
+	ctx := context.Background()
+
+	start := time.Now()
+	assert.NoError(limiter.Wait(ctx, 1))
+	assert.NoError(limiter.Wait(ctx, 2))
+	assert.Less(int64(time.Since(start)), int64(50*time.Millisecond))
+
+	// Messages to the same chat are spaced
+	assert.NoError(limiter.Wait(ctx, 1))
+	assert.GreaterOrEqual(int64(time.Since(start)), int64(50*time.Millisecond))
+
+	start = time.Now()
+	assert.NoError(limiter.Wait(ctx, -1))
+	assert.NoError(limiter.Wait(ctx, -1))
+	assert.GreaterOrEqual(int64(time.Since(start)), int64(100*time.Millisecond))
+
+	var nilLimiter *Limiter
+	assert.NoError(nilLimiter.Wait(ctx, 1))
+}
+
+func TestLimiter_Fair(t *testing.T) {
+	assert := assert.New(t)
+	limiter := NewLimiter(200, 0, 0)
+
+	var mu sync.Mutex
+	var order []int64
+	var wg sync.WaitGroup
+	send := func(chatID int64, count int) {
+		defer wg.Done()
+		for i := 0; i < count; i++ {
+			limiter.Wait(context.Background(), chatID)
+			mu.Lock()
+			order = append(order, chatID)
+			mu.Unlock()
+		}
+	}
+
+	// Busy chat starts first, other chat still gets its turn before busy chat finishes
+	wg.Add(1)
+	go send(1, 20)
+	time.Sleep(20 * time.Millisecond)
+	wg.Add(1)
+	go send(2, 2)
+	wg.Wait()
+
+	assert.Len(order, 22)
+	last := 0
+	for i, chatID := range order {
+		if chatID == 2 {
+			last = i
+		}
+	}
+	assert.Less(last, 21)
+}
+
+func TestLimiter_Cancel(t *testing.T) {
+	assert := assert.New(t)
+	limiter := NewLimiter(0, time.Hour, time.Hour)
+
+	assert.NoError(limiter.Wait(context.Background(), 1))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, limiter.Wait(ctx, 1))
+
+	// Cancelled message does not block other chats
+	assert.NoError(limiter.Wait(context.Background(), 2))
+	assert.Empty(limiter.queues)
+	assert.Empty(limiter.ring)
+}
+
+func TestLimiter_Pause(t *testing.T) {
+	assert := assert.New(t)
+	limiter := NewLimiter(0, 0, 0)
+
+	limiter.Pause(1, 50*time.Millisecond)
+	start := time.Now()
+	assert.NoError(limiter.Wait(context.Background(), 2))
+	assert.Less(int64(time.Since(start)), int64(50*time.Millisecond))
+	assert.NoError(limiter.Wait(context.Background(), 1))
+	assert.GreaterOrEqual(int64(time.Since(start)), int64(40*time.Millisecond))
+}
+
+func TestTgBot_SendFlood(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_sender.NewMockMessageSender(ctrl)
+	bot := &TgBot{
+		Bot:     sm,
+		Media:   media.Default(),
+		Limiter: NewLimiter(0, 0, 0),
+	}
+	flood := telebot.FloodError{APIError: telebot.NewAPIError(429, "Too Many Requests: retry after 0")}
+
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 1}), gomock.Any()).
+		Return(nil, flood)
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 1}), gomock.Any()).
+		Return(&telebot.Message{ID: 10}, nil)
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 2}), gomock.Any()).
+		Return(nil, flood).
+		Times(floodRetries + 1)
+
+	results := bot.Send(context.Background(), []*logic.User{{ChatID: 1}, {ChatID: 2}}, "/a.png", "1", "")
+	assert.Equal(Result{ChatID: 1, MessageID: 10}, results[0])
+	assert.Equal(ClassFlood, results[1].Class)
+}
+
+func TestTgBot_SendCancel(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_sender.NewMockMessageSender(ctrl)
+	bot := &TgBot{
+		Bot:     sm,
+		Media:   media.Default(),
+		Limiter: NewLimiter(0, time.Hour, time.Hour),
+		Admins:  []int64{1},
+	}
+
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 1}), gomock.Any()).
+		Return(&telebot.Message{ID: 10}, nil)
+	results := bot.Send(context.Background(), []*logic.User{{ChatID: 1}}, "/a.png", "1", "")
+	assert.Equal(Result{ChatID: 1, MessageID: 10}, results[0])
+
+	// Chat waits for its turn until context is done, file is not sent
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	results = bot.Send(ctx, []*logic.User{{ChatID: 1}}, "/a.png", "1", "")
+	assert.Equal(Result{ChatID: 1, Err: context.DeadlineExceeded, Class: ClassTransient}, results[0])
+	bot.NotifyAdmins(ctx, "host is down")
+}
+
+func TestTgBot_ReplyLimited(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_sender.NewMockMessageSender(ctrl)
+	bot := &TgBot{
+		Bot:     sm,
+		Limiter: NewLimiter(0, 50*time.Millisecond, 50*time.Millisecond),
+	}
+
+	// Replies to the same chat are spaced like sent files
+	sm.
+		EXPECT().
+		Send(gomock.Any(), "OK").
+		Return(&telebot.Message{ID: 10}, nil).
+		Times(2)
+	m := &telebot.Message{Chat: &telebot.Chat{ID: 1}}
+	start := time.Now()
+	assert.NoError(bot.reply(m, "OK"))
+	assert.NoError(bot.reply(m, "OK"))
+	assert.GreaterOrEqual(int64(time.Since(start)), int64(50*time.Millisecond))
+}
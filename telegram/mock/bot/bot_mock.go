@@ -65,6 +65,26 @@ func (mr *MockMessageSenderMockRecorder) Send(arg0, arg1 interface{}, arg2 ...in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockMessageSender)(nil).Send), varargs...)
 }
 
+// SendAlbum mocks base method
+func (m *MockMessageSender) SendAlbum(arg0 telebot.Recipient, arg1 telebot.Album, arg2 ...interface{}) ([]telebot.Message, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "SendAlbum", varargs...)
+	ret0, _ := ret[0].([]telebot.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendAlbum indicates an expected call of SendAlbum
+func (mr *MockMessageSenderMockRecorder) SendAlbum(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendAlbum", reflect.TypeOf((*MockMessageSender)(nil).SendAlbum), varargs...)
+}
+
 // Start mocks base method
 func (m *MockMessageSender) Start() {
 	m.ctrl.T.Helper()
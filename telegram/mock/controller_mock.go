@@ -7,6 +7,10 @@ type MockController struct {
 	*MockInfo
 	*MockUser
 	*MockSubscription
+	*MockLedger
+	*MockBoard
+	*MockOutbox
+	*MockUpload
 }
 
 // NewMockController constructor for mock controller
@@ -15,5 +19,9 @@ func NewMockController(c *gomock.Controller) *MockController {
 		NewMockInfo(c),
 		NewMockUser(c),
 		NewMockSubscription(c),
+		NewMockLedger(c),
+		NewMockBoard(c),
+		NewMockOutbox(c),
+		NewMockUpload(c),
 	}
 }
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/aoyako/telegram_2ch_res_bot/controller (interfaces: User,Subscription,Info)
+// Source: github.com/aoyako/telegram_2ch_res_bot/controller (interfaces: User,Subscription,Info,Ledger,Board,Outbox,Upload)
 
 // Package mock_controller is a generated GoMock package.
 package mock_controller
@@ -249,28 +249,352 @@ func (m *MockInfo) EXPECT() *MockInfoMockRecorder {
 	return m.recorder
 }
 
-// GetLastTimestamp mocks base method
-func (m *MockInfo) GetLastTimestamp() uint64 {
+// GetBoardTimestamp mocks base method
+func (m *MockInfo) GetBoardTimestamp(arg0, arg1 string) (uint64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetLastTimestamp")
+	ret := m.ctrl.Call(m, "GetBoardTimestamp", arg0, arg1)
 	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBoardTimestamp indicates an expected call of GetBoardTimestamp
+func (mr *MockInfoMockRecorder) GetBoardTimestamp(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBoardTimestamp", reflect.TypeOf((*MockInfo)(nil).GetBoardTimestamp), arg0, arg1)
+}
+
+// GetThreadCursors mocks base method
+func (m *MockInfo) GetThreadCursors(arg0, arg1 string) (map[uint64]logic.Cursor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetThreadCursors", arg0, arg1)
+	ret0, _ := ret[0].(map[uint64]logic.Cursor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetThreadCursors indicates an expected call of GetThreadCursors
+func (mr *MockInfoMockRecorder) GetThreadCursors(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetThreadCursors", reflect.TypeOf((*MockInfo)(nil).GetThreadCursors), arg0, arg1)
+}
+
+// RemoveStaleThreads mocks base method
+func (m *MockInfo) RemoveStaleThreads(arg0, arg1 string, arg2 []uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveStaleThreads", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveStaleThreads indicates an expected call of RemoveStaleThreads
+func (mr *MockInfoMockRecorder) RemoveStaleThreads(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveStaleThreads", reflect.TypeOf((*MockInfo)(nil).RemoveStaleThreads), arg0, arg1, arg2)
+}
+
+// SetBoardTimestamp mocks base method
+func (m *MockInfo) SetBoardTimestamp(arg0, arg1 string, arg2 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBoardTimestamp", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBoardTimestamp indicates an expected call of SetBoardTimestamp
+func (mr *MockInfoMockRecorder) SetBoardTimestamp(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBoardTimestamp", reflect.TypeOf((*MockInfo)(nil).SetBoardTimestamp), arg0, arg1, arg2)
+}
+
+// SetThreadCursor mocks base method
+func (m *MockInfo) SetThreadCursor(arg0, arg1 string, arg2, arg3, arg4 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetThreadCursor", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetThreadCursor indicates an expected call of SetThreadCursor
+func (mr *MockInfoMockRecorder) SetThreadCursor(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetThreadCursor", reflect.TypeOf((*MockInfo)(nil).SetThreadCursor), arg0, arg1, arg2, arg3, arg4)
+}
+
+// MockLedger is a mock of Ledger interface
+type MockLedger struct {
+	ctrl     *gomock.Controller
+	recorder *MockLedgerMockRecorder
+}
+
+// MockLedgerMockRecorder is the mock recorder for MockLedger
+type MockLedgerMockRecorder struct {
+	mock *MockLedger
+}
+
+// NewMockLedger creates a new mock instance
+func NewMockLedger(ctrl *gomock.Controller) *MockLedger {
+	mock := &MockLedger{ctrl: ctrl}
+	mock.recorder = &MockLedgerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockLedger) EXPECT() *MockLedgerMockRecorder {
+	return m.recorder
+}
+
+// FilterUnsent mocks base method
+func (m *MockLedger) FilterUnsent(arg0 string, arg1 []*logic.User) ([]*logic.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilterUnsent", arg0, arg1)
+	ret0, _ := ret[0].([]*logic.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FilterUnsent indicates an expected call of FilterUnsent
+func (mr *MockLedgerMockRecorder) FilterUnsent(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterUnsent", reflect.TypeOf((*MockLedger)(nil).FilterUnsent), arg0, arg1)
+}
+
+// MarkSent mocks base method
+func (m *MockLedger) MarkSent(arg0 string, arg1 []*logic.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkSent", arg0, arg1)
+	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// GetLastTimestamp indicates an expected call of GetLastTimestamp
-func (mr *MockInfoMockRecorder) GetLastTimestamp() *gomock.Call {
+// MarkSent indicates an expected call of MarkSent
+func (mr *MockLedgerMockRecorder) MarkSent(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastTimestamp", reflect.TypeOf((*MockInfo)(nil).GetLastTimestamp))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkSent", reflect.TypeOf((*MockLedger)(nil).MarkSent), arg0, arg1)
 }
 
-// SetLastTimestamp mocks base method
-func (m *MockInfo) SetLastTimestamp(arg0 uint64) {
+// PruneSent mocks base method
+func (m *MockLedger) PruneSent() error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "SetLastTimestamp", arg0)
+	ret := m.ctrl.Call(m, "PruneSent")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PruneSent indicates an expected call of PruneSent
+func (mr *MockLedgerMockRecorder) PruneSent() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneSent", reflect.TypeOf((*MockLedger)(nil).PruneSent))
+}
+
+// MockBoard is a mock of Board interface
+type MockBoard struct {
+	ctrl     *gomock.Controller
+	recorder *MockBoardMockRecorder
+}
+
+// MockBoardMockRecorder is the mock recorder for MockBoard
+type MockBoardMockRecorder struct {
+	mock *MockBoard
+}
+
+// NewMockBoard creates a new mock instance
+func NewMockBoard(ctrl *gomock.Controller) *MockBoard {
+	mock := &MockBoard{ctrl: ctrl}
+	mock.recorder = &MockBoardMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockBoard) EXPECT() *MockBoardMockRecorder {
+	return m.recorder
+}
+
+// CheckBoard mocks base method
+func (m *MockBoard) CheckBoard(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckBoard", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckBoard indicates an expected call of CheckBoard
+func (mr *MockBoardMockRecorder) CheckBoard(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckBoard", reflect.TypeOf((*MockBoard)(nil).CheckBoard), arg0, arg1)
+}
+
+// GetBoards mocks base method
+func (m *MockBoard) GetBoards(arg0 string) ([]logic.Board, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBoards", arg0)
+	ret0, _ := ret[0].([]logic.Board)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBoards indicates an expected call of GetBoards
+func (mr *MockBoardMockRecorder) GetBoards(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBoards", reflect.TypeOf((*MockBoard)(nil).GetBoards), arg0)
+}
+
+// MockOutbox is a mock of Outbox interface
+type MockOutbox struct {
+	ctrl     *gomock.Controller
+	recorder *MockOutboxMockRecorder
+}
+
+// MockOutboxMockRecorder is the mock recorder for MockOutbox
+type MockOutboxMockRecorder struct {
+	mock *MockOutbox
+}
+
+// NewMockOutbox creates a new mock instance
+func NewMockOutbox(ctrl *gomock.Controller) *MockOutbox {
+	mock := &MockOutbox{ctrl: ctrl}
+	mock.recorder = &MockOutboxMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockOutbox) EXPECT() *MockOutboxMockRecorder {
+	return m.recorder
+}
+
+// CompleteDelivery mocks base method
+func (m *MockOutbox) CompleteDelivery(arg0 *logic.Delivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteDelivery", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteDelivery indicates an expected call of CompleteDelivery
+func (mr *MockOutboxMockRecorder) CompleteDelivery(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteDelivery", reflect.TypeOf((*MockOutbox)(nil).CompleteDelivery), arg0)
+}
+
+// Enqueue mocks base method
+func (m *MockOutbox) Enqueue(arg0 []int64, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Enqueue indicates an expected call of Enqueue
+func (mr *MockOutboxMockRecorder) Enqueue(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockOutbox)(nil).Enqueue), arg0, arg1, arg2, arg3)
+}
+
+// EnqueueAlbum mocks base method
+func (m *MockOutbox) EnqueueAlbum(arg0 []int64, arg1 []logic.File, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueAlbum", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnqueueAlbum indicates an expected call of EnqueueAlbum
+func (mr *MockOutboxMockRecorder) EnqueueAlbum(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueAlbum", reflect.TypeOf((*MockOutbox)(nil).EnqueueAlbum), arg0, arg1, arg2)
+}
+
+// FailDelivery mocks base method
+func (m *MockOutbox) FailDelivery(arg0 *logic.Delivery, arg1 error, arg2 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailDelivery", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FailDelivery indicates an expected call of FailDelivery
+func (mr *MockOutboxMockRecorder) FailDelivery(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailDelivery", reflect.TypeOf((*MockOutbox)(nil).FailDelivery), arg0, arg1, arg2)
+}
+
+// GetDueDeliveries mocks base method
+func (m *MockOutbox) GetDueDeliveries(arg0 int) ([]logic.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDueDeliveries", arg0)
+	ret0, _ := ret[0].([]logic.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDueDeliveries indicates an expected call of GetDueDeliveries
+func (mr *MockOutboxMockRecorder) GetDueDeliveries(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDueDeliveries", reflect.TypeOf((*MockOutbox)(nil).GetDueDeliveries), arg0)
+}
+
+// MockUpload is a mock of Upload interface
+type MockUpload struct {
+	ctrl     *gomock.Controller
+	recorder *MockUploadMockRecorder
+}
+
+// MockUploadMockRecorder is the mock recorder for MockUpload
+type MockUploadMockRecorder struct {
+	mock *MockUpload
+}
+
+// NewMockUpload creates a new mock instance
+func NewMockUpload(ctrl *gomock.Controller) *MockUpload {
+	mock := &MockUpload{ctrl: ctrl}
+	mock.recorder = &MockUploadMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockUpload) EXPECT() *MockUploadMockRecorder {
+	return m.recorder
+}
+
+// ForgetFileID mocks base method
+func (m *MockUpload) ForgetFileID(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForgetFileID", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForgetFileID indicates an expected call of ForgetFileID
+func (mr *MockUploadMockRecorder) ForgetFileID(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForgetFileID", reflect.TypeOf((*MockUpload)(nil).ForgetFileID), arg0)
+}
+
+// GetFileID mocks base method
+func (m *MockUpload) GetFileID(arg0 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFileID", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFileID indicates an expected call of GetFileID
+func (mr *MockUploadMockRecorder) GetFileID(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFileID", reflect.TypeOf((*MockUpload)(nil).GetFileID), arg0)
+}
+
+// SaveFileID mocks base method
+func (m *MockUpload) SaveFileID(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveFileID", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// SetLastTimestamp indicates an expected call of SetLastTimestamp
-func (mr *MockInfoMockRecorder) SetLastTimestamp(arg0 interface{}) *gomock.Call {
+// SaveFileID indicates an expected call of SaveFileID
+func (mr *MockUploadMockRecorder) SaveFileID(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLastTimestamp", reflect.TypeOf((*MockInfo)(nil).SetLastTimestamp), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveFileID", reflect.TypeOf((*MockUpload)(nil).SaveFileID), arg0, arg1)
 }
@@ -0,0 +1,44 @@
+package telegram
+
+import (
+	"context"
+	"log"
+)
+
+// Notifier sends admin notifications in background, so caller does not wait for telegram limits
+// Notifications coming while buffer is full are dropped
+type Notifier struct {
+	queue chan string
+	done  chan struct{}
+}
+
+// NewNotifier starts sending notifications through bot
+// Buffer is max amount of notifications waiting to be sent
+func NewNotifier(bot *TgBot, buffer int) *Notifier {
+	n := &Notifier{
+		queue: make(chan string, buffer),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(n.done)
+		for text := range n.queue {
+			bot.NotifyAdmins(context.Background(), text)
+		}
+	}()
+	return n
+}
+
+// Notify queues text for admins, drops it if buffer is full
+func (n *Notifier) Notify(text string) {
+	select {
+	case n.queue <- text:
+	default:
+		log.Printf("Admin notification is dropped, too many are waiting: %s", text)
+	}
+}
+
+// Close stops notifier after queued notifications are sent
+func (n *Notifier) Close() {
+	close(n.queue)
+	<-n.done
+}
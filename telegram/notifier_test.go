@@ -0,0 +1,45 @@
+package telegram
+
+import (
+	"testing"
+
+	mock_sender "github.com/aoyako/telegram_2ch_res_bot/telegram/mock/bot"
+	"github.com/golang/mock/gomock"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestNotifier_Notify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_sender.NewMockMessageSender(ctrl)
+	bot := &TgBot{
+		Bot:    sm,
+		Admins: []int64{1},
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 1}), "1").
+		Do(func(interface{}, interface{}, ...interface{}) {
+			close(started)
+			<-release
+		}).
+		Return(&telebot.Message{}, nil)
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 1}), "2").
+		Return(&telebot.Message{}, nil)
+
+	notifier := NewNotifier(bot, 1)
+	notifier.Notify("1")
+	<-started
+
+	// Caller does not wait while notification is sent, notification beyond buffer is dropped
+	notifier.Notify("2")
+	notifier.Notify("3")
+	close(release)
+	notifier.Close()
+}
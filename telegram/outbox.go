@@ -0,0 +1,219 @@
+package telegram
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+)
+
+// Outbox queues files in database and sends them in background
+// Failed deliveries are retried, queued files survive restart
+type Outbox struct {
+	Bot      *TgBot
+	Interval time.Duration // Pause between checks of queue
+	Batch    int           // Max amount of deliveries taken from queue at once
+}
+
+// Pause between checks of queue if it is not set
+const defaultOutboxInterval = 5 * time.Second
+
+// NewOutbox constructor for Outbox
+func NewOutbox(bot *TgBot, interval time.Duration, batch int) *Outbox {
+	if interval <= 0 {
+		interval = defaultOutboxInterval
+	}
+	if batch < 1 {
+		batch = 1
+	}
+	return &Outbox{
+		Bot:      bot,
+		Interval: interval,
+		Batch:    batch,
+	}
+}
+
+// Send queues file for users, returns result of every user in the same order
+// File is sent immediately if it can not be queued
+func (o *Outbox) Send(ctx context.Context, users []*logic.User, path, caption, key string) []Result {
+	if len(users) == 0 {
+		return []Result{}
+	}
+
+	chatIDs := make([]int64, len(users))
+	for i, user := range users {
+		chatIDs[i] = user.ChatID
+	}
+
+	err := o.Bot.Controller.Outbox.Enqueue(chatIDs, path, caption, key)
+	if err != nil {
+		log.Printf("Error queueing %s, sending it now: %s", path, err.Error())
+		return o.Bot.Send(ctx, users, path, caption, key)
+	}
+
+	results := make([]Result, len(chatIDs))
+	for i, chatID := range chatIDs {
+		results[i] = Result{ChatID: chatID, Queued: true}
+	}
+	return results
+}
+
+// SendAlbum queues files for users to be sent as album, returns results of every file in the same order
+// Files are sent immediately if they can not be queued
+func (o *Outbox) SendAlbum(ctx context.Context, users []*logic.User, files []logic.File, caption string) [][]Result {
+	chatIDs := make([]int64, len(users))
+	for i, user := range users {
+		chatIDs[i] = user.ChatID
+	}
+
+	err := o.Bot.Controller.Outbox.EnqueueAlbum(chatIDs, files, caption)
+	if err != nil {
+		log.Printf("Error queueing album of %d files, sending it now: %s", len(files), err.Error())
+		return o.Bot.SendAlbum(ctx, users, files, caption)
+	}
+
+	results := make([][]Result, len(files))
+	for i := range files {
+		results[i] = make([]Result, len(chatIDs))
+		for j, chatID := range chatIDs {
+			results[i][j] = Result{ChatID: chatID, Queued: true}
+		}
+	}
+	return results
+}
+
+// Run sends queued files until context is done
+func (o *Outbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Full batch means more deliveries may be due
+		for ctx.Err() == nil {
+			if o.Flush(ctx) < o.Batch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush sends deliveries which are due, returns amount of deliveries which were sent or rescheduled
+func (o *Outbox) Flush(ctx context.Context) int {
+	deliveries, err := o.Bot.Controller.Outbox.GetDueDeliveries(o.Batch)
+	if err != nil {
+		log.Printf("Error reading queued deliveries: %s", err.Error())
+		return 0
+	}
+
+	// Recipients of the same file are sent together, so it is prepared once
+	// Files of album are sent together to chats which wait for the same files of it
+	type file struct {
+		url     string
+		caption string
+		key     string
+	}
+	type album struct {
+		id      string
+		caption string
+	}
+	files := make([]file, 0)
+	groups := make(map[file][]*logic.Delivery)
+	albums := make([]album, 0)
+	albumChats := make(map[album][]int64)
+	albumFiles := make(map[album]map[int64][]*logic.Delivery)
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		if delivery.Album != "" {
+			a := album{delivery.Album, delivery.Caption}
+			if _, ok := albumFiles[a]; !ok {
+				albums = append(albums, a)
+				albumFiles[a] = make(map[int64][]*logic.Delivery)
+			}
+			if _, ok := albumFiles[a][delivery.ChatID]; !ok {
+				albumChats[a] = append(albumChats[a], delivery.ChatID)
+			}
+			albumFiles[a][delivery.ChatID] = append(albumFiles[a][delivery.ChatID], delivery)
+			continue
+		}
+
+		f := file{delivery.URL, delivery.Caption, delivery.Key}
+		if _, ok := groups[f]; !ok {
+			files = append(files, f)
+		}
+		groups[f] = append(groups[f], delivery)
+	}
+
+	processed := 0
+	for _, f := range files {
+		group := groups[f]
+		chatIDs := make([]int64, len(group))
+		for i, delivery := range group {
+			chatIDs[i] = delivery.ChatID
+		}
+
+		processed += o.update(group, o.Bot.deliver(ctx, chatIDs, f.url, f.caption, f.key))
+	}
+
+	for _, a := range albums {
+		sets := make([]string, 0)
+		setChats := make(map[string][]int64)
+		for _, chatID := range albumChats[a] {
+			urls := make([]string, 0)
+			for _, delivery := range albumFiles[a][chatID] {
+				urls = append(urls, delivery.URL)
+			}
+			set := strings.Join(urls, "\n")
+			if _, ok := setChats[set]; !ok {
+				sets = append(sets, set)
+			}
+			setChats[set] = append(setChats[set], chatID)
+		}
+
+		for _, set := range sets {
+			chatIDs := setChats[set]
+			albumDeliveries := albumFiles[a][chatIDs[0]]
+			files := make([]logic.File, len(albumDeliveries))
+			for j, delivery := range albumDeliveries {
+				files[j] = logic.File{URL: delivery.URL, Key: delivery.Key}
+			}
+
+			for j, results := range o.Bot.deliverAlbum(ctx, chatIDs, files, a.caption) {
+				group := make([]*logic.Delivery, len(chatIDs))
+				for i, chatID := range chatIDs {
+					group[i] = albumFiles[a][chatID][j]
+				}
+				processed += o.update(group, results)
+			}
+		}
+	}
+
+	return processed
+}
+
+// Completes or reschedules deliveries by results of sending them, returns amount of updated deliveries
+func (o *Outbox) update(group []*logic.Delivery, results []Result) int {
+	updated := 0
+	for i, result := range results {
+		var err error
+		if result.Err == nil {
+			err = o.Bot.Controller.Outbox.CompleteDelivery(group[i])
+		} else {
+			err = o.Bot.Controller.Outbox.FailDelivery(group[i], result.Err, !result.Retry())
+		}
+		if err != nil {
+			log.Printf("Error updating delivery %d: %s", group[i].ID, err.Error())
+			continue
+		}
+		updated++
+	}
+
+	return updated
+}
@@ -0,0 +1,174 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aoyako/telegram_2ch_res_bot/controller"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/media"
+	mock_controller "github.com/aoyako/telegram_2ch_res_bot/telegram/mock"
+	mock_sender "github.com/aoyako/telegram_2ch_res_bot/telegram/mock/bot"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestOutbox_Send(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cm := mock_controller.NewMockController(ctrl)
+	sm := mock_sender.NewMockMessageSender(ctrl)
+	outbox := NewOutbox(&TgBot{
+		Bot:        sm,
+		Controller: &controller.Controller{Outbox: cm.MockOutbox},
+		Media:      media.Default(),
+	}, 0, 10)
+
+	users := []*logic.User{{ChatID: 1}, {ChatID: 2}}
+	cm.MockOutbox.
+		EXPECT().
+		Enqueue([]int64{1, 2}, "/a.png", "1", "").
+		Return(nil)
+	outbox.Send(context.Background(), users, "/a.png", "1", "")
+
+	// File is sent now if queue is not available
+	cm.MockOutbox.
+		EXPECT().
+		Enqueue([]int64{1, 2}, "/a.png", "1", "").
+		Return(errors.New("db error"))
+	sm.
+		EXPECT().
+		Send(gomock.Any(), gomock.Any()).
+		Return(&telebot.Message{}, nil).
+		Times(2)
+	outbox.Send(context.Background(), users, "/a.png", "1", "")
+}
+
+func TestOutbox_SendAlbum(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cm := mock_controller.NewMockController(ctrl)
+	outbox := NewOutbox(&TgBot{
+		Controller: &controller.Controller{Outbox: cm.MockOutbox},
+		Media:      media.Default(),
+	}, 0, 10)
+
+	files := []logic.File{{URL: "/a.png", Key: "md5:a"}, {URL: "/b.png", Key: "md5:b"}}
+	cm.MockOutbox.
+		EXPECT().
+		EnqueueAlbum([]int64{1}, files, "1").
+		Return(nil)
+
+	results := outbox.SendAlbum(context.Background(), []*logic.User{{ChatID: 1}}, files, "1")
+	assert.Equal([][]Result{{{ChatID: 1, Queued: true}}, {{ChatID: 1, Queued: true}}}, results)
+}
+
+func TestOutbox_Flush(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cm := mock_controller.NewMockController(ctrl)
+	sm := mock_sender.NewMockMessageSender(ctrl)
+	outbox := NewOutbox(&TgBot{
+		Bot:        sm,
+		Controller: &controller.Controller{Outbox: cm.MockOutbox},
+		Media:      media.Default(),
+	}, 0, 10)
+
+	deliveries := []logic.Delivery{
+		{ID: 1, ChatID: 1, URL: "/a.png", Caption: "1"},
+		{ID: 2, ChatID: 2, URL: "/a.png", Caption: "1"},
+		{ID: 3, ChatID: 3, URL: "/a.png", Caption: "1"},
+		{ID: 4, ChatID: 1, URL: "/a.xyz", Caption: "1"},
+	}
+	cm.MockOutbox.
+		EXPECT().
+		GetDueDeliveries(10).
+		Return(deliveries, nil)
+
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 1}), gomock.Any()).
+		Return(&telebot.Message{}, nil)
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 2}), gomock.Any()).
+		Return(nil, telebot.ErrBlockedByUser)
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 3}), gomock.Any()).
+		Return(nil, errors.New("connection reset"))
+
+	cm.MockOutbox.
+		EXPECT().
+		CompleteDelivery(&deliveries[0]).
+		Return(nil)
+	cm.MockOutbox.
+		EXPECT().
+		FailDelivery(&deliveries[1], telebot.ErrBlockedByUser, true).
+		Return(nil)
+	cm.MockOutbox.
+		EXPECT().
+		FailDelivery(&deliveries[2], errors.New("connection reset"), false).
+		Return(nil)
+	cm.MockOutbox.
+		EXPECT().
+		FailDelivery(&deliveries[3], gomock.Any(), true).
+		Return(errors.New("db error"))
+
+	assert.Equal(3, outbox.Flush(context.Background()))
+}
+
+func TestOutbox_FlushAlbum(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cm := mock_controller.NewMockController(ctrl)
+	sm := mock_sender.NewMockMessageSender(ctrl)
+	outbox := NewOutbox(&TgBot{
+		Bot:        sm,
+		Controller: &controller.Controller{Outbox: cm.MockOutbox},
+		Media:      media.Default(),
+	}, 0, 10)
+
+	// The third chat waits only for the second file of album
+	deliveries := []logic.Delivery{
+		{ID: 1, ChatID: 1, URL: "/a.png", Caption: "1", Album: "1:/a.png"},
+		{ID: 2, ChatID: 1, URL: "/b.png", Caption: "1", Album: "1:/a.png"},
+		{ID: 3, ChatID: 2, URL: "/a.png", Caption: "1", Album: "1:/a.png"},
+		{ID: 4, ChatID: 2, URL: "/b.png", Caption: "1", Album: "1:/a.png"},
+		{ID: 5, ChatID: 3, URL: "/b.png", Caption: "1", Album: "1:/a.png"},
+	}
+	cm.MockOutbox.
+		EXPECT().
+		GetDueDeliveries(10).
+		Return(deliveries, nil)
+
+	sm.
+		EXPECT().
+		SendAlbum(gomock.Eq(&telebot.Chat{ID: 1}), gomock.Any()).
+		Return([]telebot.Message{{ID: 10}, {ID: 11}}, nil)
+	sm.
+		EXPECT().
+		SendAlbum(gomock.Eq(&telebot.Chat{ID: 2}), gomock.Any()).
+		Return(nil, errors.New("connection reset"))
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 3}), gomock.Any()).
+		Return(&telebot.Message{}, nil)
+
+	cm.MockOutbox.EXPECT().CompleteDelivery(&deliveries[0]).Return(nil)
+	cm.MockOutbox.EXPECT().CompleteDelivery(&deliveries[1]).Return(nil)
+	cm.MockOutbox.EXPECT().FailDelivery(&deliveries[2], errors.New("connection reset"), false).Return(nil)
+	cm.MockOutbox.EXPECT().FailDelivery(&deliveries[3], errors.New("connection reset"), false).Return(nil)
+	cm.MockOutbox.EXPECT().CompleteDelivery(&deliveries[4]).Return(nil)
+
+	assert.Equal(5, outbox.Flush(context.Background()))
+}
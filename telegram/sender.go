@@ -1,8 +1,75 @@
 package telegram
 
-import "github.com/aoyako/telegram_2ch_res_bot/logic"
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
 
 // Sender can send files to users
 type Sender interface {
-	Send(user []*logic.User, path, caption string)
+	Send(ctx context.Context, user []*logic.User, path, caption, key string) []Result                 // Returns result of every user in the same order, key identifies file to send it without uploading again
+	SendAlbum(ctx context.Context, user []*logic.User, files []logic.File, caption string) [][]Result // Returns results of every file in the same order
+}
+
+// ErrorClass describes why file was not delivered
+type ErrorClass string
+
+// Classes of delivery errors
+const (
+	ClassNone      ErrorClass = ""          // File was delivered
+	ClassFlood     ErrorClass = "flood"     // Telegram limits were exceeded
+	ClassBlocked   ErrorClass = "blocked"   // Bot can not write to chat
+	ClassTooLarge  ErrorClass = "too_large" // File exceeds telegram limits
+	ClassRejected  ErrorClass = "rejected"  // Telegram refused file or request
+	ClassTransient ErrorClass = "transient" // Network or server error
+)
+
+// Result describes delivery of file to single chat
+type Result struct {
+	ChatID    int64
+	MessageID int        // Sent message, 0 if file was not sent or is queued
+	Queued    bool       // File is queued and will be sent later
+	Err       error      // Error of the latest attempt, nil if file was delivered
+	Class     ErrorClass // Class of Err
+}
+
+// Retry returns true if sending file later may succeed
+func (r *Result) Retry() bool {
+	return r.Class == ClassFlood || r.Class == ClassTransient
+}
+
+// Classify returns class of error returned by telegram
+func Classify(err error) ErrorClass {
+	if err == nil {
+		return ClassNone
+	}
+	if errors.Is(err, ErrUnknownFileType) || errors.Is(err, ErrConversion) {
+		return ClassRejected
+	}
+
+	var floodErr telebot.FloodError
+	if errors.As(err, &floodErr) {
+		return ClassFlood
+	}
+
+	var apiErr *telebot.APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.Description == telebot.ErrTooLarge.Description || apiErr.Code == http.StatusRequestEntityTooLarge:
+			return ClassTooLarge
+		case apiErr.Description == telebot.ErrChatNotFound.Description:
+			return ClassBlocked
+		case apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden:
+			return ClassBlocked
+		case apiErr.Code == http.StatusBadRequest:
+			return ClassRejected
+		}
+	}
+
+	return ClassTransient
 }
@@ -1,7 +1,11 @@
 package telegram
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"time"
@@ -10,16 +14,19 @@ import (
 	"github.com/aoyako/telegram_2ch_res_bot/controller"
 	"github.com/aoyako/telegram_2ch_res_bot/downloader"
 	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/media"
 	"github.com/xfrr/goffmpeg/transcoder"
 
 	telebot "gopkg.in/tucnak/telebot.v2"
 )
 
-var fileHandlersQueue = make(chan bool, 100)
+// Times file is sent to chat again after telegram asked to retry later
+const floodRetries = 3
 
 // MessageSender defines interface for bot-sender
 type MessageSender interface {
 	Send(r telebot.Recipient, value interface{}, args ...interface{}) (*telebot.Message, error)
+	SendAlbum(r telebot.Recipient, a telebot.Album, args ...interface{}) ([]telebot.Message, error)
 	Handle(interface{}, interface{})
 	Start()
 }
@@ -29,6 +36,9 @@ type TgBot struct {
 	Bot        MessageSender
 	Controller *controller.Controller
 	Downloader *downloader.Downloader
+	Media      *media.Registry // Known file types
+	Admins     []int64         // Chats receiving service notifications
+	Limiter    *Limiter        // Limits messages sent to chats, nil means no limit
 }
 
 // NewTelegramBot constructor of TelegramBot
@@ -63,6 +73,8 @@ func NewTelegramBot(token string, cnt *controller.Controller, d *downloader.Down
 		Bot:        bot,
 		Controller: cnt,
 		Downloader: d,
+		Media:      media.Default(),
+		Limiter:    NewLimiter(30, time.Second, 3*time.Second),
 	}
 }
 
@@ -72,6 +84,7 @@ func SetupHandlers(tb *TgBot) {
 	tb.Bot.Handle("/list", list(tb))
 	tb.Bot.Handle("/clist", cleverList(tb))
 	tb.Bot.Handle("/help", help(tb))
+	tb.Bot.Handle("/boards", boards(tb))
 
 	tb.Bot.Handle("/subs", subs(tb))
 	tb.Bot.Handle("/create", create(tb))
@@ -82,75 +95,431 @@ func SetupHandlers(tb *TgBot) {
 	tb.Bot.Handle("/rm_default", removeDefault(tb))
 }
 
-// Send files to users
-func (tb *TgBot) Send(users []*logic.User, path, caption string) {
-	if len(users) == 0 {
-		return
+// NotifyAdmins sends text to admin chats, admins left are not notified when context is done
+func (tb *TgBot) NotifyAdmins(ctx context.Context, text string) {
+	for _, chatID := range tb.Admins {
+		if err := tb.Limiter.Wait(ctx, chatID); err != nil {
+			log.Printf("Error notifying admin %d: %s", chatID, err.Error())
+			return
+		}
+		_, err := tb.Bot.Send(&telebot.Chat{ID: chatID}, text)
+		if err != nil {
+			log.Printf("Error notifying admin %d: %s", chatID, err.Error())
+		}
 	}
-	var file telebot.Sendable
-	if strings.HasSuffix(path, ".mp4") {
-		file = &telebot.Video{File: telebot.FromURL(path), Caption: caption}
+}
+
+// Send files to users, returns result of every user in the same order
+// Key identifies file, e.g. its md5, so file is uploaded once and then sent by telegram file id
+func (tb *TgBot) Send(ctx context.Context, users []*logic.User, path, caption, key string) []Result {
+	chatIDs := make([]int64, len(users))
+	for i, user := range users {
+		chatIDs[i] = user.ChatID
 	}
 
-	if strings.HasSuffix(path, ".png") || strings.HasSuffix(path, ".jpg") || strings.HasSuffix(path, ".jpeg") || strings.HasSuffix(path, ".gif") {
-		file = &telebot.Photo{File: telebot.FromURL(path), Caption: caption}
+	results := tb.deliver(ctx, chatIDs, path, caption, key)
+	for _, result := range results {
+		if result.Err != nil {
+			log.Printf("Error sending %s to %d (%s): %s", path, result.ChatID, result.Class, result.Err.Error())
+		}
 	}
+	return results
+}
 
-	if strings.HasSuffix(path, ".webm") {
-		defer func() {
-			err := tb.Downloader.Free(strings.TrimSuffix(path, ".webm") + ".mp4")
-			if err != nil {
-				log.Println(err)
+// SendAlbum sends files to users as albums, returns results of every file in the same order
+// Files which can not be grouped are sent one by one
+func (tb *TgBot) SendAlbum(ctx context.Context, users []*logic.User, files []logic.File, caption string) [][]Result {
+	chatIDs := make([]int64, len(users))
+	for i, user := range users {
+		chatIDs[i] = user.ChatID
+	}
+
+	results := tb.deliverAlbum(ctx, chatIDs, files, caption)
+	for i := range results {
+		for _, result := range results[i] {
+			if result.Err != nil {
+				log.Printf("Error sending %s to %d (%s): %s", files[i].URL, result.ChatID, result.Class, result.Err.Error())
 			}
-		}()
+		}
+	}
+	return results
+}
+
+// ErrUnknownFileType is returned when file can not be sent because its type is unknown
+var ErrUnknownFileType = errors.New("unknown file type")
+
+// ErrConversion is returned when video can not be converted to format supported by telegram
+var ErrConversion = errors.New("video conversion failed")
 
-		newVidPath, err := convertWebmToMp4(tb.Downloader, path)
+// Max amount of files in telegram album
+const maxAlbumSize = 10
+
+// File sent to chats, it is uploaded once and then sent by telegram file id
+type upload struct {
+	tb        *TgBot
+	path      string
+	key       string
+	format    media.Format
+	fileID    string        // Telegram file id, empty if file was not uploaded
+	source    *telebot.File // File to upload, prepared when it is needed
+	converted bool          // Video was converted to temporary file
+}
+
+// Returns file with saved telegram file id if there is one
+func (tb *TgBot) newUpload(path, key string, format media.Format) *upload {
+	return &upload{
+		tb:     tb,
+		path:   path,
+		key:    key,
+		format: format,
+		fileID: tb.uploadedFileID(key),
+	}
+}
+
+// Returns file to send, file without file id is prepared for uploading
+func (u *upload) file() (telebot.File, error) {
+	if u.fileID != "" {
+		return telebot.File{FileID: u.fileID}, nil
+	}
+
+	if u.source == nil {
+		u.converted = u.converted || u.format.Transcode
+		prepared, err := u.tb.prepareFile(u.path, u.format)
 		if err != nil {
-			log.Println(err)
-			return
+			return telebot.File{}, err
+		}
+		u.source = &prepared
+	}
+	return *u.source, nil
+}
+
+// Saves file id of file uploaded in message
+func (u *upload) uploaded(msg *telebot.Message) {
+	if u.fileID != "" || msg == nil {
+		return
+	}
+
+	u.fileID = sentFileID(msg, u.format.Method)
+	u.tb.saveFileID(u.key, u.fileID)
+}
+
+// Forgets file id which telegram does not accept, returns false if file has no file id
+func (u *upload) reject() bool {
+	if u.fileID == "" {
+		return false
+	}
+
+	log.Printf("Error sending %s by file id, uploading it", u.path)
+	u.tb.forgetFileID(u.key)
+	u.fileID = ""
+	return true
+}
+
+// Removes converted video from disk
+func (u *upload) free() {
+	if !u.converted {
+		return
+	}
+
+	err := u.tb.Downloader.Free(strings.TrimSuffix(u.path, filepath.Ext(u.path)) + ".mp4")
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Returns results of chats, all of them failed with err
+func failedResults(chatIDs []int64, err error) []Result {
+	results := make([]Result, len(chatIDs))
+	for i, chatID := range chatIDs {
+		results[i] = Result{ChatID: chatID, Err: err, Class: Classify(err)}
+	}
+	return results
+}
+
+// Sends file to chats, returns result of every chat in the same order
+// File is uploaded for the first chat, others receive it by file id
+func (tb *TgBot) deliver(ctx context.Context, chatIDs []int64, path, caption, key string) []Result {
+	format, ok := tb.Media.Lookup(path)
+	if !ok {
+		return failedResults(chatIDs, fmt.Errorf("%w: %s", ErrUnknownFileType, path))
+	}
+
+	u := tb.newUpload(path, key, format)
+	defer u.free()
+
+	results := make([]Result, len(chatIDs))
+	for i := 0; i < len(chatIDs); i++ {
+		results[i].ChatID = chatIDs[i]
+		file, err := u.file()
+		if err != nil {
+			copy(results[i:], failedResults(chatIDs[i:], err))
+			break
 		}
 
-		file = &telebot.Video{File: telebot.FromDisk(newVidPath), Caption: caption}
+		var msg *telebot.Message
+		err = tb.limited(ctx, chatIDs[i], func() (err error) {
+			msg, err = tb.Bot.Send(&telebot.Chat{ID: chatIDs[i]}, sendable(format.Method, file, caption))
+			return err
+		})
+		if err != nil && Classify(err) == ClassRejected && u.reject() {
+			i--
+			continue
+		}
+		if err != nil {
+			results[i].Err = err
+			results[i].Class = Classify(err)
+			continue
+		}
+		if msg != nil {
+			results[i].MessageID = msg.ID
+			u.uploaded(msg)
+		}
 	}
 
-	for _, user := range users {
-		for {
-			fileHandlersQueue <- true
+	return results
+}
+
+// Sends files to chats as albums of photos and videos, other files are sent one by one
+// Caption is attached to the first file of album, returns results of every file in the same order
+func (tb *TgBot) deliverAlbum(ctx context.Context, chatIDs []int64, files []logic.File, caption string) [][]Result {
+	results := make([][]Result, len(files))
+	grouped := make([]int, 0, len(files))
+	for i, file := range files {
+		format, ok := tb.Media.Lookup(file.URL)
+		if ok && (format.Method == media.MethodPhoto || format.Method == media.MethodVideo) {
+			grouped = append(grouped, i)
+			continue
+		}
+		results[i] = tb.deliver(ctx, chatIDs, file.URL, caption, file.Key)
+	}
 
-			_, err := tb.Bot.Send(&telebot.Chat{
-				ID: int64(user.ChatID),
-			}, file)
+	for start := 0; start < len(grouped); start += maxAlbumSize {
+		end := start + maxAlbumSize
+		if end > len(grouped) {
+			end = len(grouped)
+		}
+		album := grouped[start:end]
 
-			<-fileHandlersQueue
+		if len(album) == 1 {
+			file := files[album[0]]
+			results[album[0]] = tb.deliver(ctx, chatIDs, file.URL, caption, file.Key)
+			continue
+		}
 
+		uploads := make([]*upload, len(album))
+		for i, index := range album {
+			format, _ := tb.Media.Lookup(files[index].URL)
+			uploads[i] = tb.newUpload(files[index].URL, files[index].Key, format)
+		}
+		for i, albumResults := range tb.sendAlbum(ctx, chatIDs, uploads, caption) {
+			results[album[i]] = albumResults
+		}
+	}
+
+	return results
+}
+
+// Sends album to chats, returns results of every file in the same order
+// Telegram delivers album as a whole, so all files of chat share error
+// File which can not be prepared fails alone and the rest of album is sent without it
+func (tb *TgBot) sendAlbum(ctx context.Context, chatIDs []int64, uploads []*upload, caption string) [][]Result {
+	results := make([][]Result, len(uploads))
+	pending := make([]int, len(uploads)) // Indexes of uploads which are sent
+	for j, u := range uploads {
+		defer u.free()
+		results[j] = make([]Result, len(chatIDs))
+		for i, chatID := range chatIDs {
+			results[j][i].ChatID = chatID
+		}
+		pending[j] = j
+	}
+
+	for i := 0; i < len(chatIDs) && len(pending) != 0; i++ {
+		album := make(telebot.Album, 0, len(pending))
+		files := make([]telebot.File, 0, len(pending))
+		prepared := pending[:0]
+		for _, j := range pending {
+			file, err := uploads[j].file()
 			if err != nil {
-				if e, ok := err.(telebot.FloodError); ok {
-					time.Sleep(time.Duration(e.RetryAfter) * time.Second)
-					continue
-				} else {
-					log.Println(err)
-				}
+				copy(results[j][i:], failedResults(chatIDs[i:], err))
+				continue
 			}
+
+			itemCaption := ""
+			if len(album) == 0 {
+				itemCaption = caption
+			}
+			album = append(album, inputMedia(uploads[j].format.Method, file, itemCaption))
+			files = append(files, file)
+			prepared = append(prepared, j)
+		}
+		pending = prepared
+		if len(pending) == 0 {
 			break
 		}
+
+		var msgs []telebot.Message
+		err := tb.limited(ctx, chatIDs[i], func() (err error) {
+			if len(album) == 1 {
+				// Album has to contain at least two files
+				var msg *telebot.Message
+				msg, err = tb.Bot.Send(&telebot.Chat{ID: chatIDs[i]}, sendable(uploads[pending[0]].format.Method, files[0], caption))
+				if msg != nil {
+					msgs = []telebot.Message{*msg}
+				}
+				return err
+			}
+			msgs, err = tb.Bot.SendAlbum(&telebot.Chat{ID: chatIDs[i]}, album)
+			return err
+		})
+		if err != nil && Classify(err) == ClassRejected {
+			// It is unknown which file id was not accepted, all files are uploaded again
+			rejected := false
+			for _, j := range pending {
+				rejected = uploads[j].reject() || rejected
+			}
+			if rejected {
+				i--
+				continue
+			}
+		}
+
+		for k, j := range pending {
+			if err != nil {
+				results[j][i].Err = err
+				results[j][i].Class = Classify(err)
+				continue
+			}
+			if k < len(msgs) {
+				results[j][i].MessageID = msgs[k].ID
+				uploads[j].uploaded(&msgs[k])
+			}
+		}
+	}
+
+	return results
+}
+
+// Sends to chat within limits, repeats sending if telegram asks to retry later
+// Returns context error if context is done before file is sent
+func (tb *TgBot) limited(ctx context.Context, chatID int64, send func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := tb.Limiter.Wait(ctx, chatID); err != nil {
+			return err
+		}
+
+		err := send()
+		if e, ok := err.(telebot.FloodError); ok && attempt < floodRetries {
+			tb.Limiter.Pause(chatID, time.Duration(e.RetryAfter)*time.Second)
+			continue
+		}
+		return err
+	}
+}
+
+// Prepares file for uploading, videos which telegram does not support are converted
+func (tb *TgBot) prepareFile(path string, format media.Format) (telebot.File, error) {
+	if !format.Transcode {
+		return telebot.FromURL(path), nil
+	}
+
+	newVidPath, err := convertToMp4(tb.Downloader, path)
+	if err != nil {
+		return telebot.File{}, fmt.Errorf("%w: %s", ErrConversion, err)
+	}
+	return telebot.FromDisk(newVidPath), nil
+}
+
+// Returns message with file sent by method
+func sendable(method media.Method, file telebot.File, caption string) telebot.Sendable {
+	switch method {
+	case media.MethodPhoto:
+		return &telebot.Photo{File: file, Caption: caption}
+	case media.MethodVideo:
+		return &telebot.Video{File: file, Caption: caption}
+	case media.MethodAnimation:
+		return &telebot.Animation{File: file, Caption: caption}
+	default:
+		return &telebot.Document{File: file, Caption: caption}
+	}
+}
+
+// Returns album item with file sent by method, it has to be photo or video
+func inputMedia(method media.Method, file telebot.File, caption string) telebot.InputMedia {
+	if method == media.MethodVideo {
+		return &telebot.Video{File: file, Caption: caption}
+	}
+	return &telebot.Photo{File: file, Caption: caption}
+}
+
+// Returns telegram file id of file sent in message by method, empty if message has no such file
+func sentFileID(msg *telebot.Message, method media.Method) string {
+	switch {
+	case method == media.MethodPhoto && msg.Photo != nil:
+		return msg.Photo.FileID
+	case method == media.MethodVideo && msg.Video != nil:
+		return msg.Video.FileID
+	case method == media.MethodAnimation && msg.Animation != nil:
+		return msg.Animation.FileID
+	case method == media.MethodDocument && msg.Document != nil:
+		return msg.Document.FileID
+	}
+	return ""
+}
+
+// Returns saved file id of file with key, empty if file was not uploaded
+func (tb *TgBot) uploadedFileID(key string) string {
+	if key == "" || tb.Controller == nil || tb.Controller.Upload == nil {
+		return ""
+	}
+
+	fileID, err := tb.Controller.Upload.GetFileID(key)
+	if err != nil {
+		log.Printf("Error reading file id of %s: %s", key, err.Error())
+	}
+	return fileID
+}
+
+// Saves file id of uploaded file with key
+func (tb *TgBot) saveFileID(key, fileID string) {
+	if key == "" || fileID == "" || tb.Controller == nil || tb.Controller.Upload == nil {
+		return
+	}
+
+	err := tb.Controller.Upload.SaveFileID(key, fileID)
+	if err != nil {
+		log.Printf("Error saving file id of %s: %s", key, err.Error())
+	}
+}
+
+// Forgets file id of file with key
+func (tb *TgBot) forgetFileID(key string) {
+	if key == "" || tb.Controller == nil || tb.Controller.Upload == nil {
+		return
+	}
+
+	err := tb.Controller.Upload.ForgetFileID(key)
+	if err != nil {
+		log.Printf("Error removing file id of %s: %s", key, err.Error())
 	}
 }
 
-func convertWebmToMp4(d *downloader.Downloader, path string) (string, error) {
+// Converts video to mp4, returns path of converted file
+func convertToMp4(d *downloader.Downloader, path string) (string, error) {
 	trans := new(transcoder.Transcoder)
 
 	vidPath := d.Get(path)
-	newVidPath := strings.TrimSuffix(vidPath, ".webm") + ".mp4"
+	newVidPath := strings.TrimSuffix(vidPath, filepath.Ext(vidPath)) + ".mp4"
 
 	err := trans.Initialize(path, newVidPath)
 	if err != nil {
-		log.Println("convertWebmToMp4", err)
+		log.Println("convertToMp4", err)
 		return "", err
 	}
 	done := trans.Run(false)
 	err = <-done
 	if err != nil {
-		log.Println("convertWebmToMp4-finish", err)
+		log.Println("convertToMp4-finish", err)
 		log.Println(err)
 		return "", err
 	}
@@ -0,0 +1,259 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aoyako/telegram_2ch_res_bot/controller"
+	"github.com/aoyako/telegram_2ch_res_bot/downloader"
+	"github.com/aoyako/telegram_2ch_res_bot/logic"
+	"github.com/aoyako/telegram_2ch_res_bot/media"
+	mock_controller "github.com/aoyako/telegram_2ch_res_bot/telegram/mock"
+	mock_sender "github.com/aoyako/telegram_2ch_res_bot/telegram/mock/bot"
+	mock_downloader "github.com/aoyako/telegram_2ch_res_bot/telegram/mock/downloader"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestTgBot_NotifyAdmins(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_sender.NewMockMessageSender(ctrl)
+	bot := &TgBot{
+		Bot:    sm,
+		Admins: []int64{1, 2},
+	}
+
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 1}), "host is down").
+		Return(nil, errors.New("blocked"))
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 2}), "host is down").
+		Return(&telebot.Message{}, nil)
+
+	bot.NotifyAdmins(context.Background(), "host is down")
+}
+
+func TestClassify(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{nil, ClassNone},
+		{telebot.FloodError{APIError: telebot.NewAPIError(429, "Too Many Requests: retry after 8"), RetryAfter: 8}, ClassFlood},
+		{telebot.ErrBlockedByUser, ClassBlocked},
+		{telebot.ErrBotKickedFromGroup, ClassBlocked},
+		{telebot.ErrChatNotFound, ClassBlocked},
+		{telebot.ErrTooLarge, ClassTooLarge},
+		{telebot.ErrWrongFileID, ClassRejected},
+		{ErrUnknownFileType, ClassRejected},
+		{fmt.Errorf("%w: exit status 1", ErrConversion), ClassRejected},
+		{telebot.ErrInternal, ClassTransient},
+		{errors.New("connection reset"), ClassTransient},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(tt.want, Classify(tt.err), tt.err)
+	}
+}
+
+func TestTgBot_Send(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_sender.NewMockMessageSender(ctrl)
+	bot := &TgBot{
+		Bot:   sm,
+		Media: media.Default(),
+	}
+
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 1}), gomock.Any()).
+		Return(&telebot.Message{ID: 10}, nil)
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 2}), gomock.Any()).
+		Return(nil, telebot.ErrBlockedByUser)
+
+	results := bot.Send(context.Background(), []*logic.User{{ChatID: 1}, {ChatID: 2}}, "/a.png", "1", "")
+	assert.Equal([]Result{
+		{ChatID: 1, MessageID: 10},
+		{ChatID: 2, Err: telebot.ErrBlockedByUser, Class: ClassBlocked},
+	}, results)
+
+	results = bot.Send(context.Background(), []*logic.User{{ChatID: 1}}, "/a.xyz", "1", "")
+	assert.Equal(ClassRejected, results[0].Class)
+	assert.True(errors.Is(results[0].Err, ErrUnknownFileType))
+}
+
+func TestTgBot_SendUploaded(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cm := mock_controller.NewMockController(ctrl)
+	sm := mock_sender.NewMockMessageSender(ctrl)
+	bot := &TgBot{
+		Bot:        sm,
+		Controller: &controller.Controller{Upload: cm.MockUpload},
+		Media:      media.Default(),
+	}
+	uploaded := func(fileID string) *telebot.Message {
+		return &telebot.Message{ID: 10, Photo: &telebot.Photo{File: telebot.File{FileID: fileID}}}
+	}
+
+	// File is uploaded once, the second chat receives it by file id
+	gomock.InOrder(
+		cm.MockUpload.
+			EXPECT().
+			GetFileID("md5:abc").
+			Return("", nil),
+		sm.
+			EXPECT().
+			Send(gomock.Eq(&telebot.Chat{ID: 1}), gomock.Eq(&telebot.Photo{File: telebot.FromURL("/a.png"), Caption: "1"})).
+			Return(uploaded("AgAD"), nil),
+		cm.MockUpload.
+			EXPECT().
+			SaveFileID("md5:abc", "AgAD").
+			Return(nil),
+		sm.
+			EXPECT().
+			Send(gomock.Eq(&telebot.Chat{ID: 2}), gomock.Eq(&telebot.Photo{File: telebot.File{FileID: "AgAD"}, Caption: "1"})).
+			Return(uploaded("AgAD"), nil),
+	)
+
+	results := bot.Send(context.Background(), []*logic.User{{ChatID: 1}, {ChatID: 2}}, "/a.png", "1", "md5:abc")
+	assert.Nil(results[0].Err)
+	assert.Nil(results[1].Err)
+
+	// File id which is not accepted is forgotten, file is uploaded again
+	gomock.InOrder(
+		cm.MockUpload.
+			EXPECT().
+			GetFileID("md5:abc").
+			Return("AgAD", nil),
+		sm.
+			EXPECT().
+			Send(gomock.Eq(&telebot.Chat{ID: 1}), gomock.Eq(&telebot.Photo{File: telebot.File{FileID: "AgAD"}, Caption: "1"})).
+			Return(nil, telebot.ErrWrongFileID),
+		cm.MockUpload.
+			EXPECT().
+			ForgetFileID("md5:abc").
+			Return(nil),
+		sm.
+			EXPECT().
+			Send(gomock.Eq(&telebot.Chat{ID: 1}), gomock.Eq(&telebot.Photo{File: telebot.FromURL("/a.png"), Caption: "1"})).
+			Return(uploaded("AgAE"), nil),
+		cm.MockUpload.
+			EXPECT().
+			SaveFileID("md5:abc", "AgAE").
+			Return(nil),
+	)
+
+	results = bot.Send(context.Background(), []*logic.User{{ChatID: 1}}, "/a.png", "1", "md5:abc")
+	assert.Equal([]Result{{ChatID: 1, MessageID: 10}}, results)
+}
+
+func TestTgBot_SendAlbum(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_sender.NewMockMessageSender(ctrl)
+	bot := &TgBot{
+		Bot:   sm,
+		Media: media.Default(),
+	}
+
+	// Animation can not be sent in album
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 1}), gomock.Eq(&telebot.Animation{File: telebot.FromURL("/c.gif"), Caption: "1"})).
+		Return(&telebot.Message{ID: 12}, nil)
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 2}), gomock.Any()).
+		Return(&telebot.Message{ID: 13}, nil)
+
+	// Caption is attached to the first file of album
+	album := telebot.Album{
+		&telebot.Photo{File: telebot.FromURL("/a.png"), Caption: "1"},
+		&telebot.Video{File: telebot.FromURL("/b.mp4")},
+	}
+	sm.
+		EXPECT().
+		SendAlbum(gomock.Eq(&telebot.Chat{ID: 1}), gomock.Eq(album)).
+		Return([]telebot.Message{{ID: 10}, {ID: 11}}, nil)
+	sm.
+		EXPECT().
+		SendAlbum(gomock.Eq(&telebot.Chat{ID: 2}), gomock.Eq(album)).
+		Return(nil, telebot.ErrBlockedByUser)
+
+	files := []logic.File{{URL: "/a.png"}, {URL: "/b.mp4"}, {URL: "/c.gif"}}
+	results := bot.SendAlbum(context.Background(), []*logic.User{{ChatID: 1}, {ChatID: 2}}, files, "1")
+	assert.Equal([][]Result{
+		{{ChatID: 1, MessageID: 10}, {ChatID: 2, Err: telebot.ErrBlockedByUser, Class: ClassBlocked}},
+		{{ChatID: 1, MessageID: 11}, {ChatID: 2, Err: telebot.ErrBlockedByUser, Class: ClassBlocked}},
+		{{ChatID: 1, MessageID: 12}, {ChatID: 2, MessageID: 13}},
+	}, results)
+}
+
+func TestTgBot_SendAlbumConversion(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_sender.NewMockMessageSender(ctrl)
+	dm := mock_downloader.NewMockLoader(ctrl)
+	bot := &TgBot{
+		Bot:        sm,
+		Media:      media.Default(),
+		Downloader: &downloader.Downloader{Loader: dm},
+	}
+
+	// Video which can not be converted is left out of album
+	dm.EXPECT().Get(gomock.Any()).Return("/tmp/missing.webm").AnyTimes()
+	dm.EXPECT().Free(gomock.Any()).Return(nil).AnyTimes()
+	album := telebot.Album{
+		&telebot.Photo{File: telebot.FromURL("/a.png"), Caption: "1"},
+		&telebot.Photo{File: telebot.FromURL("/c.jpg")},
+	}
+	sm.
+		EXPECT().
+		SendAlbum(gomock.Eq(&telebot.Chat{ID: 1}), gomock.Eq(album)).
+		Return([]telebot.Message{{ID: 10}, {ID: 11}}, nil)
+	sm.
+		EXPECT().
+		SendAlbum(gomock.Eq(&telebot.Chat{ID: 2}), gomock.Eq(album)).
+		Return([]telebot.Message{{ID: 12}, {ID: 13}}, nil)
+
+	files := []logic.File{{URL: "/a.png"}, {URL: "/missing.webm"}, {URL: "/c.jpg"}}
+	results := bot.SendAlbum(context.Background(), []*logic.User{{ChatID: 1}, {ChatID: 2}}, files, "1")
+	assert.Equal([]Result{{ChatID: 1, MessageID: 10}, {ChatID: 2, MessageID: 12}}, results[0])
+	assert.Equal([]Result{{ChatID: 1, MessageID: 11}, {ChatID: 2, MessageID: 13}}, results[2])
+	for _, result := range results[1] {
+		assert.True(errors.Is(result.Err, ErrConversion))
+		assert.Equal(ClassRejected, result.Class)
+	}
+
+	// The only file left is sent alone
+	sm.
+		EXPECT().
+		Send(gomock.Eq(&telebot.Chat{ID: 1}), gomock.Eq(&telebot.Photo{File: telebot.FromURL("/a.png"), Caption: "1"})).
+		Return(&telebot.Message{ID: 14}, nil)
+
+	files = []logic.File{{URL: "/a.png"}, {URL: "/missing.webm"}}
+	results = bot.SendAlbum(context.Background(), []*logic.User{{ChatID: 1}}, files, "1")
+	assert.Equal([]Result{{ChatID: 1, MessageID: 14}}, results[0])
+	assert.True(errors.Is(results[1][0].Err, ErrConversion))
+}